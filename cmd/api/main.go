@@ -3,8 +3,14 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/clock"
 	"github.com/williamnoble/kube-botany/pkg/config"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/gen"
 	"github.com/williamnoble/kube-botany/pkg/repository"
+	"github.com/williamnoble/kube-botany/pkg/retention"
+	"github.com/williamnoble/kube-botany/pkg/scheduler"
 	"github.com/williamnoble/kube-botany/pkg/server"
 	"log"
 	"net/http"
@@ -20,12 +26,82 @@ func main() {
 		log.Fatalf("failed to read config: %v", err)
 	}
 
-	inMemoryStore, err := repository.NewInMemoryStore(true)
+	clk, err := newClock(c)
 	if err != nil {
-		log.Fatalf("server: failed to create in-memory store: %v\n", err)
+		log.Fatalf("server: failed to create clock: %v\n", err)
 	}
 
-	svr, err := server.NewServer(inMemoryStore)
+	images, err := imageStore(c)
+	if err != nil {
+		log.Fatalf("server: failed to create %s image store: %v\n", c.ImageStore, err)
+	}
+	if images != nil && c.ImageCacheMB > 0 {
+		images = fs.NewCachedImageStore(images, c.ImageCacheMB<<20)
+	}
+
+	store, err := repository.New(repository.Options{
+		Driver:       c.Store,
+		DataDir:      c.DataDir,
+		DatabasePath: c.DatabasePath,
+		Populate:     true,
+		Images:       images,
+		Clock:        clk,
+	})
+	if err != nil {
+		log.Fatalf("server: failed to create %s store: %v\n", c.Store, err)
+	}
+
+	provider, err := gen.NewProvider(gen.ProviderConfig{
+		Provider:  c.ImageProvider,
+		APIKey:    c.ImageAPIKey,
+		BaseURL:   c.ImageBaseURL,
+		Model:     c.ImageModel,
+		StaticDir: "pkg/static",
+	})
+	if err != nil {
+		log.Fatalf("server: failed to create %s image provider: %v\n", c.ImageProvider, err)
+	}
+
+	prompts, err := gen.NewPromptBuilder(c.PromptVersion, c.PromptTemplateDir)
+	if err != nil {
+		log.Fatalf("server: failed to load prompt templates: %v\n", err)
+	}
+
+	imageSchedule, err := scheduler.Parse(c.ImageSchedule)
+	if err != nil {
+		log.Fatalf("server: invalid image schedule: %v\n", err)
+	}
+
+	policies, err := retention.NewPolicies(retention.Policy{
+		KeepLast:   c.RetentionKeepLast,
+		KeepWeekly: c.RetentionKeepWeekly,
+	}, c.RetentionPlantPolicies)
+	if err != nil {
+		log.Fatalf("server: invalid image retention policy: %v\n", err)
+	}
+
+	opts := []server.Option{
+		server.WithImageProvider(provider, gen.Options{
+			Timeout:     c.ImageTimeout,
+			Prompts:     prompts,
+			Backdrop:    c.ImageBackdrop,
+			Clock:       clk,
+			Workers:     c.ImageWorkers,
+			RateLimit:   gen.RateLimit{PerMinute: c.ImageRatePerMinute, Burst: c.ImageRateBurst},
+			MaxAttempts: c.ImageMaxAttempts,
+			JobBackoff:  c.ImageJobBackoff,
+			JobLease:    c.ImageJobLease,
+		}),
+		server.WithImageSchedule(imageSchedule),
+		server.WithClock(clk),
+		server.WithGrowthInterval(c.GrowthInterval),
+		server.WithRetention(policies, c.RetentionInterval),
+	}
+	if c.ManifestDir != "" {
+		opts = append(opts, server.WithManifestDir(c.ManifestDir, c.ManifestSyncInterval))
+	}
+
+	svr, err := server.NewServer(store, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -55,3 +131,40 @@ func main() {
 
 	svr.Logger.With("component", "server").Info("graceful shutdown complete")
 }
+
+// newClock returns a simulated clock when one is configured, otherwise the system clock.
+func newClock(c *config.Config) (clock.Clock, error) {
+	if !c.SimulatedClock && c.ClockAcceleration == 1 {
+		return clock.System{}, nil
+	}
+	return clock.NewSimulated(time.Time{}, c.ClockAcceleration)
+}
+
+// imageStore returns the configured image store, or nil to keep images in the plant store's own image store.
+func imageStore(c *config.Config) (fs.ImageStore, error) {
+	switch c.ImageStore {
+	case "":
+		return nil, nil
+	case "disk":
+		images, err := fs.NewDiskImageStore(c.ImageDir)
+		if err != nil {
+			return nil, err
+		}
+		return images, nil
+	case "s3":
+		images, err := fs.NewS3ImageStore(fs.S3Config{
+			Endpoint:        c.S3Endpoint,
+			Region:          c.S3Region,
+			Bucket:          c.S3Bucket,
+			Prefix:          c.S3Prefix,
+			AccessKeyID:     c.S3AccessKeyID,
+			SecretAccessKey: c.S3SecretAccessKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return images, nil
+	default:
+		return nil, fmt.Errorf("unsupported image store %q", c.ImageStore)
+	}
+}
@@ -0,0 +1,151 @@
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Clock tells the time of the simulation. Everything which ages a plant reads the time from a Clock rather than
+// calling time.Now, so that a garden can be fast-forwarded for demos and controlled in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the Clock of the system, it tells the real time.
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// ErrBackwards is returned when simulated time would move backwards, plants only ever age.
+var ErrBackwards = errors.New("clock: simulated time cannot move backwards")
+
+// Simulated is a Clock which runs at a multiple of real time, and which can be advanced and frozen. When running,
+// each real second advances simulated time by Factor seconds, time doesn't pass at all while it is frozen.
+type Simulated struct {
+	mu     sync.Mutex
+	real   func() time.Time // the source of real time
+	since  time.Time        // the real time at which simulated time was last anchored
+	at     time.Time        // the simulated time at since
+	factor float64
+	frozen bool
+}
+
+// State describes a Simulated clock.
+type State struct {
+	Now    time.Time `json:"now"`    // the simulated time
+	Factor float64   `json:"factor"` // simulated seconds per real second while running
+	Frozen bool      `json:"frozen"` // true when simulated time has stopped
+}
+
+// NewSimulated returns a running clock which starts at start, or at the real time when start is zero, and runs
+// factor times faster than real time.
+func NewSimulated(start time.Time, factor float64) (*Simulated, error) {
+	if err := validateFactor(factor); err != nil {
+		return nil, err
+	}
+	c := &Simulated{real: time.Now, factor: factor}
+	c.since = c.real()
+	c.at = start
+	if c.at.IsZero() {
+		c.at = c.since
+	}
+	return c, nil
+}
+
+// NewFrozen returns a frozen clock, which reads at until it is advanced, for tests.
+func NewFrozen(at time.Time) *Simulated {
+	return &Simulated{real: time.Now, since: time.Now(), at: at, factor: 1, frozen: true}
+}
+
+// Now returns the simulated time.
+func (c *Simulated) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nowLocked()
+}
+
+// State returns the simulated time, and how it passes.
+func (c *Simulated) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return State{Now: c.nowLocked(), Factor: c.factor, Frozen: c.frozen}
+}
+
+// Advance moves simulated time forward by d, whether or not the clock is frozen.
+func (c *Simulated) Advance(d time.Duration) error {
+	if d < 0 {
+		return ErrBackwards
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.anchorLocked()
+	c.at = c.at.Add(d)
+	return nil
+}
+
+// Set moves simulated time forward to t.
+func (c *Simulated) Set(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.anchorLocked()
+	if t.Before(c.at) {
+		return ErrBackwards
+	}
+	c.at = t
+	return nil
+}
+
+// Freeze stops simulated time until the clock is resumed.
+func (c *Simulated) Freeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.anchorLocked()
+	c.frozen = true
+}
+
+// Resume restarts simulated time after the clock was frozen.
+func (c *Simulated) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.anchorLocked()
+	c.frozen = false
+}
+
+// SetFactor changes how many times faster than real time simulated time runs, from now on.
+func (c *Simulated) SetFactor(factor float64) error {
+	if err := validateFactor(factor); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.anchorLocked()
+	c.factor = factor
+	return nil
+}
+
+// nowLocked returns the simulated time. The caller holds c.mu.
+func (c *Simulated) nowLocked() time.Time {
+	if c.frozen {
+		return c.at
+	}
+	elapsed := c.real().Sub(c.since)
+	return c.at.Add(time.Duration(float64(elapsed) * c.factor))
+}
+
+// anchorLocked records the current simulated time against the current real time, so that a change to how time
+// passes only applies from now on. The caller holds c.mu.
+func (c *Simulated) anchorLocked() {
+	c.at = c.nowLocked()
+	c.since = c.real()
+}
+
+func validateFactor(factor float64) error {
+	if !(factor > 0) || factor > 1e6 {
+		return fmt.Errorf("clock: time acceleration factor must be greater than 0 and at most 1000000, got %v", factor)
+	}
+	return nil
+}
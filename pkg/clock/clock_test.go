@@ -0,0 +1,67 @@
+package clock
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// newTestClock returns a simulated clock whose real time is advanced by the returned function
+func newTestClock(t *testing.T, start time.Time, factor float64) (*Simulated, func(time.Duration)) {
+	t.Helper()
+	c, err := NewSimulated(start, factor)
+	require.NoError(t, err)
+	real := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	c.real = func() time.Time { return real }
+	c.since = real
+	return c, func(d time.Duration) { real = real.Add(d) }
+}
+
+func TestSimulated(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c, sleep := newTestClock(t, start, 60)
+	assert.Equal(t, start, c.Now())
+
+	sleep(time.Minute)
+	assert.Equal(t, start.Add(time.Hour), c.Now(), "a minute is an hour at 60x")
+
+	require.NoError(t, c.Advance(24*time.Hour))
+	assert.Equal(t, start.Add(25*time.Hour), c.Now())
+
+	c.Freeze()
+	sleep(time.Hour)
+	assert.Equal(t, start.Add(25*time.Hour), c.Now(), "time doesn't pass while frozen")
+	require.NoError(t, c.Advance(time.Hour))
+	assert.Equal(t, start.Add(26*time.Hour), c.Now(), "a frozen clock can still be advanced")
+
+	c.Resume()
+	require.NoError(t, c.SetFactor(1))
+	sleep(time.Minute)
+	assert.Equal(t, start.Add(26*time.Hour+time.Minute), c.Now())
+	assert.Equal(t, State{Now: start.Add(26*time.Hour + time.Minute), Factor: 1}, c.State())
+}
+
+func TestSimulatedNeverMovesBackwards(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c, _ := newTestClock(t, start, 1)
+
+	assert.ErrorIs(t, c.Advance(-time.Second), ErrBackwards)
+	assert.ErrorIs(t, c.Set(start.Add(-time.Second)), ErrBackwards)
+	require.NoError(t, c.Set(start.Add(48*time.Hour)))
+	assert.Equal(t, start.Add(48*time.Hour), c.Now())
+}
+
+func TestSimulatedFactor(t *testing.T) {
+	t.Parallel()
+	for _, factor := range []float64{0, -1, 2e6} {
+		_, err := NewSimulated(time.Time{}, factor)
+		assert.Error(t, err, "factor %v", factor)
+	}
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFrozen(at)
+	assert.Error(t, c.SetFactor(0))
+	assert.Equal(t, State{Now: at, Factor: 1, Frozen: true}, c.State())
+}
@@ -2,10 +2,77 @@ package config
 
 import (
 	"github.com/caarlos0/env/v11"
+	"time"
 )
 
 type Config struct {
-	Port string `env:"PORT" envDefault:"8090"`
+	Port         string `env:"PORT" envDefault:"8090"`
+	Store        string `env:"STORE" envDefault:"memory"` // memory, file or sqlite
+	DataDir      string `env:"DATA_DIR" envDefault:"data"`
+	DatabasePath string `env:"DATABASE_PATH" envDefault:"data/kube-botany.db"`
+
+	// ImageProvider selects how plant images are generated: openai (any OpenAI-compatible API), procedural,
+	// local or mock
+	ImageProvider string        `env:"IMAGE_PROVIDER" envDefault:"procedural"`
+	ImageAPIKey   string        `env:"IMAGE_API_KEY"`
+	ImageBaseURL  string        `env:"IMAGE_BASE_URL" envDefault:"https://api.openai.com/v1"`
+	ImageModel    string        `env:"IMAGE_MODEL" envDefault:"gpt-image-1"`
+	ImageTimeout  time.Duration `env:"IMAGE_TIMEOUT" envDefault:"2m"`
+	ImageBackdrop string        `env:"IMAGE_BACKDROP" envDefault:"library"`
+
+	// ImageWorkers is the number of images generated at once. ImageRatePerMinute limits calls to the image provider,
+	// allowing bursts of ImageRateBurst calls: the provider's default limit applies when it is zero, and calls are
+	// unlimited when it is negative
+	ImageWorkers       int     `env:"IMAGE_WORKERS" envDefault:"4"`
+	ImageRatePerMinute float64 `env:"IMAGE_RATE_PER_MINUTE"`
+	ImageRateBurst     int     `env:"IMAGE_RATE_BURST"`
+
+	// A failed image is retried after ImageJobBackoff, doubled for each further failure, until it has been attempted
+	// ImageMaxAttempts times. An attempt which hasn't finished after ImageJobLease, e.g. because its replica stopped,
+	// is retried
+	ImageMaxAttempts int           `env:"IMAGE_MAX_ATTEMPTS" envDefault:"5"`
+	ImageJobBackoff  time.Duration `env:"IMAGE_JOB_BACKOFF" envDefault:"5m"`
+	ImageJobLease    time.Duration `env:"IMAGE_JOB_LEASE" envDefault:"1h"`
+
+	// ImageStore selects where images are kept: the store's own image store when empty, disk for ImageDir, or
+	// s3 for an S3-compatible bucket such as MinIO
+	ImageStore        string `env:"IMAGE_STORE"`
+	ImageDir          string `env:"IMAGE_DIR" envDefault:"data/images"`
+	ImageCacheMB      int64  `env:"IMAGE_CACHE_MB" envDefault:"64"` // memory cache in front of disk or s3, 0 disables it
+	S3Endpoint        string `env:"S3_ENDPOINT"`
+	S3Region          string `env:"S3_REGION" envDefault:"us-east-1"`
+	S3Bucket          string `env:"S3_BUCKET"`
+	S3Prefix          string `env:"S3_PREFIX"`
+	S3AccessKeyID     string `env:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `env:"S3_SECRET_ACCESS_KEY"`
+
+	// PromptVersion selects the embedded prompt templates, those in PromptTemplateDir override them
+	PromptVersion     string `env:"PROMPT_VERSION" envDefault:"v1"`
+	PromptTemplateDir string `env:"PROMPT_TEMPLATE_DIR"`
+
+	// Retention keeps the last RetentionKeepLast images of each plant and one image for each of the last
+	// RetentionKeepWeekly weeks. RetentionPlantPolicies overrides this for individual plants, e.g. "FooPlant:7/4"
+	RetentionKeepLast      int               `env:"RETENTION_KEEP_LAST" envDefault:"30"`
+	RetentionKeepWeekly    int               `env:"RETENTION_KEEP_WEEKLY" envDefault:"52"`
+	RetentionPlantPolicies map[string]string `env:"RETENTION_PLANT_POLICIES"`
+	RetentionInterval      time.Duration     `env:"RETENTION_INTERVAL" envDefault:"1h"`
+
+	// ImageSchedule is when plant images are generated, an interval such as "5m" or a cron expression such as
+	// "0 6 * * *"
+	ImageSchedule string `env:"IMAGE_SCHEDULE" envDefault:"5m"`
+
+	// GrowthInterval is the interval at which every plant's state is brought up to date, 0 disables the growth tick
+	GrowthInterval time.Duration `env:"GROWTH_INTERVAL" envDefault:"1m"`
+
+	// ManifestDir is a directory of Plant manifests reconciled by the embedded controller, disabled when empty
+	ManifestDir          string        `env:"MANIFEST_DIR"`
+	ManifestSyncInterval time.Duration `env:"MANIFEST_SYNC_INTERVAL" envDefault:"10s"`
+
+	// SimulatedClock runs the garden on a simulated clock, which can be advanced and frozen with the admin clock
+	// endpoint. ClockAcceleration is how many simulated seconds pass per real second, any value other than 1 also
+	// simulates the clock
+	SimulatedClock    bool    `env:"SIMULATED_CLOCK"`
+	ClockAcceleration float64 `env:"CLOCK_ACCELERATION" envDefault:"1"`
 }
 
 // NewFromEnvironment reads Environment Variables and returns a pointer to a Config struct
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"github.com/williamnoble/kube-botany/pkg/types"
+	"time"
+)
+
+var (
+	// ErrInvalidResource is returned when a resource is malformed.
+	ErrInvalidResource = errors.New("invalid resource")
+
+	// ErrUnsupportedVariety is returned when a resource requests a variety the backend doesn't support.
+	ErrUnsupportedVariety = errors.New("unsupported variety")
+
+	// ErrConflict is returned when a resource is older than the plant, or changes the plant's variety.
+	ErrConflict = errors.New("conflict")
+
+	// errUnchanged stops Apply saving a plant which the resource doesn't change
+	errUnchanged = errors.New("unchanged")
+)
+
+// Result describes the outcome of applying a resource.
+type Result struct {
+	Plant   *plant.Plant
+	Created bool // the plant didn't exist and was created
+	Updated bool // the plant's spec or generation changed
+}
+
+// Apply idempotently applies a Plant resource to the plant with the given id, creating the plant if it doesn't
+// exist. The plant's generation tracks the resource's metadata.generation, a resource without a generation bumps
+// the plant's generation whenever it changes the spec. Applying a resource older than the plant's generation, or
+// one which changes the plant's variety, fails with ErrConflict.
+func Apply(store repository.PlantRepository, id string, resource types.PlantResource, now time.Time) (Result, error) {
+	if err := resource.Validate(id); err != nil {
+		return Result{}, fmt.Errorf("%w: %w", ErrInvalidResource, err)
+	}
+	spec, generation := resource.Spec, resource.Metadata.Generation
+
+	// the plant is changed under the store's lock, so that a concurrent watering or growth tick isn't lost
+	var (
+		before    types.PlantSpec
+		changed   bool
+		unchanged plant.Plant
+	)
+	p, err := store.UpdatePlant(id, func(p *plant.Plant) error {
+		if spec.Variety != p.Variety.Type {
+			return fmt.Errorf("%w: plant %s is a %s, its variety cannot be changed", ErrConflict, id, p.Variety.Type)
+		}
+		if generation != 0 && generation < p.Generation {
+			return fmt.Errorf("%w: generation %d is older than %d", ErrConflict, generation, p.Generation)
+		}
+
+		before = types.IntoPlantSpec(p)
+		changed = before != spec
+		if !changed && generation <= p.Generation {
+			unchanged = *p
+			return errUnchanged
+		}
+
+		p.FriendlyName = spec.FriendlyName
+		p.Motif = spec.Motif
+		if generation > 0 {
+			p.Generation = generation
+		} else {
+			p.Generation++
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, repository.ErrPlantNotFound):
+		return create(store, id, spec, generation, now)
+	case errors.Is(err, errUnchanged):
+		return Result{Plant: &unchanged}, nil
+	case err != nil:
+		return Result{}, err
+	}
+
+	if changed {
+		message := fmt.Sprintf("spec updated from %+v to %+v (generation %d)", before, spec, p.Generation)
+		if err := store.RecordEvent(plant.NewEvent(p.Id, plant.EventUpdated, now, message)); err != nil {
+			return Result{}, err
+		}
+	}
+	return Result{Plant: p, Updated: true}, nil
+}
+
+// create creates the plant declared by a resource. A plant created with the same id after Apply found none, e.g.
+// by a concurrent request, is left alone and ErrConflict returned.
+func create(store repository.PlantRepository, id string, spec types.PlantSpec, generation int64,
+	now time.Time) (Result, error) {
+	if _, err := store.Variety(spec.Variety); err != nil {
+		return Result{}, fmt.Errorf("%w: %s", ErrUnsupportedVariety, spec.Variety)
+	}
+
+	p, err := store.NewPlant(id, spec.FriendlyName, spec.Variety, now)
+	if errors.Is(err, repository.ErrPlantExists) {
+		return Result{}, fmt.Errorf("%w: plant %s was created concurrently", ErrConflict, id)
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create plant %s: %w", id, err)
+	}
+	if spec.Motif == p.Motif && generation <= p.Generation {
+		return Result{Plant: p, Created: true}, nil
+	}
+
+	// the rest of the spec is applied under the store's lock, so that it can't replace a concurrent change
+	p, err = store.UpdatePlant(id, func(p *plant.Plant) error {
+		p.Motif = spec.Motif
+		p.Generation = max(generation, p.Generation)
+		return nil
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create plant %s: %w", id, err)
+	}
+	return Result{Plant: p, Created: true}, nil
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"github.com/williamnoble/kube-botany/pkg/types"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// statusDir is the subdirectory of the manifest directory to which the status of each plant is written.
+const statusDir = "status"
+
+// Controller reconciles the plants in a PlantRepository with the Plant manifests in a directory, standing in for
+// kube-botany-operator so that the operator contract can be exercised without a cluster.
+//
+// Each reconcile creates or updates a plant for every manifest, using the same semantics as PUT /api/plants/{id},
+// and writes the plant's resource, with its status, to status/<id>.yaml under the manifest directory. The status
+// files record which plants the controller manages: when a manifest is removed, its plant and status file are
+// deleted. Plants created by other means are never deleted, although a manifest may adopt an existing plant.
+type Controller struct {
+	dir    string
+	store  repository.PlantRepository
+	clock  clock.Clock
+	logger *slog.Logger
+}
+
+// New returns a controller which reconciles store with the manifests in dir, at the times told by clk.
+func New(store repository.PlantRepository, dir string, clk clock.Clock, logger *slog.Logger) *Controller {
+	return &Controller{
+		dir:    dir,
+		store:  store,
+		clock:  clk,
+		logger: logger,
+	}
+}
+
+// Run reconciles once. It is run by the server's job scheduler.
+func (c *Controller) Run(ctx context.Context) error {
+	return c.Reconcile(ctx)
+}
+
+// Reconcile performs a single pass over the manifest directory. Failures are collected so that one bad manifest
+// doesn't prevent the others from being applied, the returned error joins them. Plants are only deleted when
+// every manifest was read, so that a manifest which fails to parse doesn't cause its plant to be deleted. The pass
+// stops once the context is done, before the next manifest is applied, and then deletes nothing.
+func (c *Controller) Reconcile(ctx context.Context) error {
+	manifests, readErr := readManifests(c.dir)
+	if manifests == nil && readErr != nil {
+		return readErr
+	}
+	errs := []error{readErr}
+
+	desired := make(map[string]bool)
+	for _, m := range manifests {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, fmt.Errorf("controller: reconcile cancelled: %w", err))...)
+		}
+		id := m.resource.Metadata.Name
+		if desired[id] {
+			errs = append(errs, fmt.Errorf("controller: plant %s is declared more than once, ignoring %s", id, m.path))
+			continue
+		}
+		desired[id] = true
+
+		if err := c.apply(m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return errors.Join(append(errs, fmt.Errorf("controller: reconcile cancelled: %w", err))...)
+	}
+	if readErr == nil {
+		errs = append(errs, c.prune(desired))
+	}
+	return errors.Join(errs...)
+}
+
+// apply applies a manifest and writes the plant's status.
+func (c *Controller) apply(m manifest) error {
+	id := m.resource.Metadata.Name
+	result, err := Apply(c.store, id, m.resource, c.clock.Now())
+	if err != nil {
+		return fmt.Errorf("controller: failed to apply %s: %w", m.path, err)
+	}
+
+	switch {
+	case result.Created:
+		c.logger.Info("created plant", "id", id, "manifest", m.path)
+	case result.Updated:
+		c.logger.Info("updated plant", "id", id, "manifest", m.path, "generation", result.Plant.Generation)
+	}
+
+	resource := types.IntoPlantResource(result.Plant)
+	resource.Metadata.Namespace = m.resource.Metadata.Namespace
+	return c.writeStatus(id, resource)
+}
+
+// prune deletes the plants which the controller manages but which no longer have a manifest.
+func (c *Controller) prune(desired map[string]bool) error {
+	managed, err := c.managedPlants()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, id := range managed {
+		if desired[id] {
+			continue
+		}
+
+		if _, err := c.store.GetPlant(id); err == nil {
+			if err := c.store.DeletePlant(id); err != nil {
+				errs = append(errs, fmt.Errorf("controller: failed to delete plant %s: %w", id, err))
+				continue
+			}
+			c.logger.Info("deleted plant", "id", id)
+		}
+
+		if err := os.Remove(c.statusPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("controller: failed to remove status of %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// managedPlants returns the ids of the plants for which a status file exists.
+func (c *Controller) managedPlants() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(c.dir, statusDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("controller: failed to read status directory: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".yaml"); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// writeStatus writes the plant's resource to its status file, the file is only rewritten when it changes.
+func (c *Controller) writeStatus(id string, resource types.PlantResource) error {
+	data, err := yaml.Marshal(resource)
+	if err != nil {
+		return fmt.Errorf("controller: failed to marshal status of %s: %w", id, err)
+	}
+
+	path := c.statusPath(id)
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("controller: failed to create status directory: %w", err)
+	}
+
+	// readers never see a partially written status
+	if err := fs.WriteFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("controller: failed to write status of %s: %w", id, err)
+	}
+	return nil
+}
+
+func (c *Controller) statusPath(id string) string {
+	return filepath.Join(c.dir, statusDir, id+".yaml")
+}
@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"github.com/williamnoble/kube-botany/pkg/types"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"testing"
+	"time"
+)
+
+func newTestController(t *testing.T) (*Controller, repository.PlantRepository, string) {
+	t.Helper()
+	store, err := repository.NewInMemoryStore(false, "../plant/varieties.json")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	return New(store, dir, clock.System{}, slog.New(slog.NewTextHandler(io.Discard, nil))), store, dir
+}
+
+func writeManifest(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func readStatus(t *testing.T, dir, id string) types.PlantResource {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, statusDir, id+".yaml"))
+	require.NoError(t, err)
+	var resource types.PlantResource
+	require.NoError(t, yaml.Unmarshal(data, &resource))
+	return resource
+}
+
+const bonsaiManifest = `apiVersion: botany.williamnoble.dev/v1alpha1
+kind: Plant
+metadata:
+  name: FooPlant
+  namespace: garden
+  labels:
+    app: kube-botany
+spec:
+  variety: bonsai
+  friendlyName: MyBonsai
+  motif: gopher
+`
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+	c, store, dir := newTestController(t)
+
+	// a plant which the controller doesn't manage
+	_, err := store.NewPlant("UnmanagedPlant", "MyOrchid", "orchid", time.Now())
+	require.NoError(t, err)
+
+	writeManifest(t, dir, "bonsai.yaml", bonsaiManifest)
+	writeManifest(t, dir, "sunflowers.yml", `kind: Plant
+metadata:
+  name: BarPlant
+spec:
+  variety: sunflower
+---
+kind: Plant
+metadata:
+  name: BazPlant
+spec:
+  variety: sunflower
+`)
+	writeManifest(t, dir, "cactus.json", `{"kind": "Plant", "metadata": {"name": "QuxPlant"}, "spec": {"variety": "cactus"}}`)
+	writeManifest(t, dir, "README.md", "not a manifest")
+
+	// plants are created and their status is written back
+	require.NoError(t, c.Reconcile(context.Background()))
+	assert.Len(t, store.ListAllPlants(), 5)
+	p, err := store.GetPlant("FooPlant")
+	require.NoError(t, err)
+	assert.Equal(t, "MyBonsai", p.FriendlyName)
+	assert.Equal(t, "gopher", p.Motif)
+
+	status := readStatus(t, dir, "FooPlant")
+	assert.Equal(t, "garden", status.Metadata.Namespace)
+	assert.Equal(t, "bonsai", status.Spec.Variety)
+	assert.Equal(t, int64(1), status.Status.ObservedGeneration)
+	assert.Equal(t, 50, status.Status.CurrentWaterLevel)
+	entries, err := os.ReadDir(filepath.Join(dir, statusDir))
+	require.NoError(t, err)
+	assert.Len(t, entries, 4, "no temporary files are left behind")
+
+	// reconciling is idempotent
+	require.NoError(t, c.Reconcile(context.Background()))
+	p, err = store.GetPlant("FooPlant")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), p.Generation)
+
+	// editing a manifest updates the plant, removing one deletes the plant
+	writeManifest(t, dir, "bonsai.yaml", bonsaiManifest+"\n---\n")
+	writeManifest(t, dir, "sunflowers.yml", `kind: Plant
+metadata:
+  name: BarPlant
+spec:
+  variety: sunflower
+  friendlyName: MySunflower
+`)
+	require.NoError(t, os.Remove(filepath.Join(dir, "cactus.json")))
+	require.NoError(t, c.Reconcile(context.Background()))
+
+	p, err = store.GetPlant("BarPlant")
+	require.NoError(t, err)
+	assert.Equal(t, "MySunflower", p.FriendlyName)
+	assert.Equal(t, int64(2), readStatus(t, dir, "BarPlant").Status.ObservedGeneration)
+	for _, id := range []string{"BazPlant", "QuxPlant"} {
+		_, err = store.GetPlant(id)
+		assert.Error(t, err, "plant %s should have been deleted", id)
+		assert.NoFileExists(t, filepath.Join(dir, statusDir, id+".yaml"))
+	}
+	_, err = store.GetPlant("UnmanagedPlant")
+	assert.NoError(t, err, "plants which aren't managed by the controller are kept")
+}
+
+func TestReconcileErrors(t *testing.T) {
+	t.Parallel()
+	c, store, dir := newTestController(t)
+
+	writeManifest(t, dir, "bonsai.yaml", bonsaiManifest)
+	require.NoError(t, c.Reconcile(context.Background()))
+
+	// a manifest which can't be parsed doesn't cause plants to be deleted, the remaining manifests are applied
+	writeManifest(t, dir, "bonsai.yaml", "kind: Plant\nmetadata: [")
+	writeManifest(t, dir, "fern.yaml", "kind: Plant\nmetadata:\n  name: FernPlant\nspec:\n  variety: fern\n")
+	writeManifest(t, dir, "orchid.yaml", "kind: Plant\nmetadata:\n  name: OrchidPlant\nspec:\n  variety: orchid\n")
+	writeManifest(t, dir, "escape.yaml", "kind: Plant\nmetadata:\n  name: ../escape\nspec:\n  variety: orchid\n")
+
+	err := c.Reconcile(context.Background())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrUnsupportedVariety)
+	assert.ErrorContains(t, err, "invalid metadata.name")
+	_, err = store.GetPlant("FooPlant")
+	assert.NoError(t, err)
+	_, err = store.GetPlant("OrchidPlant")
+	assert.NoError(t, err)
+
+	// a plant declared twice is applied once
+	writeManifest(t, dir, "bonsai.yaml", bonsaiManifest)
+	writeManifest(t, dir, "duplicate.yaml", bonsaiManifest)
+	require.NoError(t, os.Remove(filepath.Join(dir, "fern.yaml")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "escape.yaml")))
+	assert.ErrorContains(t, c.Reconcile(context.Background()), "declared more than once")
+}
+
+func TestReconcileCancelled(t *testing.T) {
+	t.Parallel()
+	c, store, dir := newTestController(t)
+	writeManifest(t, dir, "bonsai.yaml", bonsaiManifest)
+	require.NoError(t, c.Reconcile(context.Background()))
+
+	// a cancelled reconcile neither applies manifests nor deletes plants
+	require.NoError(t, os.Remove(filepath.Join(dir, "bonsai.yaml")))
+	writeManifest(t, dir, "fern.yaml", "kind: Plant\nmetadata:\n  name: FernPlant\nspec:\n  variety: fern\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.Reconcile(ctx), context.Canceled)
+	_, err := store.GetPlant("FooPlant")
+	assert.NoError(t, err)
+	_, err = store.GetPlant("FernPlant")
+	assert.ErrorIs(t, err, repository.ErrPlantNotFound)
+}
+
+func TestReconcileMissingDirectory(t *testing.T) {
+	t.Parallel()
+	c, _, dir := newTestController(t)
+	c.dir = filepath.Join(dir, "missing")
+	assert.Error(t, c.Reconcile(context.Background()))
+}
+
+// unavailableStore fails every read, as a store which is briefly unavailable would.
+type unavailableStore struct {
+	repository.PlantRepository
+}
+
+var errUnavailable = errors.New("database is locked")
+
+func (s unavailableStore) GetPlant(string) (*plant.Plant, error) {
+	return nil, errUnavailable
+}
+
+func (s unavailableStore) UpdatePlant(string, func(p *plant.Plant) error) (*plant.Plant, error) {
+	return nil, errUnavailable
+}
+
+// racingStore creates the plant, as a concurrent request would, between Apply finding no plant and creating it.
+type racingStore struct {
+	repository.PlantRepository
+}
+
+func (s racingStore) UpdatePlant(id string, update func(p *plant.Plant) error) (*plant.Plant, error) {
+	if _, err := s.PlantRepository.GetPlant(id); errors.Is(err, repository.ErrPlantNotFound) {
+		if _, err := s.NewPlant(id, "TheirBonsai", "bonsai", time.Now()); err != nil {
+			return nil, err
+		}
+		return nil, repository.ErrPlantNotFound
+	}
+	return s.PlantRepository.UpdatePlant(id, update)
+}
+
+func TestApplyConcurrentCreate(t *testing.T) {
+	t.Parallel()
+	_, store, _ := newTestController(t)
+	var resource types.PlantResource
+	require.NoError(t, yaml.Unmarshal([]byte(bonsaiManifest), &resource))
+
+	// the plant created concurrently is kept, rather than replaced
+	_, err := Apply(racingStore{store}, "FooPlant", resource, time.Now())
+	assert.ErrorIs(t, err, ErrConflict)
+	p, err := store.GetPlant("FooPlant")
+	require.NoError(t, err)
+	assert.Equal(t, "TheirBonsai", p.FriendlyName)
+	assert.Empty(t, p.Motif)
+}
+
+func TestApplyStoreError(t *testing.T) {
+	t.Parallel()
+	_, store, _ := newTestController(t)
+	var resource types.PlantResource
+	require.NoError(t, yaml.Unmarshal([]byte(bonsaiManifest), &resource))
+
+	// an error reading the plant is returned as is, rather than treating the plant as missing and creating it
+	_, err := Apply(unavailableStore{store}, "FooPlant", resource, time.Now())
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.NotErrorIs(t, err, ErrConflict)
+	_, err = store.GetPlant("FooPlant")
+	assert.ErrorIs(t, err, repository.ErrPlantNotFound)
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"slices"
+	"strings"
+)
+
+// manifestExtensions are the extensions of the files read from the manifest directory.
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// documentSeparator splits a YAML file into documents.
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// manifest is a Plant resource read from the manifest directory.
+type manifest struct {
+	path     string // the file the resource was read from
+	resource types.PlantResource
+}
+
+// readManifests reads the Plant resources from the YAML and JSON files in dir. A YAML file may contain several
+// documents, empty documents are skipped. Subdirectories are not read. Files which can't be read are reported
+// in the returned error, alongside the resources read from the remaining files.
+func readManifests(dir string) ([]manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("controller: failed to read manifest directory: %w", err)
+	}
+
+	var (
+		manifests []manifest
+		errs      []error
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(manifestExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		resources, err := readManifestFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, resource := range resources {
+			manifests = append(manifests, manifest{path: path, resource: resource})
+		}
+	}
+	return manifests, errors.Join(errs...)
+}
+
+// readManifestFile reads the Plant resources from a single file.
+func readManifestFile(path string) ([]types.PlantResource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("controller: failed to read manifest %s: %w", path, err)
+	}
+
+	var resources []types.PlantResource
+	for i, document := range documentSeparator.Split(string(data), -1) {
+		if strings.TrimSpace(document) == "" {
+			continue
+		}
+
+		var resource types.PlantResource
+		if err := yaml.Unmarshal([]byte(document), &resource); err != nil {
+			return nil, fmt.Errorf("controller: failed to parse manifest %s (document %d): %w", path, i+1, err)
+		}
+		name := resource.Metadata.Name
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("controller: manifest %s (document %d) has an invalid metadata.name %q", path, i+1, name)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
@@ -0,0 +1,207 @@
+package fs
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheStats reports how effective a CachedImageStore has been.
+type CacheStats struct {
+	Hits      uint64 // reads served from the cache
+	Misses    uint64 // reads passed through to the backing store
+	Evictions uint64 // images evicted to keep within the budget
+	Entries   int    // images currently cached
+	Bytes     int64  // size of the images currently cached
+}
+
+// CachedImageStore is an ImageStore which keeps recently used images in memory in front of a slower backing
+// store, such as a DiskImageStore or S3ImageStore. Reads of uncached images are passed through to the backing
+// store and the result cached, writes and deletes are applied to the backing store before the cache. The least
+// recently used images are evicted to keep the cache within its byte budget, images larger than the budget are
+// never cached.
+type CachedImageStore struct {
+	backing  ImageStore
+	maxBytes int64
+
+	writeMu sync.Mutex // serialises writes, so that the cache applies them in the same order as the backing store
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[cacheKey]*list.Element
+	bytes   int64
+	version uint64 // incremented by every write, so that a read which raced with a write isn't cached
+	stats   CacheStats
+}
+
+type cacheKey struct {
+	key      string
+	fileName string
+}
+
+type cacheEntry struct {
+	cacheKey
+	image ImageMetadata
+}
+
+// NewCachedImageStore returns an ImageStore which caches up to maxBytes of backing's images in memory.
+func NewCachedImageStore(backing ImageStore, maxBytes int64) *CachedImageStore {
+	return &CachedImageStore{
+		backing:  backing,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[cacheKey]*list.Element),
+	}
+}
+
+// Stats returns the cache's statistics.
+func (s *CachedImageStore) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Entries = s.lru.Len()
+	stats.Bytes = s.bytes
+	return stats
+}
+
+// GetImage returns the image from the cache, or from the backing store when it isn't cached.
+func (s *CachedImageStore) GetImage(key, fileName string) (*ImageMetadata, error) {
+	k := cacheKey{key: key, fileName: fileName}
+
+	s.mu.Lock()
+	if element, ok := s.entries[k]; ok {
+		s.lru.MoveToFront(element)
+		s.stats.Hits++
+		image := element.Value.(*cacheEntry).image
+		s.mu.Unlock()
+		return &image, nil
+	}
+	s.stats.Misses++
+	version := s.version
+	s.mu.Unlock()
+
+	// the backing store is read without holding the lock, so that a slow read doesn't block other readers
+	image, err := s.backing.GetImage(key, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.version == version {
+		s.add(k, *image)
+	}
+	return image, nil
+}
+
+// SaveImage saves the image in the backing store, then caches it.
+func (s *CachedImageStore) SaveImage(key string, fileName string, imageData []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	k := cacheKey{key: key, fileName: fileName}
+	err := s.backing.SaveImage(key, fileName, imageData)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.remove(k)
+	if err != nil {
+		return err
+	}
+	s.add(k, NewImageMetadata(fileName, imageData))
+	return nil
+}
+
+// DeleteImage deletes the image from the backing store and the cache.
+func (s *CachedImageStore) DeleteImage(key, fileName string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	err := s.backing.DeleteImage(key, fileName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.remove(cacheKey{key: key, fileName: fileName})
+	return err
+}
+
+// DeleteKey deletes all the images for the given key from the backing store and the cache.
+func (s *CachedImageStore) DeleteKey(key string) bool {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	deleted := s.backing.DeleteKey(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	for k := range s.entries {
+		if k.key == key {
+			s.remove(k)
+		}
+	}
+	return deleted
+}
+
+// GetImagesForKey returns all images for the given key from the backing store.
+func (s *CachedImageStore) GetImagesForKey(key string) ([]ImageMetadata, bool) {
+	return s.backing.GetImagesForKey(key)
+}
+
+// StatImages describes the images for the given key in the backing store.
+func (s *CachedImageStore) StatImages(key string) ([]ImageInfo, error) {
+	return s.backing.StatImages(key)
+}
+
+// List returns all keys in the backing store.
+func (s *CachedImageStore) List() []string {
+	return s.backing.List()
+}
+
+// CountByKey returns the number of images for the given key in the backing store.
+func (s *CachedImageStore) CountByKey(key string) int {
+	return s.backing.CountByKey(key)
+}
+
+// Clear clears the backing store and the cache.
+func (s *CachedImageStore) Clear() {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.backing.Clear()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.lru.Init()
+	clear(s.entries)
+	s.bytes = 0
+}
+
+// add caches an image, evicting the least recently used images to make room for it. The caller holds s.mu.
+func (s *CachedImageStore) add(k cacheKey, image ImageMetadata) {
+	size := int64(len(image.Data()))
+	if size > s.maxBytes {
+		return
+	}
+	s.remove(k)
+	for s.bytes+size > s.maxBytes {
+		oldest := s.lru.Back()
+		s.remove(oldest.Value.(*cacheEntry).cacheKey)
+		s.stats.Evictions++
+	}
+	s.entries[k] = s.lru.PushFront(&cacheEntry{cacheKey: k, image: image})
+	s.bytes += size
+}
+
+// remove drops an image from the cache, if it is cached. The caller holds s.mu.
+func (s *CachedImageStore) remove(k cacheKey) {
+	element, ok := s.entries[k]
+	if !ok {
+		return
+	}
+	s.lru.Remove(element)
+	delete(s.entries, k)
+	s.bytes -= int64(len(element.Value.(*cacheEntry).image.Data()))
+}
@@ -0,0 +1,136 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingStore counts the reads which reach the backing store, and fails writes when failWrites is set.
+type countingStore struct {
+	ImageStore
+	reads      atomic.Int32
+	failWrites atomic.Bool
+}
+
+func (s *countingStore) GetImage(key, fileName string) (*ImageMetadata, error) {
+	s.reads.Add(1)
+	return s.ImageStore.GetImage(key, fileName)
+}
+
+func (s *countingStore) SaveImage(key string, fileName string, imageData []byte) error {
+	if s.failWrites.Load() {
+		return errors.New("backing store unavailable")
+	}
+	return s.ImageStore.SaveImage(key, fileName, imageData)
+}
+
+func TestCachedImageStore(t *testing.T) {
+	t.Parallel()
+	testImageStore(t, NewCachedImageStore(NewInMemoryImageStore(), 1<<20))
+}
+
+func TestCachedImageStoreEviction(t *testing.T) {
+	t.Parallel()
+	backing := &countingStore{ImageStore: NewInMemoryImageStore()}
+	store := NewCachedImageStore(backing, 10)
+
+	// writes go through to the backing store and are cached
+	require.NoError(t, store.SaveImage("FooPlant", "1.png", []byte("aaaa")))
+	require.NoError(t, store.SaveImage("FooPlant", "2.png", []byte("bbbb")))
+	_, err := store.GetImage("FooPlant", "1.png")
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), backing.reads.Load())
+	assert.Equal(t, CacheStats{Hits: 1, Entries: 2, Bytes: 8}, store.Stats())
+
+	// the least recently used image is evicted to stay within the budget
+	require.NoError(t, store.SaveImage("FooPlant", "3.png", []byte("cccc")))
+	assert.Equal(t, CacheStats{Hits: 1, Evictions: 1, Entries: 2, Bytes: 8}, store.Stats())
+	_, err = store.GetImage("FooPlant", "1.png")
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), backing.reads.Load(), "1.png was used more recently than 2.png")
+
+	// evicted images are read through from the backing store, and cached again
+	img, err := store.GetImage("FooPlant", "2.png")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bbbb"), img.Data())
+	_, err = store.GetImage("FooPlant", "2.png")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), backing.reads.Load())
+	stats := store.Stats()
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(3), stats.Hits)
+
+	// images larger than the budget aren't cached
+	require.NoError(t, store.SaveImage("BarPlant", "big.png", []byte("larger than ten bytes")))
+	_, err = store.GetImage("BarPlant", "big.png")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), backing.reads.Load())
+	assert.LessOrEqual(t, store.Stats().Bytes, int64(10))
+
+	// misses aren't cached
+	_, err = store.GetImage("BarPlant", "missing.png")
+	assert.ErrorIs(t, err, ErrImageNotFound)
+	assert.Equal(t, 2, store.Stats().Entries)
+}
+
+func TestCachedImageStoreWrites(t *testing.T) {
+	t.Parallel()
+	backing := &countingStore{ImageStore: NewInMemoryImageStore()}
+	store := NewCachedImageStore(backing, 1<<20)
+
+	// a failed write leaves neither store holding the new image
+	require.NoError(t, store.SaveImage("FooPlant", "1.png", []byte("old")))
+	backing.failWrites.Store(true)
+	assert.Error(t, store.SaveImage("FooPlant", "1.png", []byte("new")))
+	backing.failWrites.Store(false)
+	img, err := store.GetImage("FooPlant", "1.png")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("old"), img.Data())
+
+	// deletes are applied to both stores
+	require.NoError(t, store.SaveImage("FooPlant", "2.png", []byte("two")))
+	require.NoError(t, store.DeleteImage("FooPlant", "2.png"))
+	_, err = store.GetImage("FooPlant", "2.png")
+	assert.ErrorIs(t, err, ErrImageNotFound)
+
+	assert.True(t, store.DeleteKey("FooPlant"))
+	_, err = store.GetImage("FooPlant", "1.png")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, 0, store.Stats().Entries)
+}
+
+func TestCachedImageStoreConcurrency(t *testing.T) {
+	t.Parallel()
+	store := NewCachedImageStore(NewInMemoryImageStore(), 64)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				fileName := fmt.Sprintf("%d.png", j%10)
+				data := []byte(fmt.Sprintf("image-%d-%d", i, j))
+				assert.NoError(t, store.SaveImage("FooPlant", fileName, data))
+				_, _ = store.GetImage("FooPlant", fileName)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// whatever the interleaving, the cache agrees with the backing store
+	assert.LessOrEqual(t, store.Stats().Bytes, int64(64))
+	for j := range 10 {
+		fileName := fmt.Sprintf("%d.png", j)
+		cached, err := store.GetImage("FooPlant", fileName)
+		require.NoError(t, err)
+		backing, err := store.backing.GetImage("FooPlant", fileName)
+		require.NoError(t, err)
+		assert.Equal(t, backing.Data(), cached.Data())
+	}
+}
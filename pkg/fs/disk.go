@@ -0,0 +1,354 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskImageStore is an ImageStore which keeps images on the local disk. Images are content addressed: each
+// distinct image is written once, as blobs/<aa>/<sha256>, however many keys or file names refer to it. Each key
+// has an index file, keys/<key>.json, mapping its file names to blobs. Blobs and index files are written
+// atomically, so a crash leaves either the old or the new version of each file, and blobs which are no longer
+// referenced by any index are removed.
+type DiskImageStore struct {
+	dir string
+
+	mu      sync.RWMutex
+	indexes map[string]diskIndex // by key, mirrors the index files
+	refs    map[string]int       // number of index entries referring to each blob
+}
+
+// diskIndex is the contents of a key's index file.
+type diskIndex struct {
+	Key    string                    `json:"key"`
+	Images map[string]diskIndexEntry `json:"images"` // by file name
+}
+
+type diskIndexEntry struct {
+	SHA256  string    `json:"sha256"`
+	Size    int       `json:"size"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// NewDiskImageStore returns an ImageStore which keeps its images under dir, creating it if necessary. Temporary
+// files and unreferenced blobs left behind by an earlier crash are removed.
+func NewDiskImageStore(dir string) (*DiskImageStore, error) {
+	s := &DiskImageStore{
+		dir:     dir,
+		indexes: make(map[string]diskIndex),
+		refs:    make(map[string]int),
+	}
+	for _, sub := range []string{s.blobsDir(), s.keysDir()} {
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			return nil, fmt.Errorf("fs: failed to create image directory: %w", err)
+		}
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.sweep(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetImage returns the image for the given key and file name.
+func (s *DiskImageStore) GetImage(key, fileName string) (*ImageMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, exists := s.indexes[key]
+	if !exists {
+		return nil, ErrKeyNotFound
+	}
+	entry, exists := index.Images[fileName]
+	if !exists {
+		return nil, ErrImageNotFound
+	}
+	data, err := s.readBlob(entry.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	image := NewImageMetadata(fileName, data)
+	return &image, nil
+}
+
+// SaveImage saves the image for the given key and file name, replacing any image with the same file name.
+func (s *DiskImageStore) SaveImage(key string, fileName string, imageData []byte) error {
+	if key == "" || fileName == "" {
+		return errors.New("fs: image key and file name are required")
+	}
+	digest := sha256.Sum256(imageData)
+	hash := hex.EncodeToString(digest[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newBlob := s.refs[hash] == 0
+	if newBlob {
+		if err := s.writeBlob(hash, imageData); err != nil {
+			return err
+		}
+	}
+
+	index := s.indexes[key]
+	previous, replaced := index.Images[fileName]
+	updated := diskIndex{Key: key, Images: maps.Clone(index.Images)}
+	if updated.Images == nil {
+		updated.Images = make(map[string]diskIndexEntry)
+	}
+	updated.Images[fileName] = diskIndexEntry{SHA256: hash, Size: len(imageData), SavedAt: time.Now().UTC()}
+	if err := s.writeIndex(updated); err != nil {
+		if newBlob {
+			_ = os.Remove(s.blobPath(hash))
+		}
+		return err
+	}
+
+	s.indexes[key] = updated
+	s.refs[hash]++
+	if replaced {
+		s.release(previous.SHA256)
+	}
+	return nil
+}
+
+// DeleteImage deletes the image for the given key and file name.
+func (s *DiskImageStore) DeleteImage(key, fileName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.indexes[key]
+	if !exists {
+		return ErrKeyNotFound
+	}
+	entry, exists := index.Images[fileName]
+	if !exists {
+		return ErrImageNotFound
+	}
+
+	updated := diskIndex{Key: key, Images: maps.Clone(index.Images)}
+	delete(updated.Images, fileName)
+	if err := s.writeIndex(updated); err != nil {
+		return err
+	}
+	if len(updated.Images) == 0 {
+		delete(s.indexes, key)
+	} else {
+		s.indexes[key] = updated
+	}
+	s.release(entry.SHA256)
+	return nil
+}
+
+// DeleteKey deletes all the images for the given key, returning true if there were any.
+func (s *DiskImageStore) DeleteKey(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleteKey(key) == nil
+}
+
+// GetImagesForKey returns all images for the given key, ordered by file name.
+func (s *DiskImageStore) GetImagesForKey(key string) ([]ImageMetadata, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, exists := s.indexes[key]
+	if !exists {
+		return nil, false
+	}
+	images := make([]ImageMetadata, 0, len(index.Images))
+	for _, fileName := range slices.Sorted(maps.Keys(index.Images)) {
+		data, err := s.readBlob(index.Images[fileName].SHA256)
+		if err != nil {
+			return nil, false
+		}
+		images = append(images, NewImageMetadata(fileName, data))
+	}
+	return images, true
+}
+
+// StatImages returns the name, size and save time of each image for the given key from its index, ordered by file
+// name. No blobs are read.
+func (s *DiskImageStore) StatImages(key string) ([]ImageInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, exists := s.indexes[key]
+	if !exists {
+		return nil, ErrKeyNotFound
+	}
+	infos := make([]ImageInfo, 0, len(index.Images))
+	for _, fileName := range slices.Sorted(maps.Keys(index.Images)) {
+		entry := index.Images[fileName]
+		infos = append(infos, ImageInfo{FileName: fileName, Size: int64(entry.Size), SavedAt: entry.SavedAt})
+	}
+	return infos, nil
+}
+
+// List returns all keys in the image store, in order.
+func (s *DiskImageStore) List() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Sorted(maps.Keys(s.indexes))
+}
+
+// CountByKey returns the number of images for the given key.
+func (s *DiskImageStore) CountByKey(key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.indexes[key].Images)
+}
+
+// Clear deletes every image.
+func (s *DiskImageStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.indexes {
+		_ = s.deleteKey(key)
+	}
+}
+
+func (s *DiskImageStore) deleteKey(key string) error {
+	index, exists := s.indexes[key]
+	if !exists {
+		return ErrKeyNotFound
+	}
+	if err := os.Remove(s.indexPath(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	delete(s.indexes, key)
+	for _, entry := range index.Images {
+		s.release(entry.SHA256)
+	}
+	return nil
+}
+
+// release drops a reference to a blob, removing the blob when nothing refers to it. A blob which can't be
+// removed is swept up when the store is next opened.
+func (s *DiskImageStore) release(hash string) {
+	s.refs[hash]--
+	if s.refs[hash] > 0 {
+		return
+	}
+	delete(s.refs, hash)
+	_ = os.Remove(s.blobPath(hash))
+}
+
+func (s *DiskImageStore) blobsDir() string {
+	return filepath.Join(s.dir, "blobs")
+}
+
+func (s *DiskImageStore) keysDir() string {
+	return filepath.Join(s.dir, "keys")
+}
+
+func (s *DiskImageStore) blobPath(hash string) string {
+	return filepath.Join(s.blobsDir(), hash[:2], hash)
+}
+
+// indexPath returns the path of the key's index file, the key is escaped so that it is a single path element.
+func (s *DiskImageStore) indexPath(key string) string {
+	return filepath.Join(s.keysDir(), url.PathEscape(key)+".json")
+}
+
+func (s *DiskImageStore) writeBlob(hash string, data []byte) error {
+	path := s.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("fs: failed to create blob directory: %w", err)
+	}
+	if err := WriteFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("fs: failed to write blob %s: %w", hash, err)
+	}
+	return nil
+}
+
+// readBlob reads a blob, verifying that its contents match its hash.
+func (s *DiskImageStore) readBlob(hash string) ([]byte, error) {
+	data, err := os.ReadFile(s.blobPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("fs: failed to read blob %s: %w", hash, err)
+	}
+	digest := sha256.Sum256(data)
+	if hex.EncodeToString(digest[:]) != hash {
+		return nil, fmt.Errorf("fs: blob %s is corrupt", hash)
+	}
+	return data, nil
+}
+
+// writeIndex replaces the key's index file, removing it when the key has no images.
+func (s *DiskImageStore) writeIndex(index diskIndex) error {
+	path := s.indexPath(index.Key)
+	if len(index.Images) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("fs: failed to remove index of %s: %w", index.Key, err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("fs: failed to marshal index of %s: %w", index.Key, err)
+	}
+	if err := WriteFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("fs: failed to write index of %s: %w", index.Key, err)
+	}
+	return nil
+}
+
+// load reads the index files and counts the references to each blob.
+func (s *DiskImageStore) load() error {
+	paths, err := filepath.Glob(filepath.Join(s.keysDir(), "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("fs: failed to read image index: %w", err)
+		}
+		var index diskIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return fmt.Errorf("fs: failed to decode image index %s: %w", path, err)
+		}
+		if len(index.Images) == 0 {
+			continue
+		}
+		s.indexes[index.Key] = index
+		for _, entry := range index.Images {
+			s.refs[entry.SHA256]++
+		}
+	}
+	return nil
+}
+
+// sweep removes temporary files and blobs which aren't referenced by any index.
+func (s *DiskImageStore) sweep() error {
+	return filepath.WalkDir(s.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		switch {
+		case strings.HasSuffix(name, ".tmp"):
+		case filepath.Dir(filepath.Dir(path)) == s.blobsDir() && s.refs[name] == 0:
+		default:
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("fs: failed to remove %s: %w", path, err)
+		}
+		return nil
+	})
+}
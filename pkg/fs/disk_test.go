@@ -0,0 +1,118 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func hashOf(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+func countBlobs(t *testing.T, dir string) int {
+	t.Helper()
+	blobs, err := filepath.Glob(filepath.Join(dir, "blobs", "*", "*"))
+	require.NoError(t, err)
+	return len(blobs)
+}
+
+func TestDiskImageStore(t *testing.T) {
+	t.Parallel()
+	store, err := NewDiskImageStore(t.TempDir())
+	require.NoError(t, err)
+	testImageStore(t, store)
+}
+
+func TestDiskImageStoreContentAddressing(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	store, err := NewDiskImageStore(dir)
+	require.NoError(t, err)
+
+	// identical images are stored once
+	require.NoError(t, store.SaveImage("FooPlant", "2025-01-01-FooPlant.png", []byte("placeholder")))
+	require.NoError(t, store.SaveImage("BarPlant", "2025-01-01-BarPlant.png", []byte("placeholder")))
+	require.NoError(t, store.SaveImage("BarPlant", "2025-01-02-BarPlant.png", []byte("bar")))
+	assert.Equal(t, 2, countBlobs(t, dir))
+
+	// a blob is removed once nothing refers to it
+	assert.True(t, store.DeleteKey("FooPlant"))
+	assert.Equal(t, 2, countBlobs(t, dir))
+	require.NoError(t, store.SaveImage("BarPlant", "2025-01-01-BarPlant.png", []byte("replaced")))
+	assert.Equal(t, 2, countBlobs(t, dir))
+	require.NoError(t, store.DeleteImage("BarPlant", "2025-01-02-BarPlant.png"))
+	assert.Equal(t, 1, countBlobs(t, dir))
+
+	// the images survive reopening the store
+	reopened, err := NewDiskImageStore(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"BarPlant"}, reopened.List())
+	img, err := reopened.GetImage("BarPlant", "2025-01-01-BarPlant.png")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("replaced"), img.Data())
+}
+
+func TestDiskImageStoreRecovery(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	store, err := NewDiskImageStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveImage("FooPlant", "2025-01-01-FooPlant.png", []byte("foo")))
+
+	// a crash may leave behind temporary files, and blobs which were written but never indexed
+	require.NoError(t, store.writeBlob(hashOf([]byte("orphan")), []byte("orphan")))
+	tmp := filepath.Join(dir, "keys", ".FooPlant.json.123.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte("{"), 0o644))
+	assert.Equal(t, 2, countBlobs(t, dir))
+
+	store, err = NewDiskImageStore(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, countBlobs(t, dir))
+	assert.NoFileExists(t, tmp)
+	assert.Equal(t, 1, store.CountByKey("FooPlant"))
+
+	// corrupt blobs are detected
+	require.NoError(t, os.WriteFile(store.blobPath(hashOf([]byte("foo"))), []byte("bar"), 0o644))
+	_, err = store.GetImage("FooPlant", "2025-01-01-FooPlant.png")
+	assert.ErrorContains(t, err, "corrupt")
+}
+
+func TestDiskImageStoreSaveError(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	store, err := NewDiskImageStore(dir)
+	require.NoError(t, err)
+
+	// a file in place of the blob's directory makes the write fail
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "blobs", hashOf([]byte("foo"))[:2]), nil, 0o644))
+
+	err = store.SaveImage("FooPlant", "2025-01-01-FooPlant.png", []byte("foo"))
+	assert.Error(t, err)
+	assert.Empty(t, store.List(), "a failed save leaves the store unchanged")
+	assert.Error(t, store.SaveImage("", "2025-01-01-FooPlant.png", []byte("foo")))
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "image.png")
+
+	require.NoError(t, WriteFileAtomic(name, []byte("first"), 0o644))
+	require.NoError(t, WriteFileAtomic(name, []byte("second"), 0o644))
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+
+	// no temporary files are left behind, even when the rename fails
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "directory"), 0o755))
+	assert.Error(t, WriteFileAtomic(filepath.Join(dir, "directory"), []byte("data"), 0o644))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
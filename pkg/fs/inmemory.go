@@ -2,7 +2,10 @@ package fs
 
 import (
 	"errors"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -12,10 +15,11 @@ var (
 
 type ImageStore interface {
 	GetImage(key, fileName string) (*ImageMetadata, error)
-	SaveImage(key string, fileName string, imageData []byte)
+	SaveImage(key string, fileName string, imageData []byte) error
 	DeleteImage(key, fileName string) error
 	DeleteKey(key string) bool
 	GetImagesForKey(key string) ([]ImageMetadata, bool)
+	StatImages(key string) ([]ImageInfo, error)
 	List() []string
 	CountByKey(key string) int
 	Clear()
@@ -26,15 +30,39 @@ type ImageMetadata struct {
 	image    []byte
 }
 
+// NewImageMetadata returns the metadata of an image, for use by ImageStore implementations.
+func NewImageMetadata(fileName string, image []byte) ImageMetadata {
+	return ImageMetadata{fileName: fileName, image: image}
+}
+
+// FileName returns the image's file name.
+func (m ImageMetadata) FileName() string {
+	return m.fileName
+}
+
+// Data returns the image's contents.
+func (m ImageMetadata) Data() []byte {
+	return m.image
+}
+
+// ImageInfo describes a stored image without its contents.
+type ImageInfo struct {
+	FileName string
+	Size     int64
+	SavedAt  time.Time // when the image was last saved
+}
+
 type InMemoryImageStore struct {
-	images map[string][]ImageMetadata
-	mu     sync.RWMutex
+	images  map[string][]ImageMetadata
+	savedAt map[string]map[string]time.Time // by key and file name
+	mu      sync.RWMutex
 }
 
 // NewInMemoryImageStore returns a new in-memory image store
 func NewInMemoryImageStore() ImageStore {
 	return &InMemoryImageStore{
-		images: make(map[string][]ImageMetadata),
+		images:  make(map[string][]ImageMetadata),
+		savedAt: make(map[string]map[string]time.Time),
 	}
 }
 
@@ -54,15 +82,26 @@ func (s *InMemoryImageStore) GetImage(key, fileName string) (*ImageMetadata, err
 	return nil, ErrImageNotFound
 }
 
-// SaveImage saves the image data for the given key and file name.
-func (s *InMemoryImageStore) SaveImage(key string, fileName string, imageData []byte) {
+// SaveImage saves the image data for the given key and file name, replacing any image with the same file name.
+func (s *InMemoryImageStore) SaveImage(key string, fileName string, imageData []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	image := ImageMetadata{
 		fileName: fileName,
 		image:    imageData,
 	}
+	if s.savedAt[key] == nil {
+		s.savedAt[key] = make(map[string]time.Time)
+	}
+	s.savedAt[key][fileName] = time.Now()
+	for i, img := range s.images[key] {
+		if img.fileName == fileName {
+			s.images[key][i] = image
+			return nil
+		}
+	}
 	s.images[key] = append(s.images[key], image)
+	return nil
 }
 
 // DeleteImage deletes the image data for the given key and file name.
@@ -77,8 +116,10 @@ func (s *InMemoryImageStore) DeleteImage(key, fileName string) error {
 	for i, img := range images {
 		if img.fileName == fileName {
 			s.images[key] = append(images[:i], images[i+1:]...)
+			delete(s.savedAt[key], fileName)
 			if len(s.images[key]) == 0 {
 				delete(s.images, key)
+				delete(s.savedAt, key)
 			}
 			return nil
 		}
@@ -94,18 +135,46 @@ func (s *InMemoryImageStore) DeleteKey(key string) bool {
 
 	if _, exists := s.images[key]; exists {
 		delete(s.images, key)
+		delete(s.savedAt, key)
 		return true
 	}
 	return false
 }
 
-// GetImagesForKey returns all images for the given key.
+// GetImagesForKey returns all images for the given key. The images are copied, as the store replaces and deletes
+// images in place.
 func (s *InMemoryImageStore) GetImagesForKey(key string) ([]ImageMetadata, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	images, exists := s.images[key]
-	return images, exists
+	return slices.Clone(images), exists
+}
+
+// StatImages returns the name, size and save time of each image for the given key, ordered by file name.
+func (s *InMemoryImageStore) StatImages(key string) ([]ImageInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	images, exists := s.images[key]
+	if !exists {
+		return nil, ErrKeyNotFound
+	}
+	infos := make([]ImageInfo, 0, len(images))
+	for _, img := range images {
+		infos = append(infos, ImageInfo{
+			FileName: img.fileName,
+			Size:     int64(len(img.image)),
+			SavedAt:  s.savedAt[key][img.fileName],
+		})
+	}
+	slices.SortFunc(infos, compareImageInfo)
+	return infos, nil
+}
+
+// compareImageInfo orders images by file name.
+func compareImageInfo(a, b ImageInfo) int {
+	return strings.Compare(a.FileName, b.FileName)
 }
 
 // List returns all keys in the image store.
@@ -136,4 +205,5 @@ func (s *InMemoryImageStore) Clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.images = make(map[string][]ImageMetadata)
+	s.savedAt = make(map[string]map[string]time.Time)
 }
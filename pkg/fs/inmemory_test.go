@@ -24,7 +24,7 @@ func TestInMemoryStore(t *testing.T) {
 	assert.Equal(t, len(store.List()), 0)
 
 	// save the image
-	store.SaveImage(testData.key, testData.fileName, testData.imageData)
+	assert.NoError(t, store.SaveImage(testData.key, testData.fileName, testData.imageData))
 	assert.Equal(t, len(store.List()), 1)
 
 	// get the image
@@ -54,9 +54,32 @@ func TestInMemoryStore(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, len(store.List()), 0)
 
+	// saving the image again replaces it
+	assert.NoError(t, store.SaveImage(testData.key, testData.fileName, testData.imageData))
+	assert.NoError(t, store.SaveImage(testData.key, testData.fileName, testData.imageData))
+	assert.Equal(t, store.CountByKey(testData.key), 1)
+
 	// test clean
-	store.SaveImage(testData.key, testData.fileName, testData.imageData)
 	store.Clear()
 	keys := store.List()
 	assert.Equal(t, len(keys), 0)
 }
+
+func TestInMemoryStoreImagesAreCopied(t *testing.T) {
+	t.Parallel()
+	store := NewInMemoryImageStore()
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		assert.NoError(t, store.SaveImage("key", name, []byte(name)))
+	}
+
+	images, ok := store.GetImagesForKey("key")
+	assert.True(t, ok)
+	assert.NoError(t, store.DeleteImage("key", "a.png"))
+	assert.NoError(t, store.SaveImage("key", "b.png", []byte("replaced")))
+	names := make([]string, 0, len(images))
+	for _, img := range images {
+		names = append(names, img.FileName())
+	}
+	assert.Equal(t, []string{"a.png", "b.png", "c.png"}, names, "the images read are unchanged by later writes")
+	assert.Equal(t, []byte("b.png"), images[1].Data())
+}
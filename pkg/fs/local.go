@@ -2,26 +2,62 @@ package fs
 
 import (
 	"fmt"
-	"io"
 	"os"
+	"path/filepath"
 )
 
+// CopyImage copies the image at srcFileName to dstFileName, which is replaced atomically.
 func CopyImage(srcFileName string, dstFileName string) error {
-	src, err := os.Open(srcFileName)
+	data, err := os.ReadFile(srcFileName)
 	if err != nil {
-		return fmt.Errorf("error opening source file: %w", err)
+		return fmt.Errorf("error reading source file: %w", err)
 	}
-	defer src.Close()
+	if err := WriteFileAtomic(dstFileName, data, 0o644); err != nil {
+		return fmt.Errorf("error copying file contents: %w", err)
+	}
+	return nil
+}
 
-	dst, err := os.Create(dstFileName)
+// WriteFileAtomic writes data to a temporary file in the same directory as name, syncs it and renames it over
+// name, so that readers see either the old or the new contents and never a partial write. The temporary file is
+// removed if any step fails.
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(name)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(name)+".*.tmp")
 	if err != nil {
-		return fmt.Errorf("error opening destinationImg file: %w", err)
+		return err
 	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
 
-	_, err = io.Copy(dst, src)
-	if err != nil {
-		return fmt.Errorf("error copying file contents: %w", err)
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
 	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
 
-	return nil
+// syncDir flushes a directory, making a rename within it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
@@ -0,0 +1,395 @@
+package fs
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxDeleteObjects is the largest number of objects S3 deletes in a single DeleteObjects request.
+const maxDeleteObjects = 1000
+
+// S3Config configures an S3ImageStore.
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.eu-west-2.amazonaws.com or http://localhost:9000 for MinIO
+	Region          string // used to sign requests, us-east-1 when empty
+	Bucket          string // the bucket must already exist
+	Prefix          string // prepended to every object key, e.g. "images/"
+	AccessKeyID     string
+	SecretAccessKey string
+	HTTPClient      *http.Client // a client with a 30 second timeout when nil
+}
+
+// S3ImageStore is an ImageStore backed by an S3-compatible object store, such as AWS S3 or MinIO. An image is
+// stored as the object <prefix><key>/<fileName>, buckets are addressed path-style (<endpoint>/<bucket>) so that
+// any endpoint works without DNS configuration. Requests are signed with AWS Signature Version 4.
+//
+// Where ImageStore methods don't report errors, failed requests are treated as missing images.
+type S3ImageStore struct {
+	endpoint *url.URL
+	cfg      S3Config
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3ImageStore returns an ImageStore which keeps images in the configured bucket.
+func NewS3ImageStore(cfg S3Config) (*S3ImageStore, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("fs: s3 endpoint and bucket are required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("fs: s3 access key id and secret access key are required")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("fs: invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &S3ImageStore{endpoint: endpoint, cfg: cfg, client: client, now: time.Now}, nil
+}
+
+// s3Error is the error document returned by S3 for a failed request.
+type s3Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("fs: s3 request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("fs: s3 request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func isNotFound(err error) bool {
+	var s3Err *s3Error
+	return errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusNotFound
+}
+
+// GetImage returns the image for the given key and file name.
+func (s *S3ImageStore) GetImage(key, fileName string) (*ImageMetadata, error) {
+	data, err := s.do(http.MethodGet, s.objectKey(key, fileName), nil, nil, nil)
+	if isNotFound(err) {
+		return nil, s.notFound(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	image := NewImageMetadata(fileName, data)
+	return &image, nil
+}
+
+// SaveImage uploads the image, replacing any image with the same key and file name.
+func (s *S3ImageStore) SaveImage(key string, fileName string, imageData []byte) error {
+	_, err := s.do(http.MethodPut, s.objectKey(key, fileName), nil, nil, imageData)
+	return err
+}
+
+// DeleteImage deletes the image for the given key and file name.
+func (s *S3ImageStore) DeleteImage(key, fileName string) error {
+	// S3 reports success when deleting a missing object, check it exists first
+	objectKey := s.objectKey(key, fileName)
+	if _, err := s.do(http.MethodHead, objectKey, nil, nil, nil); err != nil {
+		if isNotFound(err) {
+			return s.notFound(key)
+		}
+		return err
+	}
+	_, err := s.do(http.MethodDelete, objectKey, nil, nil, nil)
+	return err
+}
+
+// DeleteKey deletes all the images for the given key, returning true if there were any.
+func (s *S3ImageStore) DeleteKey(key string) bool {
+	objects, _, err := s.list(s.keyPrefix(key), "", 0)
+	if err != nil || len(objects) == 0 {
+		return false
+	}
+	return s.deleteObjects(objects) == nil
+}
+
+// GetImagesForKey returns all images for the given key, ordered by file name.
+func (s *S3ImageStore) GetImagesForKey(key string) ([]ImageMetadata, bool) {
+	prefix := s.keyPrefix(key)
+	objects, _, err := s.list(prefix, "", 0)
+	if err != nil || len(objects) == 0 {
+		return nil, false
+	}
+
+	images := make([]ImageMetadata, 0, len(objects))
+	for _, object := range objects {
+		data, err := s.do(http.MethodGet, object, nil, nil, nil)
+		if isNotFound(err) {
+			continue // deleted since it was listed
+		}
+		if err != nil {
+			return nil, false
+		}
+		images = append(images, NewImageMetadata(strings.TrimPrefix(object, prefix), data))
+	}
+	return images, len(images) > 0
+}
+
+// StatImages returns the name, size and modification time of each image for the given key from a listing of the
+// key's objects, ordered by file name. No objects are downloaded.
+func (s *S3ImageStore) StatImages(key string) ([]ImageInfo, error) {
+	prefix := s.keyPrefix(key)
+	objects, _, err := s.listObjects(prefix, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	infos := make([]ImageInfo, 0, len(objects))
+	for _, object := range objects {
+		infos = append(infos, ImageInfo{
+			FileName: strings.TrimPrefix(object.Key, prefix),
+			Size:     object.Size,
+			SavedAt:  object.LastModified,
+		})
+	}
+	return infos, nil
+}
+
+// List returns all keys in the image store.
+func (s *S3ImageStore) List() []string {
+	_, prefixes, err := s.list(s.cfg.Prefix, "/", 0)
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		keys = append(keys, strings.TrimSuffix(strings.TrimPrefix(prefix, s.cfg.Prefix), "/"))
+	}
+	return keys
+}
+
+// CountByKey returns the number of images for the given key.
+func (s *S3ImageStore) CountByKey(key string) int {
+	objects, _, err := s.list(s.keyPrefix(key), "", 0)
+	if err != nil {
+		return 0
+	}
+	return len(objects)
+}
+
+// Clear deletes every image under the configured prefix.
+func (s *S3ImageStore) Clear() {
+	objects, _, err := s.list(s.cfg.Prefix, "", 0)
+	if err != nil || len(objects) == 0 {
+		return
+	}
+	_ = s.deleteObjects(objects)
+}
+
+func (s *S3ImageStore) keyPrefix(key string) string {
+	return s.cfg.Prefix + key + "/"
+}
+
+func (s *S3ImageStore) objectKey(key, fileName string) string {
+	return s.keyPrefix(key) + fileName
+}
+
+// notFound distinguishes a missing image from a key without any images.
+func (s *S3ImageStore) notFound(key string) error {
+	objects, _, err := s.list(s.keyPrefix(key), "", 1)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return ErrKeyNotFound
+	}
+	return ErrImageNotFound
+}
+
+// s3Object is an object in a bucket listing.
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type listBucketResult struct {
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+	Contents              []s3Object `xml:"Contents"`
+	CommonPrefixes        []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+// list returns the keys of the objects under prefix, and the common prefixes when delimiter is set, following
+// continuation tokens until every object is listed or limit (when positive) is reached.
+func (s *S3ImageStore) list(prefix, delimiter string, limit int) ([]string, []string, error) {
+	objects, prefixes, err := s.listObjects(prefix, delimiter, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	return keys, prefixes, nil
+}
+
+// listObjects is list, returning the size and modification time of each object as well as its key. Objects are
+// ordered by key.
+func (s *S3ImageStore) listObjects(prefix, delimiter string, limit int) ([]s3Object, []string, error) {
+	var (
+		objects  []s3Object
+		prefixes []string
+		token    string
+	)
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if limit > 0 {
+			query.Set("max-keys", fmt.Sprint(limit))
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		body, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, nil, fmt.Errorf("fs: failed to decode s3 object listing: %w", err)
+		}
+		objects = append(objects, result.Contents...)
+		for _, p := range result.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" || (limit > 0 && len(objects) >= limit) {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	slices.SortFunc(objects, func(a, b s3Object) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return objects, prefixes, nil
+}
+
+type deleteRequest struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	Errors []struct {
+		Key     string `xml:"Key"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+}
+
+// deleteObjects deletes the objects with DeleteObjects requests, in batches of maxDeleteObjects.
+func (s *S3ImageStore) deleteObjects(objects []string) error {
+	for batch := range slices.Chunk(objects, maxDeleteObjects) {
+		req := deleteRequest{Quiet: true}
+		for _, object := range batch {
+			req.Objects = append(req.Objects, struct {
+				Key string `xml:"Key"`
+			}{Key: object})
+		}
+		body, err := xml.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("fs: failed to encode s3 delete request: %w", err)
+		}
+
+		// S3 requires a checksum of DeleteObjects requests
+		checksum := md5.Sum(body)
+		headers := http.Header{
+			"Content-Md5":  {base64.StdEncoding.EncodeToString(checksum[:])},
+			"Content-Type": {"application/xml"},
+		}
+		response, err := s.do(http.MethodPost, "", url.Values{"delete": {""}}, headers, body)
+		if err != nil {
+			return err
+		}
+
+		// a successful request may still fail to delete some objects
+		var result deleteResult
+		if err := xml.Unmarshal(response, &result); err != nil {
+			return fmt.Errorf("fs: failed to decode s3 delete response: %w", err)
+		}
+		if len(result.Errors) > 0 {
+			first := result.Errors[0]
+			return fmt.Errorf("fs: failed to delete %d s3 objects, %s: %s: %s",
+				len(result.Errors), first.Key, first.Code, first.Message)
+		}
+	}
+	return nil
+}
+
+// do sends a signed request for the object (or the bucket when object is empty) and returns the response body.
+// Responses other than 2xx are returned as an *s3Error.
+func (s *S3ImageStore) do(method, object string, query url.Values, headers http.Header, body []byte) ([]byte, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.cfg.Bucket
+	if object != "" {
+		u.Path += "/" + object
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("fs: failed to create s3 request: %w", err)
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = sha256Hex(body)
+	}
+	signV4(req, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, s.cfg.Region, payloadHash, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fs: s3 %s %s: %w", method, object, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fs: failed to read s3 response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		s3Err := &s3Error{StatusCode: resp.StatusCode}
+		_ = xml.Unmarshal(data, s3Err) // HEAD responses and some proxies have no error document
+		return nil, s3Err
+	}
+	return data, nil
+}
@@ -2,141 +2,309 @@ package gen
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
-	openai "github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
+	"github.com/williamnoble/kube-botany/pkg/clock"
 	"github.com/williamnoble/kube-botany/pkg/fs"
 	"github.com/williamnoble/kube-botany/pkg/plant"
 	"log/slog"
-	"os"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
-type ImageGeneratorFunction func(plant string) error
+// Options configures how an ImageGenerationService prompts and calls its provider.
+type Options struct {
+	Timeout      time.Duration  // limit on each attempt to generate an image, unlimited when zero
+	Retries      int            // number of times Generate retries a failed attempt, jobs aren't retried by Generate
+	RetryBackoff time.Duration  // delay before the first retry, doubled for each subsequent retry
+	Prompts      *PromptBuilder // renders the prompts, the embedded DefaultPromptVersion templates when nil
+	Backdrop     string         // backdrop of every image, e.g. "library"
+	Clock        clock.Clock    // source of the day of each image, the system clock when nil
+	Workers      int            // images generated at once by an ImageTask, DefaultWorkers when zero
+	RateLimit    RateLimit      // limit on calls to the provider, DefaultRateLimits of the provider when unset
+	Jobs         JobStore       // persists the queue of jobs, the queue is kept in memory when nil
+	MaxAttempts  int            // attempts after which a failing job is dead-lettered, DefaultMaxAttempts when zero
+	JobBackoff   time.Duration  // delay before a failed job is retried, doubled for each further failure
+	JobLease     time.Duration  // time after which an unfinished attempt is retried, DefaultJobLease when zero
+}
 
-type ImageGenerationService struct {
-	staticDir string
-	logger    *slog.Logger
-	generator ImageGeneratorFunction
+// DefaultWorkers is the number of images generated at once when Options doesn't set Workers.
+const DefaultWorkers = 4
+
+// ImageRecord is saved alongside each generated image, so that the image can be reproduced.
+type ImageRecord struct {
+	Image       string         `json:"image"`    // file name of the image
+	Provider    string         `json:"provider"` // the provider which generated the image
+	Prompt      Prompt         `json:"prompt"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Plant       plant.Snapshot `json:"plant"` // the plant's health when the image was generated
 }
 
-// NewImageGenerationService creates a new ImageGenerationService instance with the given staticDir and logger.
-func NewImageGenerationService(staticDir string, logger *slog.Logger) *ImageGenerationService {
-	s := ImageGenerationService{
-		staticDir: staticDir,
-		logger:    logger,
+// RecordFileName returns the file name of the ImageRecord saved alongside the image.
+func RecordFileName(image string) string {
+	return image + ".prompt.json"
+}
+
+// RecordImage returns the file name of the image which the ImageRecord named fileName describes.
+func RecordImage(fileName string) string {
+	return strings.TrimSuffix(fileName, ".prompt.json")
+}
+
+// IsRecord returns true when fileName is the name of an ImageRecord rather than of an image.
+func IsRecord(fileName string) bool {
+	return strings.HasSuffix(fileName, ".prompt.json")
+}
+
+// TaskError reports the plants whose images an ImageTask failed to generate.
+type TaskError struct {
+	Failed    map[string]error // the error of each plant whose image failed, by plant ID
+	Cancelled error            // the context's error, when the task stopped before every plant was attempted
+}
+
+func (e *TaskError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "gen: images of %d plants failed", len(e.Failed))
+	for _, id := range slices.Sorted(maps.Keys(e.Failed)) {
+		fmt.Fprintf(&b, "; %s: %v", id, e.Failed[id])
 	}
-	s.generator = s.GenerateImageOpenAI
-	return &s
+	if e.Cancelled != nil {
+		fmt.Fprintf(&b, "; task cancelled: %v", e.Cancelled)
+	}
+	return b.String()
 }
 
-// NewMockImageGenerationService creates a new ImageGenerationService instance with the given staticDir and logger.
-func NewMockImageGenerationService(
-	staticDir string,
-	logger *slog.Logger) *ImageGenerationService {
-	s := ImageGenerationService{
-		staticDir: staticDir,
-		logger:    logger,
+// Unwrap returns the errors of the failed plants, and the context's error when the task was cancelled.
+func (e *TaskError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed)+1)
+	for _, id := range slices.Sorted(maps.Keys(e.Failed)) {
+		errs = append(errs, e.Failed[id])
 	}
-	s.generator = s.GenerateMockImage
-	s.logger.With("component", "generator").Info("configured mock image generation service")
+	if e.Cancelled != nil {
+		errs = append(errs, e.Cancelled)
+	}
+	return errs
+}
 
-	return &s
+// ImageGenerationService generates a daily image for each plant using an ImageProvider and saves it in an
+// fs.ImageStore, keyed by plant ID. Images are generated by a bounded pool of workers, and calls to the provider
+// are rate limited.
+type ImageGenerationService struct {
+	provider ImageProvider
+	images   fs.ImageStore
+	logger   *slog.Logger
+	opts     Options
+	limiter  *tokenBucket // nil when calls to the provider are not limited
+
+	mu       sync.Mutex
+	inflight map[string]bool // IDs of the plants whose image is being generated
 }
 
-func (s *ImageGenerationService) ImageTask(plants map[string]*plant.Plant) error {
-	var errs []error
+// NewImageGenerationService creates a new ImageGenerationService which saves the images generated by provider
+// in images.
+func NewImageGenerationService(
+	provider ImageProvider,
+	images fs.ImageStore,
+	logger *slog.Logger,
+	opts Options) (*ImageGenerationService, error) {
+	if opts.Prompts == nil {
+		prompts, err := NewPromptBuilder(DefaultPromptVersion, "")
+		if err != nil {
+			return nil, err
+		}
+		opts.Prompts = prompts
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.System{}
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.Jobs == nil {
+		opts.Jobs = &memoryJobStore{}
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.JobBackoff <= 0 {
+		opts.JobBackoff = DefaultJobBackoff
+	}
+	if opts.JobLease <= 0 {
+		opts.JobLease = DefaultJobLease
+	}
+	if opts.RateLimit.PerMinute == 0 {
+		limit := DefaultRateLimits[provider.Kind()]
+		opts.RateLimit.PerMinute = limit.PerMinute
+		if opts.RateLimit.Burst == 0 {
+			opts.RateLimit.Burst = limit.Burst
+		}
+	}
 
-	for _, p := range plants {
-		plantImageName := p.Image()
-		plantImagePath := fmt.Sprintf("%s/images/%s", s.staticDir, plantImageName)
-		_, err := os.Stat(plantImagePath)
+	s := ImageGenerationService{
+		provider: provider,
+		images:   images,
+		logger:   logger.With("component", "generator", "provider", provider.Name()),
+		opts:     opts,
+		limiter:  newTokenBucket(opts.RateLimit),
+		inflight: make(map[string]bool),
+	}
+	return &s, nil
+}
 
-		if os.IsNotExist(err) {
-			s.logger.With("component", "generator").Info("generating missing image", "image", plantImageName)
-			err := s.generator(plantImageName)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("failed to generate image %s: %w", plantImageName, err))
-				continue
-			}
-			s.logger.With("component", "generator").Info("image generated successfully", "image", plantImageName)
-		} else if err != nil {
-			errs = append(errs, fmt.Errorf("failed to check image %s: %w", plantImageName, err))
+// ImageTask queues a job to generate today's image for every plant which doesn't have one yet, then runs the
+// jobs which are due, including the retries of earlier failures. Jobs are handed to the workers oldest first until
+// the context is done, and a job whose plant's image is already being generated, e.g. by an earlier task which is
+// still running, is left for the next task. The errors of every plant which failed are returned in a *TaskError.
+//
+// plants is a snapshot of the plants by ID, which the workers read at the same time as the store changes its own
+// plants, so it is passed by value rather than sharing the store's plants.
+func (s *ImageGenerationService) ImageTask(ctx context.Context, plants map[string]plant.Plant) error {
+	now := s.opts.Clock.Now()
+	taskErr := &TaskError{Failed: make(map[string]error)}
+	for _, id := range slices.Sorted(maps.Keys(plants)) {
+		p := plants[id]
+		if err := s.enqueue(&p, now); err != nil {
+			taskErr.Failed[id] = fmt.Errorf("failed to queue image: %w", err)
 		}
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("ImageTask encountered %d errors: %w", len(errs), errors.Join(errs...))
+	due, err := s.opts.Jobs.DueImageJobs(now)
+	if err != nil {
+		return fmt.Errorf("gen: failed to list due image jobs: %w", err)
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	work := make(chan plant.ImageJob)
+	for range min(s.opts.Workers, len(due)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range work {
+				var p *plant.Plant
+				if snapshot, ok := plants[job.PlantId]; ok {
+					p = &snapshot
+				}
+				if err := s.runJob(ctx, job, p); err != nil {
+					mu.Lock()
+					taskErr.Failed[job.PlantId] = err
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, job := range due {
+		select {
+		case work <- job:
+		case <-ctx.Done():
+			taskErr.Cancelled = ctx.Err()
+			break dispatch
+		}
 	}
+	close(work)
+	wg.Wait()
 
+	if len(taskErr.Failed) > 0 || taskErr.Cancelled != nil {
+		return taskErr
+	}
 	return nil
 }
 
-// GenerateImageOpenAI generates an image using OpenAI's ImageModelGPTImage1 model.
-func (s *ImageGenerationService) GenerateImageOpenAI(plant string) error {
-	client := openai.NewClient(
-		option.WithBaseURL("https://openrouter.ai/api/v1"))
+// claim marks the plant's image as being generated, it returns false when it already is.
+func (s *ImageGenerationService) claim(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inflight[id] {
+		return false
+	}
+	s.inflight[id] = true
+	return true
+}
 
-	ctx := context.Background()
-	prompt := "A robot ladybird in a forest of trees."
+// release marks the plant's image as no longer being generated.
+func (s *ImageGenerationService) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inflight, id)
+}
 
-	image, err := client.Images.Generate(ctx, openai.ImageGenerateParams{
-		Prompt:         prompt,
-		Model:          openai.ImageModelGPTImage1,
-		ResponseFormat: openai.ImageGenerateParamsResponseFormatB64JSON,
-		N:              openai.Int(1),
-	})
+// generateImage renders the plant's prompt, generates the image and saves it along with its ImageRecord.
+func (s *ImageGenerationService) generateImage(ctx context.Context, p *plant.Plant, fileName string) error {
+	prompt, err := s.opts.Prompts.Build(p, plant.Generator{Backdrop: s.opts.Backdrop, Mascot: p.Motif})
 	if err != nil {
-		return fmt.Errorf("failed to generate image: %w", err)
+		return err
 	}
 
-	imageBytes, err := base64.StdEncoding.DecodeString(image.Data[0].B64JSON)
+	// a single call is made, as a job is retried by the queue, each of its attempts shouldn't retry in turn
+	image, err := s.attempt(ctx, ImageRequest{Plant: p, Prompt: prompt.Text})
 	if err != nil {
-		return fmt.Errorf("failed to decode base64 image: %w", err)
-	}
-
-	dest := fmt.Sprintf("%s/images/%s", s.staticDir, plant)
-	s.logger.With("component", "generator").Info("writing image", "path", dest)
-
-	dir := fmt.Sprintf("%s/images", s.staticDir)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+		return err
 	}
 
-	err = os.WriteFile(dest, imageBytes, 0644)
+	record, err := json.Marshal(ImageRecord{
+		Image:       fileName,
+		Provider:    s.provider.Name(),
+		Prompt:      prompt,
+		GeneratedAt: s.opts.Clock.Now(),
+		Plant:       p.Snapshot(),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to write image file: %w", err)
+		return fmt.Errorf("failed to marshal image record: %w", err)
 	}
 
+	// the record is saved first, so that an image is never without its record
+	if err := s.images.SaveImage(p.Id, RecordFileName(fileName), record); err != nil {
+		return fmt.Errorf("failed to save image record: %w", err)
+	}
+	if err := s.images.SaveImage(p.Id, fileName, image); err != nil {
+		return fmt.Errorf("failed to save image: %w", err)
+	}
 	return nil
 }
 
-// GenerateMockImage uses a placeholder image to generate a mock image for a given plant.
-func (s *ImageGenerationService) GenerateMockImage(plant string) error {
-	plantName := strings.Split(plant, "-")[3]
-	srcFileName := fmt.Sprintf("%s/%s", s.staticDir, fmt.Sprintf("0001-01-01-%s", plantName))
-	dstFileName := fmt.Sprintf("%s/images/%s", s.staticDir, plant)
-	// just for local testing
-	err := fs.CopyImage(srcFileName, dstFileName)
-	return err
-}
-
-// GenerateText generates text using OpenAI's GPT-4o model.
-func GenerateText(prompt string) (string, error) {
-	client := openai.NewClient(
-		option.WithBaseURL("https://openrouter.ai/api/v1"))
+// Generate asks the provider for an image, each attempt is limited by the configured timeout and failed attempts
+// are retried with exponential backoff. Errors marked Permanent are not retried, and nothing is retried once ctx
+// is done. The jobs run by an ImageTask don't use Generate, they make a single attempt and are retried by the queue.
+func (s *ImageGenerationService) Generate(ctx context.Context, req ImageRequest) ([]byte, error) {
+	backoff := s.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		image, err := s.attempt(ctx, req)
+		if err == nil {
+			return image, nil
+		}
+		if attempt >= s.opts.Retries || IsPermanent(err) || ctx.Err() != nil {
+			return nil, err
+		}
 
-	chatCompletion, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(prompt),
-		},
-		Model: openai.ChatModelGPT4o,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to generate text: %w", err)
+		s.logger.Warn("image generation failed, retrying",
+			"plant", req.Plant.Id, "attempt", attempt+1, "backoff", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, errors.Join(err, ctx.Err())
+		}
+		backoff *= 2
 	}
+}
 
-	return chatCompletion.Choices[0].Message.Content, nil
+// attempt calls the provider once, after waiting for the rate limit. The wait doesn't count towards the timeout.
+func (s *ImageGenerationService) attempt(ctx context.Context, req ImageRequest) ([]byte, error) {
+	if s.limiter != nil {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+	return s.provider.GenerateImage(ctx, req)
 }
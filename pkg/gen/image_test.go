@@ -0,0 +1,474 @@
+package gen
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func testPlants() map[string]*plant.Plant {
+	now := time.Now()
+	variety := &plant.Variety{Type: "aloe_vera", GrowthRatePerDay: 6, WaterConsumptionUnitsPerDay: 2}
+	return map[string]*plant.Plant{
+		"FooPlant": {Id: "FooPlant", Variety: variety, CreationTime: now, LastUpdated: now, Health: plant.Health{Vitality: 100}},
+		"BarPlant": {Id: "BarPlant", Variety: variety, CreationTime: now, LastUpdated: now, Health: plant.Health{Vitality: 100}},
+	}
+}
+
+// snapshot copies plants, as the server does before it runs an ImageTask.
+func snapshot(plants map[string]*plant.Plant) map[string]plant.Plant {
+	copies := make(map[string]plant.Plant, len(plants))
+	for id, p := range plants {
+		copies[id] = *p
+	}
+	return copies
+}
+
+func newTestService(t *testing.T, provider ImageProvider, images fs.ImageStore, opts Options) *ImageGenerationService {
+	t.Helper()
+	svc, err := NewImageGenerationService(provider, images, testLogger, opts)
+	require.NoError(t, err)
+	return svc
+}
+
+// blockingProvider blocks until the request's context is done.
+type blockingProvider struct{}
+
+func (blockingProvider) Name() string { return "blocking" }
+func (blockingProvider) Kind() string { return "blocking" }
+func (blockingProvider) GenerateImage(ctx context.Context, _ ImageRequest) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestImageTask(t *testing.T) {
+	t.Parallel()
+	provider := NewMockProvider([]byte("fake-image"))
+	images := fs.NewInMemoryImageStore()
+	svc := newTestService(t, provider, images, Options{Backdrop: "library"})
+
+	plants := testPlants()
+	require.NoError(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	requests := provider.Requests()
+	require.Len(t, requests, 2)
+	for id, p := range plants {
+		img, err := images.GetImage(id, p.Image())
+		require.NoError(t, err)
+		assert.Equal(t, []byte("fake-image"), img.Data())
+
+		// the prompt is saved alongside the image
+		data, err := images.GetImage(id, RecordFileName(p.Image()))
+		require.NoError(t, err)
+		var record ImageRecord
+		require.NoError(t, json.Unmarshal(data.Data(), &record))
+		assert.Equal(t, p.Image(), record.Image)
+		assert.Equal(t, ProviderMock, record.Provider)
+		assert.Contains(t, []string{requests[0].Prompt, requests[1].Prompt}, record.Prompt.Text)
+		assert.Contains(t, record.Prompt.Text, "aloe vera")
+		assert.Contains(t, record.Prompt.Text, "library")
+		assert.Equal(t, plant.Seeding.String(), record.Plant.GrowthStage)
+	}
+
+	// images are only generated once a day
+	require.NoError(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	assert.Len(t, provider.Requests(), 2)
+}
+
+func TestGenerateRetries(t *testing.T) {
+	t.Parallel()
+	req := ImageRequest{Plant: testPlants()["FooPlant"]}
+
+	// transient failures are retried
+	provider := NewMockProvider(nil)
+	provider.FailWith(errors.New("transient"), errors.New("transient"))
+	svc := newTestService(t, provider, fs.NewInMemoryImageStore(),
+		Options{Retries: 2, RetryBackoff: time.Millisecond})
+	_, err := svc.Generate(context.Background(), req)
+	require.NoError(t, err)
+	assert.Len(t, provider.Requests(), 3)
+
+	// until the retries are exhausted
+	provider.FailWith(errors.New("transient"), errors.New("transient"), errors.New("transient"))
+	_, err = svc.Generate(context.Background(), req)
+	assert.Error(t, err)
+	assert.Len(t, provider.Requests(), 6)
+
+	// permanent failures are not retried
+	provider = NewMockProvider(nil)
+	provider.FailWith(Permanent(errors.New("unauthorised")))
+	svc = newTestService(t, provider, fs.NewInMemoryImageStore(),
+		Options{Retries: 2, RetryBackoff: time.Millisecond})
+	_, err = svc.Generate(context.Background(), req)
+	assert.True(t, IsPermanent(err))
+	assert.Len(t, provider.Requests(), 1)
+}
+
+func TestGenerateTimeout(t *testing.T) {
+	t.Parallel()
+	req := ImageRequest{Plant: testPlants()["FooPlant"]}
+
+	// each attempt is limited by the timeout
+	svc := newTestService(t, blockingProvider{}, fs.NewInMemoryImageStore(),
+		Options{Timeout: 10 * time.Millisecond, Retries: 1, RetryBackoff: time.Millisecond})
+	_, err := svc.Generate(context.Background(), req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// cancelling the context stops retries
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	svc = newTestService(t, NewMockProvider(nil), fs.NewInMemoryImageStore(),
+		Options{Retries: 5, RetryBackoff: time.Hour})
+	_, err = svc.Generate(ctx, req)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, "/images/generations", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["prompt"] == "forbidden" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": {"message": "invalid api key"}}`))
+			return
+		}
+		assert.Equal(t, "dall-e-3", body["model"])
+		assert.Equal(t, "b64_json", body["response_format"])
+
+		w.Header().Set("Content-Type", "application/json")
+		encoded := base64.StdEncoding.EncodeToString([]byte("fake-image"))
+		_, _ = w.Write([]byte(`{"created": 1, "data": [{"b64_json": "` + encoded + `"}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIProvider("test-key", server.URL, "dall-e-3")
+	require.NoError(t, err)
+
+	image, err := provider.GenerateImage(context.Background(), ImageRequest{Prompt: "a bonsai"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("fake-image"), image)
+
+	// client errors are permanent
+	_, err = provider.GenerateImage(context.Background(), ImageRequest{Prompt: "forbidden"})
+	assert.True(t, IsPermanent(err))
+	assert.Equal(t, int32(2), calls.Load())
+
+	_, err = NewOpenAIProvider("", server.URL, "dall-e-3")
+	assert.Error(t, err)
+}
+
+func TestLocalProvider(t *testing.T) {
+	t.Parallel()
+	provider := NewLocalProvider("../static")
+	plants := testPlants()
+
+	// plants without their own placeholder get the fallback image
+	image, err := provider.GenerateImage(context.Background(), ImageRequest{Plant: plants["FooPlant"]})
+	require.NoError(t, err)
+	assert.NotEmpty(t, image)
+
+	_, err = NewLocalProvider(t.TempDir()).GenerateImage(context.Background(), ImageRequest{Plant: plants["FooPlant"]})
+	assert.True(t, IsPermanent(err))
+}
+
+func TestProceduralProvider(t *testing.T) {
+	t.Parallel()
+	provider := NewProceduralProvider(32)
+	plants := testPlants()
+
+	image, err := provider.GenerateImage(context.Background(), ImageRequest{Plant: plants["FooPlant"]})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(image), "\x89PNG"), "expected a PNG")
+
+	_, err = provider.GenerateImage(context.Background(), ImageRequest{})
+	assert.True(t, IsPermanent(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = provider.GenerateImage(ctx, ImageRequest{Plant: plants["FooPlant"]})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// gatedProvider signals the start of each request, blocks it until it is released, and records the most requests
+// in flight at once.
+type gatedProvider struct {
+	started  chan struct{}
+	release  chan struct{}
+	inflight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (p *gatedProvider) Name() string { return "gated" }
+func (p *gatedProvider) Kind() string { return "gated" }
+func (p *gatedProvider) GenerateImage(ctx context.Context, _ ImageRequest) ([]byte, error) {
+	n := p.inflight.Add(1)
+	defer p.inflight.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	select {
+	case p.started <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case <-p.release:
+		return placeholderPNG(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestImageTaskWorkers(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	variety := &plant.Variety{Type: "aloe_vera", GrowthRatePerDay: 6, WaterConsumptionUnitsPerDay: 2}
+	plants := make(map[string]*plant.Plant)
+	for _, id := range []string{"A", "B", "C", "D", "E"} {
+		plants[id] = &plant.Plant{Id: id, Variety: variety, CreationTime: now, LastUpdated: now}
+	}
+
+	provider := &gatedProvider{started: make(chan struct{}), release: make(chan struct{})}
+	images := fs.NewInMemoryImageStore()
+	svc := newTestService(t, provider, images, Options{Workers: 2})
+	done := make(chan error)
+	go func() { done <- svc.ImageTask(context.Background(), snapshot(plants)) }()
+
+	// both workers are generating before either is released, then each release lets the next request start
+	<-provider.started
+	<-provider.started
+	assert.Equal(t, int32(2), provider.peak.Load(), "the workers generate at once")
+	for i := range len(plants) {
+		provider.release <- struct{}{}
+		if i < len(plants)-2 {
+			<-provider.started
+		}
+	}
+	require.NoError(t, <-done)
+	assert.LessOrEqual(t, provider.peak.Load(), int32(2), "no more than the workers generate at once")
+	for id, p := range plants {
+		_, err := images.GetImage(id, p.Image())
+		assert.NoError(t, err, id)
+	}
+}
+
+func TestImageTaskErrors(t *testing.T) {
+	t.Parallel()
+
+	// the error of every failed plant is reported
+	provider := NewMockProvider(nil)
+	provider.FailWith(Permanent(errors.New("unauthorised")), Permanent(errors.New("unauthorised")))
+	svc := newTestService(t, provider, fs.NewInMemoryImageStore(), Options{Workers: 1})
+	err := svc.ImageTask(context.Background(), snapshot(testPlants()))
+	var taskErr *TaskError
+	require.ErrorAs(t, err, &taskErr)
+	assert.Len(t, taskErr.Failed, 2)
+	assert.Nil(t, taskErr.Cancelled)
+	assert.True(t, IsPermanent(err))
+	assert.Contains(t, err.Error(), "BarPlant: failed to generate image")
+
+	// plants whose image is already being generated are skipped
+	provider = NewMockProvider(nil)
+	svc = newTestService(t, provider, fs.NewInMemoryImageStore(), Options{})
+	require.True(t, svc.claim("FooPlant"))
+	require.NoError(t, svc.ImageTask(context.Background(), snapshot(testPlants())))
+	require.Len(t, provider.Requests(), 1)
+	assert.Equal(t, "BarPlant", provider.Requests()[0].Plant.Id)
+
+	// the task stops once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	provider = NewMockProvider(nil)
+	svc = newTestService(t, provider, fs.NewInMemoryImageStore(), Options{})
+	err = svc.ImageTask(ctx, snapshot(testPlants()))
+	require.ErrorAs(t, err, &taskErr)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestImageJobQueue(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFrozen(start)
+	provider := NewMockProvider(nil)
+	jobs := &memoryJobStore{}
+	svc := newTestService(t, provider, fs.NewInMemoryImageStore(),
+		Options{Clock: clk, Jobs: jobs, MaxAttempts: 3, JobBackoff: time.Minute})
+	plants := map[string]*plant.Plant{"FooPlant": testPlants()["FooPlant"]}
+	// job returns the plant's newest job
+	job := func() plant.ImageJob {
+		list, err := jobs.ListImageJobs("FooPlant")
+		require.NoError(t, err)
+		require.NotEmpty(t, list)
+		return list[0]
+	}
+
+	// a failed job is retried after its backoff
+	provider.FailWith(errors.New("transient"))
+	assert.Error(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	assert.Equal(t, plant.ImageJobFailed, job().State)
+	assert.Equal(t, "transient", job().LastError)
+	assert.Equal(t, start.Add(time.Minute), job().NextAttempt)
+	require.NoError(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	assert.Len(t, provider.Requests(), 1, "the job isn't retried before its backoff")
+
+	// the backoff doubles, then the job is dead-lettered once its attempts are exhausted
+	provider.FailWith(errors.New("transient"), errors.New("transient"))
+	require.NoError(t, clk.Advance(time.Minute))
+	assert.Error(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	assert.Equal(t, clk.Now().Add(2*time.Minute), job().NextAttempt)
+	require.NoError(t, clk.Advance(2*time.Minute))
+	assert.Error(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	assert.Equal(t, plant.ImageJobDead, job().State)
+	assert.Equal(t, 3, job().Attempts)
+	require.NoError(t, clk.Advance(time.Hour))
+	require.NoError(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	assert.Len(t, provider.Requests(), 3, "a dead job isn't retried")
+
+	// the next day's image has a job of its own, which succeeds
+	require.NoError(t, clk.Advance(24*time.Hour))
+	require.NoError(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	assert.Equal(t, plant.ImageJobSucceeded, job().State)
+	assert.Equal(t, plants["FooPlant"].ImageOn(clk.Now()), job().Image)
+	assert.Empty(t, job().LastError)
+
+	// a permanent failure is dead-lettered at once
+	provider.FailWith(Permanent(errors.New("unauthorised")))
+	require.NoError(t, clk.Advance(24*time.Hour))
+	assert.Error(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	assert.Equal(t, plant.ImageJobDead, job().State)
+	assert.Equal(t, 1, job().Attempts)
+}
+
+func TestImageJobAttempts(t *testing.T) {
+	t.Parallel()
+	provider := NewMockProvider(nil)
+	svc := newTestService(t, provider, fs.NewInMemoryImageStore(),
+		Options{Retries: 3, RetryBackoff: time.Millisecond})
+
+	// each attempt at a job calls the provider once, the job's retries being left to the queue
+	plants := map[string]*plant.Plant{"FooPlant": testPlants()["FooPlant"]}
+	provider.FailWith(errors.New("transient"))
+	assert.Error(t, svc.ImageTask(context.Background(), snapshot(plants)))
+	assert.Len(t, provider.Requests(), 1)
+}
+
+func TestImageJobClaim(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFrozen(start)
+	provider := NewMockProvider(nil)
+	jobs := &memoryJobStore{}
+	svc := newTestService(t, provider, fs.NewInMemoryImageStore(),
+		Options{Clock: clk, Jobs: jobs, JobLease: time.Hour})
+	p := testPlants()["FooPlant"]
+	job := plant.NewImageJob(p.Id, p.ImageOn(start), DefaultMaxAttempts, start)
+	require.NoError(t, jobs.SaveImageJob(&job))
+
+	// another replica claims the job, so the stale copy read before its claim is skipped
+	stale := job
+	claimed, err := jobs.ClaimImageJob(&job, start, time.Hour)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	require.NoError(t, svc.runJob(context.Background(), stale, p))
+	assert.Empty(t, provider.Requests())
+
+	// the running job is left alone until its lease expires, when it is retried
+	require.NoError(t, clk.Advance(30*time.Minute))
+	require.NoError(t, svc.ImageTask(context.Background(), snapshot(map[string]*plant.Plant{p.Id: p})))
+	assert.Empty(t, provider.Requests())
+	require.NoError(t, clk.Advance(30*time.Minute))
+	require.NoError(t, svc.ImageTask(context.Background(), snapshot(map[string]*plant.Plant{p.Id: p})))
+	assert.Len(t, provider.Requests(), 1)
+	list, err := jobs.ListImageJobs(p.Id)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, plant.ImageJobSucceeded, list[0].State)
+	assert.Equal(t, 2, list[0].Attempts)
+}
+
+// unavailableJobs is a JobStore whose plants can't be looked up.
+type unavailableJobs struct {
+	JobStore
+}
+
+func (unavailableJobs) GetPlant(string) (*plant.Plant, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestImageJobPlantLookup(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	store, err := repository.NewInMemoryStore(false, "../plant/varieties.json")
+	require.NoError(t, err)
+	provider := NewMockProvider(nil)
+	opts := Options{Clock: clock.NewFrozen(now), Jobs: store}
+	svc := newTestService(t, provider, fs.NewInMemoryImageStore(), opts)
+	// job returns the plant's newest job
+	job := func(id string) plant.ImageJob {
+		list, err := store.ListImageJobs(id)
+		require.NoError(t, err)
+		require.NotEmpty(t, list)
+		return list[0]
+	}
+
+	// the plant was created after the task's snapshot, so it isn't in it
+	p, err := store.NewPlant("FooPlant", "Foo", "aloe_vera", now)
+	require.NoError(t, err)
+	queued := plant.NewImageJob(p.Id, p.ImageOn(now), DefaultMaxAttempts, now)
+	require.NoError(t, store.SaveImageJob(&queued))
+
+	// the job is left pending when the plant can't be looked up
+	opts.Jobs = unavailableJobs{store}
+	unavailable := newTestService(t, provider, fs.NewInMemoryImageStore(), opts)
+	assert.Error(t, unavailable.ImageTask(context.Background(), map[string]plant.Plant{}))
+	assert.Equal(t, plant.ImageJobPending, job(p.Id).State)
+	assert.Zero(t, job(p.Id).Attempts)
+	assert.Empty(t, provider.Requests())
+
+	// otherwise the plant is looked up and its image generated
+	require.NoError(t, svc.ImageTask(context.Background(), map[string]plant.Plant{}))
+	assert.Equal(t, plant.ImageJobSucceeded, job(p.Id).State)
+	assert.Len(t, provider.Requests(), 1)
+
+	// the job of a plant which doesn't exist is dead-lettered
+	queued = plant.NewImageJob("BarPlant", "2025-01-01-BarPlant.png", DefaultMaxAttempts, now)
+	require.NoError(t, store.SaveImageJob(&queued))
+	require.NoError(t, svc.ImageTask(context.Background(), map[string]plant.Plant{}))
+	assert.Equal(t, plant.ImageJobDead, job("BarPlant").State)
+	assert.Equal(t, "plant not found", job("BarPlant").LastError)
+	assert.Len(t, provider.Requests(), 1)
+}
+
+func TestJobBackoff(t *testing.T) {
+	t.Parallel()
+	svc := newTestService(t, NewMockProvider(nil), fs.NewInMemoryImageStore(), Options{JobBackoff: time.Minute})
+	assert.Equal(t, time.Minute, svc.jobBackoff(1))
+	assert.Equal(t, 8*time.Minute, svc.jobBackoff(4))
+	assert.Equal(t, maxJobBackoff, svc.jobBackoff(100))
+}
@@ -0,0 +1,48 @@
+package gen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// fallbackImage is the placeholder used by the local provider for plants without their own placeholder.
+const fallbackImage = "botany.png"
+
+// LocalProvider stands in for a real provider when no API key is configured. It serves the placeholder image
+// 0001-01-01-<plant id>.png from its directory, falling back to botany.png.
+type LocalProvider struct {
+	dir string
+}
+
+// NewLocalProvider returns a provider serving placeholder images from dir.
+func NewLocalProvider(dir string) *LocalProvider {
+	return &LocalProvider{dir: dir}
+}
+
+func (p *LocalProvider) Name() string {
+	return ProviderLocal
+}
+
+func (p *LocalProvider) Kind() string {
+	return ProviderLocal
+}
+
+func (p *LocalProvider) GenerateImage(ctx context.Context, req ImageRequest) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{fmt.Sprintf("0001-01-01-%s.png", req.Plant.Id), fallbackImage} {
+		image, err := os.ReadFile(filepath.Join(p.dir, name))
+		if err == nil {
+			return image, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read placeholder image: %w", err)
+		}
+	}
+	return nil, Permanent(fmt.Errorf("no placeholder image for %s in %s", req.Plant.Id, p.dir))
+}
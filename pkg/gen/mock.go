@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"slices"
+	"sync"
+)
+
+// MockProvider returns a fixed image and records the requests it receives, for tests.
+type MockProvider struct {
+	image []byte
+
+	mu       sync.Mutex
+	requests []ImageRequest
+	errs     []error // returned by successive calls before the image is returned
+}
+
+// NewMockProvider returns a provider which always returns image, a 1x1 PNG is used when image is nil.
+func NewMockProvider(image []byte) *MockProvider {
+	if image == nil {
+		image = placeholderPNG()
+	}
+	return &MockProvider{image: image}
+}
+
+// FailWith makes the next len(errs) calls fail with the given errors, in order.
+func (p *MockProvider) FailWith(errs ...error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errs = append(p.errs, errs...)
+}
+
+// Requests returns the requests received so far.
+func (p *MockProvider) Requests() []ImageRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.requests)
+}
+
+func (p *MockProvider) Name() string {
+	return ProviderMock
+}
+
+func (p *MockProvider) Kind() string {
+	return ProviderMock
+}
+
+func (p *MockProvider) GenerateImage(ctx context.Context, req ImageRequest) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return p.image, nil
+}
+
+// placeholderPNG returns a 1x1 green PNG.
+func placeholderPNG() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{G: 0xa0, A: 0xff})
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
@@ -0,0 +1,85 @@
+package gen
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	openai "github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider generates images using an OpenAI-compatible images API.
+type OpenAIProvider struct {
+	client openai.Client
+	model  string
+}
+
+// NewOpenAIProvider returns a provider using the images API at baseURL. Retries are left to the caller, the
+// client doesn't retry failed requests itself.
+func NewOpenAIProvider(apiKey, baseURL, model string) (*OpenAIProvider, error) {
+	if apiKey == "" {
+		return nil, errors.New("gen: the openai image provider requires an API key")
+	}
+	if model == "" {
+		model = openai.ImageModelGPTImage1
+	}
+
+	opts := []option.RequestOption{option.WithAPIKey(apiKey), option.WithMaxRetries(0)}
+	if baseURL != "" {
+		opts = append(opts, option.WithBaseURL(baseURL))
+	}
+
+	return &OpenAIProvider{
+		client: openai.NewClient(opts...),
+		model:  model,
+	}, nil
+}
+
+func (p *OpenAIProvider) Name() string {
+	return fmt.Sprintf("%s (%s)", ProviderOpenAI, p.model)
+}
+
+func (p *OpenAIProvider) Kind() string {
+	return ProviderOpenAI
+}
+
+// GenerateImage requests a single base64 encoded image.
+func (p *OpenAIProvider) GenerateImage(ctx context.Context, req ImageRequest) ([]byte, error) {
+	params := openai.ImageGenerateParams{
+		Prompt: req.Prompt,
+		Model:  p.model,
+		N:      openai.Int(1),
+	}
+	// GPT image models always respond with base64 and reject the response_format parameter
+	if !strings.HasPrefix(p.model, "gpt-image") {
+		params.ResponseFormat = openai.ImageGenerateParamsResponseFormatB64JSON
+	}
+
+	image, err := p.client.Images.Generate(ctx, params)
+	if err != nil {
+		var apiErr *openai.Error
+		if errors.As(err, &apiErr) && !retryableStatus(apiErr.StatusCode) {
+			return nil, Permanent(fmt.Errorf("failed to generate image: %w", err))
+		}
+		return nil, fmt.Errorf("failed to generate image: %w", err)
+	}
+
+	if len(image.Data) == 0 || image.Data[0].B64JSON == "" {
+		return nil, errors.New("failed to generate image: the response contains no image")
+	}
+
+	imageBytes, err := base64.StdEncoding.DecodeString(image.Data[0].B64JSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 image: %w", err)
+	}
+	return imageBytes, nil
+}
+
+// retryableStatus returns true for the HTTP status codes which indicate a transient failure.
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusConflict ||
+		code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
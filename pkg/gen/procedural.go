@@ -0,0 +1,37 @@
+package gen
+
+import (
+	"context"
+	"errors"
+	"github.com/williamnoble/kube-botany/pkg/render"
+)
+
+// ProceduralProvider draws the plant itself, from its variety, growth stage, water level and health, so that
+// every plant has a meaningful image without an API key. The same plant in the same state always gets the same
+// image.
+type ProceduralProvider struct {
+	renderer *render.PNGRenderer
+}
+
+// NewProceduralProvider returns a provider drawing images size pixels square, render.DefaultPNGSize if size is 0.
+func NewProceduralProvider(size int) *ProceduralProvider {
+	return &ProceduralProvider{renderer: render.NewPNGRenderer(size)}
+}
+
+func (p *ProceduralProvider) Name() string {
+	return ProviderProcedural
+}
+
+func (p *ProceduralProvider) Kind() string {
+	return ProviderProcedural
+}
+
+func (p *ProceduralProvider) GenerateImage(ctx context.Context, req ImageRequest) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if req.Plant == nil {
+		return nil, Permanent(errors.New("gen: the procedural provider requires a plant"))
+	}
+	return p.renderer.RenderPNG(req.Plant)
+}
@@ -0,0 +1,173 @@
+package gen
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// DefaultPromptVersion is the version of the embedded prompt templates used when none is configured.
+const DefaultPromptVersion = "v1"
+
+//go:embed prompts
+var promptTemplates embed.FS
+
+// Prompt is a rendered prompt, along with the version of the templates which produced it.
+type Prompt struct {
+	Text    string `json:"text"`
+	Version string `json:"version"` // the template version and a digest of the template sources
+}
+
+// PromptData is the data available to the prompt templates.
+type PromptData struct {
+	Id               string
+	FriendlyName     string
+	Variety          string // human readable e.g. "aloe vera"
+	VarietyType      string // as in varieties.json e.g. "aloe_vera"
+	Stage            string
+	GrowthPercentage int
+	Condition        string
+	WaterLevel       int
+	MinWaterLevel    int
+	MaxWaterLevel    int
+	Vitality         int
+	DaysAlive        int
+	Backdrop         string // name of a "backdrop/<name>" template
+	Mascot           string // e.g. "gopher", omitted when empty
+}
+
+// NewPromptData collects the data describing the plant's current state.
+func NewPromptData(p *plant.Plant, g plant.Generator) PromptData {
+	return PromptData{
+		Id:               p.Id,
+		FriendlyName:     p.FriendlyName,
+		Variety:          strings.ReplaceAll(p.Variety.Type, "_", " "),
+		VarietyType:      p.Variety.Type,
+		Stage:            p.GrowthStage(),
+		GrowthPercentage: p.GrowthPercentage(),
+		Condition:        p.Condition().String(),
+		WaterLevel:       p.CurrentWaterLevel(),
+		MinWaterLevel:    p.Variety.MinimumWaterLevel,
+		MaxWaterLevel:    p.MaximumWaterLevel(),
+		Vitality:         p.Vitality(),
+		DaysAlive:        p.DaysAlive(),
+		Backdrop:         g.Backdrop,
+		Mascot:           g.Mascot,
+	}
+}
+
+// PromptBuilder renders image prompts from a versioned set of text/template files. The embedded templates under
+// prompts/<version> are parsed first, then those in the override directory, whose definitions replace any
+// embedded template of the same name. Rendering starts from the template named "prompt".
+type PromptBuilder struct {
+	templates *template.Template
+	version   string
+}
+
+// NewPromptBuilder parses the embedded templates of the given version, and the *.tmpl files in overrideDir
+// when it isn't empty.
+func NewPromptBuilder(version, overrideDir string) (*PromptBuilder, error) {
+	if version == "" {
+		version = DefaultPromptVersion
+	}
+
+	sources, err := readTemplates(promptTemplates, path.Join("prompts", version))
+	if err != nil {
+		return nil, fmt.Errorf("gen: failed to read prompt templates %s: %w", version, err)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("gen: unknown prompt template version %q", version)
+	}
+	if overrideDir != "" {
+		overrides, err := readTemplates(os.DirFS(overrideDir), ".")
+		if err != nil {
+			return nil, fmt.Errorf("gen: failed to read prompt template overrides: %w", err)
+		}
+		sources = append(sources, overrides...)
+	}
+
+	b := &PromptBuilder{}
+	b.templates = template.New("prompts").Option("missingkey=error").Funcs(template.FuncMap{
+		"has":     b.has,
+		"include": b.include,
+	})
+
+	digest := sha256.New()
+	for _, src := range sources {
+		if _, err := b.templates.New(src.name).Parse(src.text); err != nil {
+			return nil, fmt.Errorf("gen: failed to parse prompt template %s: %w", src.name, err)
+		}
+		digest.Write([]byte(src.text))
+	}
+	if b.templates.Lookup("prompt") == nil {
+		return nil, fmt.Errorf("gen: prompt templates %s don't define \"prompt\"", version)
+	}
+
+	b.version = fmt.Sprintf("%s-%s", version, hex.EncodeToString(digest.Sum(nil))[:8])
+	return b, nil
+}
+
+// Version returns the template version and a digest of the template sources, so that a prompt can be traced
+// back to the templates which produced it even when they have been overridden.
+func (b *PromptBuilder) Version() string {
+	return b.version
+}
+
+// Build renders the prompt for the plant.
+func (b *PromptBuilder) Build(p *plant.Plant, g plant.Generator) (Prompt, error) {
+	var text strings.Builder
+	if err := b.templates.ExecuteTemplate(&text, "prompt", NewPromptData(p, g)); err != nil {
+		return Prompt{}, fmt.Errorf("gen: failed to render prompt for %s: %w", p.Id, err)
+	}
+
+	// templates are laid out for readability, collapse the whitespace
+	return Prompt{
+		Text:    strings.Join(strings.Fields(text.String()), " "),
+		Version: b.version,
+	}, nil
+}
+
+func (b *PromptBuilder) has(name string) bool {
+	return b.templates.Lookup(name) != nil
+}
+
+func (b *PromptBuilder) include(name string, data any) (string, error) {
+	var text strings.Builder
+	if err := b.templates.ExecuteTemplate(&text, name, data); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+type templateSource struct {
+	name string
+	text string
+}
+
+// readTemplates reads the *.tmpl files in dir, ordered by name.
+func readTemplates(fsys fs.FS, dir string) ([]templateSource, error) {
+	matches, err := fs.Glob(fsys, path.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(matches)
+
+	var sources []templateSource
+	for _, match := range matches {
+		text, err := fs.ReadFile(fsys, match)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, templateSource{name: filepath.Base(match), text: string(text)})
+	}
+	return sources, nil
+}
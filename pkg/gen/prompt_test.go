@@ -0,0 +1,83 @@
+package gen
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newPromptTestPlant() *plant.Plant {
+	creationTime := time.Now().Add(-10 * 24 * time.Hour)
+	variety := &plant.Variety{Type: "bonsai", GrowthRatePerDay: 5, MinimumWaterLevel: 10, MaximumWaterLevel: 80}
+	return &plant.Plant{
+		Id:           "FooPlant",
+		Variety:      variety,
+		CreationTime: creationTime,
+		LastUpdated:  creationTime.Add(10 * 24 * time.Hour),
+		Health:       plant.Health{CurrentGrowth: 160, CurrentWaterLevel: 40, Vitality: plant.MaxVitality},
+	}
+}
+
+func TestBuildPrompt(t *testing.T) {
+	t.Parallel()
+	b, err := NewPromptBuilder("", "")
+	require.NoError(t, err)
+	p := newPromptTestPlant()
+
+	prompt, err := b.Build(p, plant.Generator{Backdrop: "library", Mascot: "gopher"})
+	require.NoError(t, err)
+	assert.Equal(t, b.Version(), prompt.Version)
+	assert.Regexp(t, `^v1-[0-9a-f]{8}$`, prompt.Version)
+	assert.Contains(t, prompt.Text, "bonsai tree with a gnarled trunk")
+	assert.Contains(t, prompt.Text, "actively growing, about 64% of its full size")
+	assert.Contains(t, prompt.Text, "looks healthy")
+	assert.Contains(t, prompt.Text, "friendly gopher character")
+	assert.Contains(t, prompt.Text, "oriental library")
+	assert.Contains(t, prompt.Text, "this is day 10")
+	assert.NotContains(t, prompt.Text, "  ", "whitespace is collapsed")
+
+	// the same state always produces the same prompt
+	again, err := b.Build(p, plant.Generator{Backdrop: "library", Mascot: "gopher"})
+	require.NoError(t, err)
+	assert.Equal(t, prompt, again)
+
+	// the prompt follows the plant's state, unknown varieties and backdrops are described generically
+	p.Variety = &plant.Variety{Type: "venus_fly_trap", MinimumWaterLevel: 10, DroughtGraceDays: 3}
+	p.Health.DroughtSince = p.LastUpdated.Add(-24 * time.Hour)
+	prompt, err = b.Build(p, plant.Generator{Backdrop: "moon"})
+	require.NoError(t, err)
+	assert.Contains(t, prompt.Text, "a venus fly trap plant")
+	assert.Contains(t, prompt.Text, "soil is dry and cracked")
+	assert.NotContains(t, prompt.Text, "character")
+}
+
+func TestPromptOverrides(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	override := `{{ define "backdrop/moon" }}The plant sits on the surface of the moon.{{ end }}
+{{ define "variety/bonsai" }}a tiny juniper bonsai{{ end }}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "custom.tmpl"), []byte(override), 0o644))
+
+	defaults, err := NewPromptBuilder("v1", "")
+	require.NoError(t, err)
+	b, err := NewPromptBuilder("v1", dir)
+	require.NoError(t, err)
+	assert.NotEqual(t, defaults.Version(), b.Version(), "overrides change the version")
+
+	prompt, err := b.Build(newPromptTestPlant(), plant.Generator{Backdrop: "moon"})
+	require.NoError(t, err)
+	assert.Contains(t, prompt.Text, "a tiny juniper bonsai")
+	assert.Contains(t, prompt.Text, "surface of the moon")
+	assert.NotContains(t, prompt.Text, "gnarled trunk")
+
+	_, err = NewPromptBuilder("v0", "")
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte(`{{ define "prompt" }}`), 0o644))
+	_, err = NewPromptBuilder("v1", dir)
+	assert.Error(t, err)
+}
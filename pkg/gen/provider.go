@@ -0,0 +1,82 @@
+package gen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+)
+
+// Supported image providers.
+const (
+	ProviderOpenAI     = "openai"     // any OpenAI-compatible images API e.g. OpenAI, OpenRouter or a local server
+	ProviderProcedural = "procedural" // images drawn from the plant's state, for running without an API key
+	ProviderLocal      = "local"      // placeholder images read from a directory
+	ProviderMock       = "mock"       // a fixed image, for tests
+)
+
+// ImageRequest describes an image to be generated for a plant.
+type ImageRequest struct {
+	Plant  *plant.Plant
+	Prompt string
+}
+
+// ImageProvider generates images. Implementations must honour the context's deadline and cancellation.
+type ImageProvider interface {
+	// Name identifies the provider in logs.
+	Name() string
+
+	// Kind returns the kind of the provider, e.g. ProviderOpenAI, which selects its DefaultRateLimits.
+	Kind() string
+
+	// GenerateImage returns the encoded image (PNG) for the request.
+	GenerateImage(ctx context.Context, req ImageRequest) ([]byte, error)
+}
+
+// ProviderConfig configures the provider returned by NewProvider.
+type ProviderConfig struct {
+	Provider  string // one of ProviderOpenAI, ProviderProcedural, ProviderLocal or ProviderMock
+	APIKey    string // API key of the OpenAI-compatible provider
+	BaseURL   string // base URL of the OpenAI-compatible provider
+	Model     string // image model of the OpenAI-compatible provider
+	StaticDir string // directory of the placeholder images used by the local provider
+}
+
+// NewProvider returns the ImageProvider selected by cfg.Provider.
+func NewProvider(cfg ProviderConfig) (ImageProvider, error) {
+	switch cfg.Provider {
+	case ProviderOpenAI:
+		return NewOpenAIProvider(cfg.APIKey, cfg.BaseURL, cfg.Model)
+	case ProviderProcedural, "":
+		return NewProceduralProvider(0), nil
+	case ProviderLocal:
+		return NewLocalProvider(cfg.StaticDir), nil
+	case ProviderMock:
+		return NewMockProvider(nil), nil
+	default:
+		return nil, fmt.Errorf("gen: unsupported image provider %q", cfg.Provider)
+	}
+}
+
+// permanentError marks an error which retrying won't resolve.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the request which caused it is not retried, e.g. when the request is rejected as
+// invalid or unauthorised.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent returns true when err, or an error it wraps, was marked by Permanent.
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
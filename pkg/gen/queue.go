@@ -0,0 +1,244 @@
+package gen
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the number of attempts after which a failing job is dead-lettered, when Options
+	// doesn't set MaxAttempts
+	DefaultMaxAttempts = 5
+	// DefaultJobBackoff is the delay before a failed job is retried, when Options doesn't set JobBackoff
+	DefaultJobBackoff = 5 * time.Minute
+	// DefaultJobLease is how long an attempt holds a job before it is retried, when Options doesn't set JobLease
+	DefaultJobLease = time.Hour
+
+	// maxJobBackoff bounds the delay between the attempts of a job, however many times it has failed
+	maxJobBackoff = 6 * time.Hour
+)
+
+// JobStore persists the queue of image generation jobs, it is implemented by repository.PlantRepository.
+type JobStore interface {
+	// SaveImageJob creates the job when its Id is zero, assigning it an Id, otherwise it updates it
+	SaveImageJob(job *plant.ImageJob) error
+	// ListImageJobs returns a plant's jobs, newest first
+	ListImageJobs(plantID string) ([]plant.ImageJob, error)
+	// DueImageJobs returns the jobs of every plant which are due at the given time, oldest first
+	DueImageJobs(at time.Time) ([]plant.ImageJob, error)
+	// ClaimImageJob starts an attempt at a due job, holding a lease on it, it returns false when the job has
+	// changed since it was read, e.g. because another replica claimed it
+	ClaimImageJob(job *plant.ImageJob, at time.Time, lease time.Duration) (bool, error)
+	// GetPlant returns the plant of a job, or repository.ErrPlantNotFound when the plant has been deleted
+	GetPlant(id string) (*plant.Plant, error)
+}
+
+// enqueue adds a job to generate the plant's image for the given day, unless the image exists or already has a job.
+func (s *ImageGenerationService) enqueue(p *plant.Plant, at time.Time) error {
+	image := p.ImageOn(at)
+	exists, err := s.imageExists(p.Id, image)
+	if err != nil || exists {
+		return err
+	}
+
+	jobs, err := s.opts.Jobs.ListImageJobs(p.Id)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(jobs, func(job plant.ImageJob) bool { return job.Image == image }) {
+		return nil
+	}
+
+	job := plant.NewImageJob(p.Id, image, s.opts.MaxAttempts, at)
+	if err := s.opts.Jobs.SaveImageJob(&job); err != nil {
+		return err
+	}
+	s.logger.Info("queued image", "image", image, "job", job.Id)
+	return nil
+}
+
+// runJob makes an attempt at a job, unless the plant's image is already being generated or another replica
+// claimed the job first. The plant is looked up in the JobStore when it is nil, e.g. because it was created after
+// the ImageTask's snapshot, and the job is dead-lettered only when the plant doesn't exist. A failed attempt is
+// retried with exponential backoff until the job's attempts are exhausted or the failure is permanent, when the
+// job is dead-lettered. An attempt interrupted by the context is not counted.
+func (s *ImageGenerationService) runJob(ctx context.Context, job plant.ImageJob, p *plant.Plant) error {
+	if !s.claim(job.PlantId) {
+		s.logger.Info("image already being generated, skipping", "plant", job.PlantId, "job", job.Id)
+		return nil
+	}
+	defer s.release(job.PlantId)
+
+	if p == nil {
+		found, err := s.opts.Jobs.GetPlant(job.PlantId)
+		switch {
+		case err == nil:
+			p = found
+		case !errors.Is(err, repository.ErrPlantNotFound):
+			return fmt.Errorf("failed to get plant %s: %w", job.PlantId, err)
+		}
+	}
+
+	if claimed, err := s.opts.Jobs.ClaimImageJob(&job, s.opts.Clock.Now(), s.opts.JobLease); err != nil {
+		return err
+	} else if !claimed {
+		s.logger.Info("image job claimed elsewhere, skipping", "plant", job.PlantId, "job", job.Id)
+		return nil
+	}
+
+	if p == nil {
+		s.finish(&job, plant.ImageJobDead, "plant not found")
+		return s.opts.Jobs.SaveImageJob(&job)
+	}
+	if exists, err := s.imageExists(job.PlantId, job.Image); err != nil {
+		return err
+	} else if exists {
+		s.finish(&job, plant.ImageJobSucceeded, "")
+		return s.opts.Jobs.SaveImageJob(&job)
+	}
+
+	s.logger.Info("generating image", "image", job.Image, "job", job.Id, "attempt", job.Attempts)
+	genErr := s.generateImage(ctx, p, job.Image)
+	switch {
+	case genErr == nil:
+		s.finish(&job, plant.ImageJobSucceeded, "")
+		s.logger.Info("image generated successfully", "image", job.Image, "job", job.Id)
+	case ctx.Err() != nil:
+		job.Attempts--
+		s.finish(&job, plant.ImageJobPending, genErr.Error())
+		job.NextAttempt = job.UpdatedAt
+	case IsPermanent(genErr) || job.Attempts >= job.MaxAttempts:
+		s.finish(&job, plant.ImageJobDead, genErr.Error())
+		s.logger.Error("image generation dead-lettered", "image", job.Image, "job", job.Id, "error", genErr)
+	default:
+		s.finish(&job, plant.ImageJobFailed, genErr.Error())
+		job.NextAttempt = job.UpdatedAt.Add(s.jobBackoff(job.Attempts))
+	}
+
+	if err := s.opts.Jobs.SaveImageJob(&job); err != nil {
+		return errors.Join(genErr, err)
+	}
+	if genErr != nil {
+		return fmt.Errorf("failed to generate image %s: %w", job.Image, genErr)
+	}
+	return nil
+}
+
+// finish records the outcome of an attempt at a job.
+func (s *ImageGenerationService) finish(job *plant.ImageJob, state plant.ImageJobState, lastError string) {
+	job.State = state
+	job.LastError = lastError
+	job.UpdatedAt = s.opts.Clock.Now()
+	job.NextAttempt = time.Time{}
+}
+
+// jobBackoff returns the delay before the next attempt at a job which has failed the given number of times.
+func (s *ImageGenerationService) jobBackoff(attempts int) time.Duration {
+	backoff := s.opts.JobBackoff
+	for range attempts - 1 {
+		if backoff >= maxJobBackoff {
+			break
+		}
+		backoff *= 2
+	}
+	return min(backoff, maxJobBackoff)
+}
+
+// imageExists returns true when the plant has the named image.
+func (s *ImageGenerationService) imageExists(id, image string) (bool, error) {
+	_, err := s.images.GetImage(id, image)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, fs.ErrKeyNotFound), errors.Is(err, fs.ErrImageNotFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check image %s: %w", image, err)
+	}
+}
+
+// memoryJobStore is the JobStore of a service created without one, its queue is lost on restart.
+type memoryJobStore struct {
+	mu     sync.Mutex
+	jobs   []plant.ImageJob // oldest first
+	lastId int64
+}
+
+func (m *memoryJobStore) SaveImageJob(job *plant.ImageJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job.Id == 0 {
+		m.lastId++
+		job.Id = m.lastId
+		m.jobs = append(m.jobs, *job)
+		return nil
+	}
+	i := slices.IndexFunc(m.jobs, func(j plant.ImageJob) bool { return j.Id == job.Id })
+	if i < 0 {
+		return fmt.Errorf("image job %d not found", job.Id)
+	}
+	m.jobs[i] = *job
+	return nil
+}
+
+func (m *memoryJobStore) ListImageJobs(plantID string) ([]plant.ImageJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]plant.ImageJob, 0)
+	for _, job := range m.jobs {
+		if job.PlantId == plantID {
+			jobs = append(jobs, job)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b plant.ImageJob) int {
+		return cmp.Compare(b.Id, a.Id)
+	})
+	return jobs, nil
+}
+
+func (m *memoryJobStore) ClaimImageJob(job *plant.ImageJob, at time.Time, lease time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.jobs, func(j plant.ImageJob) bool { return j.Id == job.Id })
+	if i < 0 {
+		return false, nil
+	}
+	stored := m.jobs[i]
+	if stored.State != job.State || !stored.UpdatedAt.Equal(job.UpdatedAt) || !stored.Due(at) {
+		return false, nil
+	}
+	stored.Claim(at, lease)
+	m.jobs[i] = stored
+	*job = stored
+	return true, nil
+}
+
+// GetPlant returns repository.ErrPlantNotFound, as a memoryJobStore only has the jobs of the plants in the
+// snapshots passed to ImageTask: a plant missing from the snapshot after its job was queued has been deleted.
+func (m *memoryJobStore) GetPlant(string) (*plant.Plant, error) {
+	return nil, repository.ErrPlantNotFound
+}
+
+func (m *memoryJobStore) DueImageJobs(at time.Time) ([]plant.ImageJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []plant.ImageJob
+	for _, job := range m.jobs {
+		if job.Due(at) {
+			due = append(due, job)
+		}
+	}
+	return due, nil
+}
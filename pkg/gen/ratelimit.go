@@ -0,0 +1,85 @@
+package gen
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit limits the calls made to a provider with a token bucket: the bucket holds up to Burst calls, and is
+// refilled at PerMinute calls a minute.
+type RateLimit struct {
+	PerMinute float64 // calls a minute, the provider's default when zero and unlimited when negative
+	Burst     int     // calls which may be made at once after a quiet period, 1 when zero
+}
+
+// DefaultRateLimits are the rate limits of the providers whose calls are paid for, they apply when Options leaves
+// the RateLimit unset. Providers without a default are not limited.
+var DefaultRateLimits = map[string]RateLimit{
+	ProviderOpenAI: {PerMinute: 12, Burst: 4},
+}
+
+// tokenBucket is a RateLimit shared by the workers of an ImageGenerationService.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens a second
+	burst  float64
+	tokens float64 // may be negative, when callers are waiting for tokens they have reserved
+	last   time.Time
+	now    func() time.Time
+}
+
+// newTokenBucket returns a full bucket for the limit, or nil when the limit doesn't limit anything.
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	if limit.PerMinute <= 0 {
+		return nil
+	}
+	burst := float64(max(limit.Burst, 1))
+	return &tokenBucket{
+		rate:   limit.PerMinute / 60,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until a call may be made, or until the context is done.
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	delay := b.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token and returns how long to wait until it is available.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token which won't be used.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
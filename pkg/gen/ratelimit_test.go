@@ -0,0 +1,60 @@
+package gen
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newTokenBucket(RateLimit{}))
+	assert.Nil(t, newTokenBucket(RateLimit{PerMinute: -1}))
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newTokenBucket(RateLimit{PerMinute: 60, Burst: 2})
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	// the burst is available at once, then calls are spaced a second apart
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, time.Second, b.reserve())
+	assert.Equal(t, 2*time.Second, b.reserve())
+
+	// a cancelled reservation is returned
+	b.cancel()
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, time.Duration(0), b.reserve())
+
+	// the bucket never holds more than the burst
+	now = now.Add(time.Hour)
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, time.Second, b.reserve())
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	t.Parallel()
+	b := newTokenBucket(RateLimit{PerMinute: 1})
+	require.NoError(t, b.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, b.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestDefaultRateLimits(t *testing.T) {
+	t.Parallel()
+	provider, err := NewOpenAIProvider("key", "", "")
+	require.NoError(t, err)
+	svc := newTestService(t, provider, fs.NewInMemoryImageStore(), Options{})
+	require.NotNil(t, svc.limiter, "calls to the paid provider are limited by default")
+	assert.Equal(t, DefaultRateLimits[ProviderOpenAI].PerMinute/60, svc.limiter.rate)
+
+	svc = newTestService(t, NewProceduralProvider(0), fs.NewInMemoryImageStore(), Options{})
+	assert.Nil(t, svc.limiter)
+}
@@ -0,0 +1,107 @@
+package plant
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType describes something which happened to a plant.
+type EventType string
+
+const (
+	EventCreated          EventType = "created"
+	EventWatered          EventType = "watered"
+	EventUpdated          EventType = "updated"
+	EventStageChanged     EventType = "stage_changed"
+	EventConditionChanged EventType = "condition_changed"
+	EventDied             EventType = "died"
+	EventDeleted          EventType = "deleted"
+)
+
+func (e EventType) String() string {
+	return string(e)
+}
+
+// eventTypes lists every EventType.
+var eventTypes = []EventType{
+	EventCreated, EventWatered, EventUpdated, EventStageChanged, EventConditionChanged, EventDied, EventDeleted,
+}
+
+// ParseEventType returns the EventType with the given name.
+func ParseEventType(name string) (EventType, error) {
+	for _, eventType := range eventTypes {
+		if eventType.String() == name {
+			return eventType, nil
+		}
+	}
+	return "", fmt.Errorf("unknown event type %q", name)
+}
+
+// Event is an entry in a plant's history. Events are ordered by Id, which is assigned by the repository.
+type Event struct {
+	Id      int64     `json:"id"`
+	PlantId string    `json:"plant_id"`
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message,omitempty"`
+	Before  *Snapshot `json:"before,omitempty"` // the plant's health before the event, nil for creation
+	After   *Snapshot `json:"after,omitempty"`  // the plant's health after the event, nil for deletion
+}
+
+// Snapshot captures a plant's health at a point in time.
+type Snapshot struct {
+	GrowthStage       string `json:"growth_stage"`
+	Condition         string `json:"condition"`
+	CurrentGrowth     int64  `json:"current_growth"`
+	CurrentWaterLevel int    `json:"current_water_level"`
+	Vitality          int    `json:"vitality"`
+}
+
+// NewEvent returns an event of the given type for a plant.
+func NewEvent(plantId string, eventType EventType, at time.Time, message string) Event {
+	return Event{
+		PlantId: plantId,
+		Type:    eventType,
+		Time:    at,
+		Message: message,
+	}
+}
+
+// Snapshot returns the plant's current health.
+func (p *Plant) Snapshot() Snapshot {
+	return Snapshot{
+		GrowthStage:       p.GrowthStage(),
+		Condition:         p.Condition().String(),
+		CurrentGrowth:     p.CurrentGrowth(),
+		CurrentWaterLevel: p.CurrentWaterLevel(),
+		Vitality:          p.Vitality(),
+	}
+}
+
+// ChangeEvents returns the events describing how the plant's growth stage and condition have changed since the
+// before snapshot was taken. A plant which has died yields a single EventDied.
+func (p *Plant) ChangeEvents(before Snapshot) []Event {
+	after := p.Snapshot()
+
+	newEvent := func(eventType EventType, at time.Time, message string) Event {
+		e := NewEvent(p.Id, eventType, at, message)
+		e.Before = &before
+		e.After = &after
+		return e
+	}
+
+	if p.Dead() && before.Condition != ConditionDead.String() {
+		return []Event{newEvent(EventDied, p.DiedAt, "plant died")}
+	}
+
+	var events []Event
+	if before.GrowthStage != after.GrowthStage {
+		message := fmt.Sprintf("growth stage changed from %s to %s", before.GrowthStage, after.GrowthStage)
+		events = append(events, newEvent(EventStageChanged, p.LastUpdated, message))
+	}
+	if before.Condition != after.Condition {
+		message := fmt.Sprintf("condition changed from %s to %s", before.Condition, after.Condition)
+		events = append(events, newEvent(EventConditionChanged, p.LastUpdated, message))
+	}
+	return events
+}
@@ -0,0 +1,222 @@
+package plant
+
+import (
+	"math"
+	"time"
+)
+
+// Condition describes the wellbeing of a plant, independently of its growth stage.
+type Condition string
+
+const (
+	ConditionHealthy     Condition = "healthy"     // the plant is adequately watered
+	ConditionThirsty     Condition = "thirsty"     // the plant is below its minimum water level and has stopped growing
+	ConditionWilting     Condition = "wilting"     // the drought grace period has expired, the plant loses vitality
+	ConditionOverwatered Condition = "overwatered" // the plant is above its maximum water level
+	ConditionRootRot     Condition = "root_rot"    // the plant has been overwatered for too long, it loses vitality
+	ConditionDead        Condition = "dead"        // the plant has run out of vitality
+)
+
+func (c Condition) String() string {
+	return string(c)
+}
+
+const (
+	// MaxWaterCapacity is the most water a pot can hold, and the maximum water level of a variety which
+	// doesn't specify one.
+	MaxWaterCapacity = 100
+
+	// MaxVitality is the vitality of a plant in full health, a plant dies when its vitality reaches zero.
+	MaxVitality = 100.0
+
+	// vitalityLossPerDay is the vitality lost each day once the drought grace period has expired, a wilting plant
+	// in full health dies after four days.
+	vitalityLossPerDay = 25.0
+
+	// vitalityRecoveryPerDay is the vitality regained each day while the plant is adequately watered.
+	vitalityRecoveryPerDay = 10.0
+
+	// rootRotGraceDays is the number of days a plant tolerates being overwatered before its roots start to rot.
+	rootRotGraceDays = 2
+
+	// rootRotVitalityLossPerDay is the vitality lost each day while the plant's roots are rotting.
+	rootRotVitalityLossPerDay = 20.0
+)
+
+// updateOverwatering records the time at which the plant's water level rose above the variety's maximum water level
+// and returns the time, no later than currentTime, until which the plant remained overwatered. It must be called
+// before the water consumed since the last update is applied.
+func (p *Plant) updateOverwatering(currentTime time.Time) time.Time {
+	if !p.Overwatered() {
+		p.Health.OverwateredSince = time.Time{}
+		return p.LastUpdated
+	}
+	if p.Health.OverwateredSince.IsZero() {
+		p.Health.OverwateredSince = p.LastUpdated
+	}
+
+	rate := p.Variety.WaterConsumptionUnitsPerDay
+	if rate <= 0 {
+		return currentTime
+	}
+
+	// the plant remains overwatered until the excess water above the maximum is consumed
+	excess := p.Health.CurrentWaterLevel - float64(p.MaximumWaterLevel())
+	return earliest(currentTime, p.LastUpdated.Add(days(excess/float64(rate))))
+}
+
+// rootRotWindow returns the period, since the last update, during which the plant's roots were rotting. The
+// window is empty unless the plant was overwatered for longer than the root rot grace period.
+func (p *Plant) rootRotWindow(overwateredUntil time.Time) (time.Time, time.Time) {
+	if p.Health.OverwateredSince.IsZero() {
+		return p.LastUpdated, p.LastUpdated
+	}
+	return latest(p.LastUpdated, p.rootRotExpiry()), overwateredUntil
+}
+
+// rootRotExpiry returns the time at which an overwatered plant's roots start to rot.
+func (p *Plant) rootRotExpiry() time.Time {
+	return p.Health.OverwateredSince.Add(days(rootRotGraceDays))
+}
+
+// updateDrought records the time at which the plant's water level dropped below the variety's minimum water level.
+// It must be called before the water consumed since the last update is applied.
+func (p *Plant) updateDrought(currentTime time.Time) {
+	if !p.Health.DroughtSince.IsZero() {
+		return
+	}
+
+	if !p.Healthy() {
+		p.Health.DroughtSince = p.LastUpdated
+		return
+	}
+
+	rate := p.Variety.WaterConsumptionUnitsPerDay
+	if rate <= 0 {
+		return
+	}
+
+	// water is consumed at a constant rate, so the level drops below the minimum once the surplus is used up
+	surplus := p.Health.CurrentWaterLevel - float64(p.Variety.MinimumWaterLevel)
+	onset := p.LastUpdated.Add(days(surplus / float64(rate)))
+	if onset.Before(currentTime) {
+		p.Health.DroughtSince = onset
+	}
+}
+
+// updateVitality applies the vitality lost while the plant's roots were rotting, regained while it was adequately
+// watered and lost while it was wilting. Water only ever decreases between updates, so these periods occur in that
+// order. A plant whose vitality reaches zero dies and the time of death is recorded.
+func (p *Plant) updateVitality(currentTime time.Time, overwateredUntil time.Time) {
+	if rotFrom, rotUntil := p.rootRotWindow(overwateredUntil); rotUntil.After(rotFrom) {
+		if p.loseVitality(rootRotVitalityLossPerDay, rotFrom, rotUntil) {
+			return
+		}
+	}
+
+	recoverFrom := latest(p.LastUpdated, overwateredUntil)
+	recoverUntil := currentTime
+	if !p.Health.DroughtSince.IsZero() {
+		recoverUntil = earliest(currentTime, p.Health.DroughtSince)
+	}
+	if recoverUntil.After(recoverFrom) {
+		recovered := vitalityRecoveryPerDay * elapsedDays(recoverUntil, recoverFrom)
+		p.Health.Vitality = math.Min(MaxVitality, p.Health.Vitality+recovered)
+	}
+
+	if p.Health.DroughtSince.IsZero() {
+		return
+	}
+
+	wiltingFrom := latest(p.LastUpdated, p.graceExpiry())
+	if currentTime.After(wiltingFrom) {
+		p.loseVitality(vitalityLossPerDay, wiltingFrom, currentTime)
+	}
+}
+
+// loseVitality applies the vitality lost at the given daily rate between from and until. If the plant runs out of
+// vitality the time of death is recorded and true is returned.
+func (p *Plant) loseVitality(ratePerDay float64, from, until time.Time) bool {
+	lost := ratePerDay * elapsedDays(until, from)
+	if lost >= p.Health.Vitality {
+		p.DiedAt = from.Add(days(p.Health.Vitality / ratePerDay))
+		p.Health.Vitality = 0
+		return true
+	}
+	p.Health.Vitality -= lost
+	return false
+}
+
+// growthDays returns the number of days, since the last update, that the plant was able to grow. A plant stops
+// growing while its roots are rotting, when it dies and as soon as its water level drops below the minimum, even
+// if that happens part way through the interval being updated. It must be called after updateVitality.
+func (p *Plant) growthDays(currentTime time.Time, overwateredUntil time.Time) float64 {
+	deadline := currentTime
+	if !p.Health.DroughtSince.IsZero() {
+		deadline = earliest(deadline, p.Health.DroughtSince)
+	}
+	if p.Dead() {
+		deadline = earliest(deadline, p.DiedAt)
+	}
+	if !deadline.After(p.LastUpdated) {
+		return 0
+	}
+
+	growing := elapsedDays(deadline, p.LastUpdated)
+	if rotFrom, rotUntil := p.rootRotWindow(overwateredUntil); rotUntil.After(rotFrom) {
+		growing -= elapsedDays(rotUntil, rotFrom)
+	}
+	return math.Max(growing, 0)
+}
+
+// graceExpiry returns the time at which a plant in drought starts to wilt.
+func (p *Plant) graceExpiry() time.Time {
+	return p.Health.DroughtSince.Add(days(float64(p.Variety.DroughtGraceDays)))
+}
+
+// Condition returns the plant's condition as of its last update.
+func (p *Plant) Condition() Condition {
+	switch {
+	case p.Dead():
+		return ConditionDead
+	case !p.Health.OverwateredSince.IsZero() && p.LastUpdated.Before(p.rootRotExpiry()):
+		return ConditionOverwatered
+	case !p.Health.OverwateredSince.IsZero():
+		return ConditionRootRot
+	case p.Health.DroughtSince.IsZero():
+		return ConditionHealthy
+	case p.LastUpdated.Before(p.graceExpiry()):
+		return ConditionThirsty
+	default:
+		return ConditionWilting
+	}
+}
+
+// Dead returns true once the plant has died, dead plants no longer grow or consume water.
+func (p *Plant) Dead() bool {
+	return !p.DiedAt.IsZero()
+}
+
+// Vitality returns the plant's vitality, between 0 and MaxVitality.
+func (p *Plant) Vitality() int {
+	return int(math.Round(p.Health.Vitality))
+}
+
+// days converts a fractional number of days into a time.Duration.
+func days(d float64) time.Duration {
+	return time.Duration(d * 24 * float64(time.Hour))
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
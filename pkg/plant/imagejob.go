@@ -0,0 +1,68 @@
+package plant
+
+import (
+	"time"
+)
+
+// ImageJobState is the state of an image generation job.
+type ImageJobState string
+
+const (
+	ImageJobPending   ImageJobState = "pending"   // waiting for its first attempt
+	ImageJobRunning   ImageJobState = "running"   // being generated
+	ImageJobSucceeded ImageJobState = "succeeded" // the image was generated
+	ImageJobFailed    ImageJobState = "failed"    // the last attempt failed, it is retried after NextAttempt
+	ImageJobDead      ImageJobState = "dead"      // dead-lettered, its attempts are exhausted or it cannot succeed
+)
+
+func (s ImageJobState) String() string {
+	return string(s)
+}
+
+// ImageJob is a queued request to generate one of a plant's daily images. Jobs are ordered by Id, which is
+// assigned by the repository, and a plant has at most one job for each image.
+type ImageJob struct {
+	Id          int64         `json:"id"`
+	PlantId     string        `json:"plant_id"`
+	Image       string        `json:"image"` // file name of the image the job generates
+	State       ImageJobState `json:"state"`
+	Attempts    int           `json:"attempts"`
+	MaxAttempts int           `json:"max_attempts"` // attempts after which a failing job is dead-lettered
+	LastError   string        `json:"last_error,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	NextAttempt time.Time     `json:"next_attempt,omitzero"` // when a job is next due, see Due
+}
+
+// NewImageJob returns a pending job, due at once, to generate the named image of a plant.
+func NewImageJob(plantId, image string, maxAttempts int, at time.Time) ImageJob {
+	return ImageJob{
+		PlantId:     plantId,
+		Image:       image,
+		State:       ImageJobPending,
+		MaxAttempts: maxAttempts,
+		CreatedAt:   at,
+		UpdatedAt:   at,
+		NextAttempt: at,
+	}
+}
+
+// Due returns true when the job should be attempted at the given time. A pending or failed job is due from its
+// NextAttempt, and a running job once its lease has expired, as the attempt was then interrupted, e.g. by a restart.
+func (j ImageJob) Due(at time.Time) bool {
+	switch j.State {
+	case ImageJobPending, ImageJobFailed, ImageJobRunning:
+		return !j.NextAttempt.After(at)
+	default:
+		return false
+	}
+}
+
+// Claim starts an attempt at the job at the given time. The attempt holds a lease on the job until at+lease, after
+// which the job is due again should the attempt not have finished.
+func (j *ImageJob) Claim(at time.Time, lease time.Duration) {
+	j.State = ImageJobRunning
+	j.Attempts++
+	j.UpdatedAt = at
+	j.NextAttempt = at.Add(lease)
+}
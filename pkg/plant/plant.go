@@ -6,32 +6,58 @@ import (
 	"time"
 )
 
+// Generator describes the scene in which a plant's images are generated.
 type Generator struct {
-	Backdrop string
-	Mascot   string
+	Backdrop string // the name of the backdrop e.g. "library"
+	Mascot   string // a character placed alongside the plant e.g. "gopher", the plant's Motif
 }
 
 type Health struct {
-	// Health State (config-map?)
-	CurrentGrowth     int64
-	CurrentWaterLevel int
+	// Health State (config-map?), growth and water are fractional so that frequent updates
+	// over short intervals accumulate exactly rather than being rounded away.
+	CurrentGrowth     float64
+	CurrentWaterLevel float64
+	Vitality          float64   // between 0 and MaxVitality, the plant dies when it reaches zero
+	DroughtSince      time.Time // when the water level dropped below the minimum, zero if adequately watered
+	OverwateredSince  time.Time // when the water level rose above the maximum, zero unless overwatered
 }
 
 type Plant struct {
 	Id           string
 	FriendlyName string
 	Variety      *Variety
+	Motif        string // decorative motif drawn alongside the plant e.g. "gopher", optional
+	Generation   int64  // incremented whenever the plant's spec (friendly name, motif) changes, starts at 1
 	CreationTime time.Time
 	LastUpdated  time.Time
+	DiedAt       time.Time // zero while the plant is alive
 
 	Health Health
 }
 
 // Update progresses the plant state based on elapsed time
-// Water consumption is calculated, assuming the plant is appropriated watered, it grows.
+// Water consumption is calculated, the plant grows for as long as it is appropriately watered, the moment
+// within the interval at which the water level drops below the minimum is taken into account. A plant left
+// below its minimum water level for longer than its variety's grace period wilts and eventually dies, as does
+// a plant left above its maximum water level long enough for its roots to rot.
 func (p *Plant) Update(currentTime time.Time) {
+	if !currentTime.After(p.LastUpdated) {
+		return
+	}
+
+	if p.Dead() {
+		p.LastUpdated = currentTime
+		return
+	}
+
+	overwateredUntil := p.updateOverwatering(currentTime)
+	p.updateDrought(currentTime)
 	p.updateWaterConsumption(currentTime)
-	p.updateGrowth(currentTime)
+	p.updateVitality(currentTime, overwateredUntil)
+	p.updateGrowth(currentTime, overwateredUntil)
+	if p.Health.CurrentWaterLevel <= float64(p.MaximumWaterLevel()) {
+		p.Health.OverwateredSince = time.Time{}
+	}
 	p.LastUpdated = currentTime
 }
 
@@ -41,7 +67,7 @@ func (p *Plant) updateWaterConsumption(currentTime time.Time) {
 	elapsedDays := elapsedDays(currentTime, p.LastUpdated)
 
 	//  determining water consumed based on the consumption rate of a particular variety of plant.
-	waterConsumed := int(float64(p.Variety.WaterConsumptionUnitsPerDay) * elapsedDays)
+	waterConsumed := float64(p.Variety.WaterConsumptionUnitsPerDay) * elapsedDays
 
 	// reducing the current water level, (bounded at zero).
 	p.Health.CurrentWaterLevel -= waterConsumed
@@ -58,21 +84,25 @@ func elapsedDays(currentTime time.Time, lastUpdatedTime time.Time) float64 {
 }
 
 // updateGrowth calculates and applies growth progress since the last update.
-func (p *Plant) updateGrowth(currentTime time.Time) {
-	elapsedDays := elapsedDays(currentTime, p.LastUpdated)
-	// growth is determined solely by the elapsed time and the plant's growth rate.
-	// the growth accumulates in CurrentGrowth, which is used to determine the
+func (p *Plant) updateGrowth(currentTime time.Time, overwateredUntil time.Time) {
+	elapsedDays := p.growthDays(currentTime, overwateredUntil)
+	// growth is determined by the plant's growth rate and the time it spent adequately
+	// watered. the growth accumulates in CurrentGrowth, which is used to determine the
 	// plant's growth stage.
 	growth := float64(p.Variety.GrowthRatePerDay) * elapsedDays
-	p.Health.CurrentGrowth += int64(math.Round(growth))
+	p.Health.CurrentGrowth += growth
 }
 
 // GrowthStage returns the growth stage based on the current growth value.
 func (p *Plant) GrowthStage() string {
+	if p.Dead() {
+		return Dead.String()
+	}
+
 	// maps.Keys() is non-deterministic so we'll hardcode
 	stages := []GrowthStage{Maturing, Growing, Sprouting, Seeding}
 	for _, stage := range stages {
-		if p.Health.CurrentGrowth >= growthStageThreshold[stage] {
+		if p.CurrentGrowth() >= growthStageThreshold[stage] {
 			return stage.String()
 		}
 	}
@@ -86,7 +116,7 @@ func (p *Plant) GrowthPercentage() int {
 		return 0
 	}
 
-	percentage := (float64(p.Health.CurrentGrowth) / float64(maturingThreshold)) * 100
+	percentage := (p.Health.CurrentGrowth / float64(maturingThreshold)) * 100
 	if percentage > 100 {
 		return 100
 	}
@@ -96,23 +126,43 @@ func (p *Plant) GrowthPercentage() int {
 // DaysToMaturity estimates the number of days until the plant reaches maturity
 // based on its current growth and growth rate (0 if already mature)
 func (p *Plant) DaysToMaturity() int {
-	if p.Health.CurrentGrowth >= growthStageThreshold[Maturing] {
+	if p.Dead() || p.CurrentGrowth() >= growthStageThreshold[Maturing] {
 		return 0
 	}
 
-	remainingGrowth := growthStageThreshold[Maturing] - p.Health.CurrentGrowth
-	daysRemaining := float64(remainingGrowth) / float64(p.Variety.GrowthRatePerDay)
+	remainingGrowth := float64(growthStageThreshold[Maturing]) - p.Health.CurrentGrowth
+	daysRemaining := remainingGrowth / float64(p.Variety.GrowthRatePerDay)
 	return int(math.Ceil(daysRemaining))
 }
 
-// Image returns the filename for the plant's image
+// imageDateLayout is the date with which each of a plant's daily image file names begins
+const imageDateLayout = "2006-01-02"
+
+// Image returns the filename for the plant's image on the day it was last updated, the image of its current state
 func (p *Plant) Image() string {
-	formattedDate := time.Now().Format("2006-01-02")
+	return p.ImageOn(p.LastUpdated)
+}
+
+// ImageOn returns the filename for the plant's image on the given day
+func (p *Plant) ImageOn(date time.Time) string {
+	formattedDate := date.Format(imageDateLayout)
 	return fmt.Sprintf("%s-%s.png", formattedDate, p.Id)
 }
 
+// ImageDate returns the date of a daily image from its file name, and false if the file name isn't dated
+func ImageDate(fileName string) (time.Time, bool) {
+	if len(fileName) < len(imageDateLayout) {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(imageDateLayout, fileName[:len(imageDateLayout)])
+	return date, err == nil
+}
+
 func (p *Plant) DaysAlive() int {
 	currentTime := p.LastUpdated
+	if p.Dead() {
+		currentTime = p.DiedAt
+	}
 	elapsed := currentTime.Sub(p.CreationTime)
 
 	// Day 1 is the creation day, no matter what time
@@ -125,21 +175,66 @@ func (p *Plant) DaysAlive() int {
 	return int(days)
 }
 
-// AddWater fully waters the plant
-func (p *Plant) AddWater() int {
-	amountAdded := 100 - p.Health.CurrentWaterLevel
-	p.Health.CurrentWaterLevel = 100
-	return amountAdded
+// AddWater adds up to amount units of water to the plant, bounded by the capacity of the pot, and returns the
+// amount actually added. Watering a plant above its minimum water level ends any drought, watering it above its
+// maximum water level overwaters it. Dead plants cannot be watered.
+func (p *Plant) AddWater(amount int) int {
+	if amount <= 0 {
+		return 0
+	}
+	return int(math.Round(p.addWater(float64(amount))))
+}
+
+// TopUp waters the plant up to its variety's maximum water level, returning the amount added.
+func (p *Plant) TopUp() int {
+	return int(math.Round(p.addWater(float64(p.MaximumWaterLevel()) - p.Health.CurrentWaterLevel)))
+}
+
+// WaterNeeded returns the amount of water required to top the plant up to its variety's maximum water level.
+func (p *Plant) WaterNeeded() int {
+	if p.Dead() {
+		return 0
+	}
+	return max(p.MaximumWaterLevel()-p.CurrentWaterLevel(), 0)
+}
+
+func (p *Plant) addWater(amount float64) float64 {
+	if p.Dead() || amount <= 0 {
+		return 0
+	}
+
+	amount = math.Min(amount, MaxWaterCapacity-p.Health.CurrentWaterLevel)
+	p.Health.CurrentWaterLevel += amount
+	if p.Healthy() {
+		p.Health.DroughtSince = time.Time{}
+	}
+	if p.Overwatered() && p.Health.OverwateredSince.IsZero() {
+		p.Health.OverwateredSince = p.LastUpdated
+	}
+	return amount
+}
+
+// MaximumWaterLevel returns the water level above which the plant is overwatered.
+func (p *Plant) MaximumWaterLevel() int {
+	if p.Variety.MaximumWaterLevel <= 0 {
+		return MaxWaterCapacity
+	}
+	return min(p.Variety.MaximumWaterLevel, MaxWaterCapacity)
 }
 
 func (p *Plant) CurrentWaterLevel() int {
-	return p.Health.CurrentWaterLevel
+	return int(math.Round(p.Health.CurrentWaterLevel))
 }
 
-func (p *Plant) CurrentGrowth() int64 { return p.Health.CurrentGrowth }
+func (p *Plant) CurrentGrowth() int64 { return int64(math.Round(p.Health.CurrentGrowth)) }
 
 func (p *Plant) Healthy() bool {
-	return p.CurrentWaterLevel() >= p.Variety.MinimumWaterLevel
+	return p.Health.CurrentWaterLevel >= float64(p.Variety.MinimumWaterLevel)
+}
+
+// Overwatered returns true while the plant's water level is above its variety's maximum water level.
+func (p *Plant) Overwatered() bool {
+	return p.Health.CurrentWaterLevel > float64(p.MaximumWaterLevel())
 }
 
 // Validate checks if the plant has valid data
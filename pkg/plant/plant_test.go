@@ -3,6 +3,7 @@ package plant_test
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/clock"
 	"github.com/williamnoble/kube-botany/pkg/plant"
 	"github.com/williamnoble/kube-botany/pkg/repository"
 	"testing"
@@ -18,9 +19,11 @@ func newInMemoryStore(t *testing.T) repository.PlantRepository {
 }
 
 func testPlant(t *testing.T) (*plant.Plant, time.Time) {
-	s := newInMemoryStore(t)
 	currentTime := time.Now()
-	_, err := s.NewPlant("FooPlant", "MyBonsai", "bonsai", currentTime)
+	// the store's clock is frozen, so that reading the plant back doesn't bring it up to date
+	s, err := repository.New(repository.Options{VarietiesPath: "varieties.json", Clock: clock.NewFrozen(currentTime)})
+	require.NoError(t, err)
+	_, err = s.NewPlant("FooPlant", "MyBonsai", "bonsai", currentTime)
 	require.NoError(t, err)
 	p, err := s.GetPlant("FooPlant")
 	require.Equal(t, currentTime, p.LastUpdated)
@@ -28,18 +31,43 @@ func testPlant(t *testing.T) (*plant.Plant, time.Time) {
 	return p, currentTime
 }
 
+// newTestPlant creates a plant of the given variety with the given water level.
+func newTestPlant(t *testing.T, variety string, waterLevel float64) (*plant.Plant, time.Time) {
+	t.Helper()
+	s := newInMemoryStore(t)
+	currentTime := time.Now()
+	p, err := s.NewPlant("FooPlant", "MyPlant", variety, currentTime)
+	require.NoError(t, err)
+	p.Health.CurrentWaterLevel = waterLevel
+	return p, currentTime
+}
+
 func TestWater(t *testing.T) {
 	t.Parallel()
 	p, currentTime := testPlant(t)
 
-	// water to 100%
-	p.AddWater()
+	// a bonsai starts with 50 units of water and is overwatered above 80 units
+	assert.Equal(t, 80, p.MaximumWaterLevel())
+	assert.Equal(t, 30, p.WaterNeeded())
+
+	// add a measured amount of water
+	assert.Equal(t, 10, p.AddWater(10))
 	assert.Equal(t, currentTime, p.LastUpdated)
-	assert.Equal(t, 100, p.CurrentWaterLevel())
+	assert.Equal(t, 60, p.CurrentWaterLevel())
+	assert.Equal(t, 0, p.AddWater(-10))
 
-	// try to water above 100%
-	p.AddWater()
+	// top up to the maximum
+	assert.Equal(t, 20, p.TopUp())
+	assert.Equal(t, 80, p.CurrentWaterLevel())
+	assert.Equal(t, 0, p.WaterNeeded())
+	assert.False(t, p.Overwatered())
+	assert.Equal(t, 0, p.TopUp())
+
+	// water above the maximum, the pot holds at most 100 units
+	assert.Equal(t, 20, p.AddWater(50))
 	assert.Equal(t, 100, p.CurrentWaterLevel())
+	assert.True(t, p.Overwatered())
+	assert.Equal(t, plant.ConditionOverwatered, p.Condition())
 }
 
 func TestUpdateWaterConsumption(t *testing.T) {
@@ -72,6 +100,9 @@ func TestUpdateGrowth(t *testing.T) {
 	assert.Equal(t, p.DaysToMaturity(), 47)
 	assert.Equal(t, 3, p.DaysAlive())
 
+	// keep the plant watered so it doesn't go through a drought
+	p.TopUp()
+
 	// bonsai grows 5 units per day and 150 units in 30 days; it's now growing
 	// it fully matures in 20 days
 	dayThirty := currentTime.Add(24 * time.Hour * 30)
@@ -80,6 +111,7 @@ func TestUpdateGrowth(t *testing.T) {
 	assert.Equal(t, plant.Growing.String(), p.GrowthStage())
 	assert.Equal(t, p.DaysToMaturity(), 20)
 	assert.Equal(t, 30, p.DaysAlive())
+	p.TopUp()
 
 	// bonsai grows 5 units per day and 250 units in 50 days, it's fully matured
 	dayFifty := currentTime.Add(24 * time.Hour * 50)
@@ -94,3 +126,222 @@ func TestUpdate(t *testing.T) {
 	p, currentTime := testPlant(t)
 	assert.Equal(t, currentTime, p.LastUpdated)
 }
+
+func TestDrought(t *testing.T) {
+	t.Parallel()
+	p, currentTime := testPlant(t)
+	day := func(d float64) time.Time {
+		return currentTime.Add(time.Duration(d * 24 * float64(time.Hour)))
+	}
+
+	// a bonsai starts with 50 units of water, consumes 2 units per day and needs at least 10 units,
+	// its water level drops below the minimum after 20 days, it then has 4 days grace before it wilts
+	p.Update(day(20))
+	assert.Equal(t, plant.ConditionHealthy, p.Condition())
+	assert.True(t, p.Health.DroughtSince.IsZero())
+
+	// the plant stops growing as soon as it is short of water
+	p.Update(day(22))
+	assert.Equal(t, plant.ConditionThirsty, p.Condition())
+	assert.True(t, day(20).Equal(p.Health.DroughtSince))
+	assert.Equal(t, int(plant.MaxVitality), p.Vitality())
+	assert.Equal(t, int64(100), p.CurrentGrowth())
+	assert.False(t, p.Healthy())
+
+	// once the grace period expires (24 days) it loses 25 vitality per day
+	p.Update(day(26))
+	assert.Equal(t, plant.ConditionWilting, p.Condition())
+	assert.Equal(t, 50, p.Vitality())
+	assert.Equal(t, int64(100), p.CurrentGrowth())
+	assert.Equal(t, plant.Sprouting.String(), p.GrowthStage())
+
+	// it dies two days later, death is final
+	p.Update(day(40))
+	assert.Equal(t, plant.ConditionDead, p.Condition())
+	assert.Equal(t, plant.Dead.String(), p.GrowthStage())
+	assert.True(t, day(28).Equal(p.DiedAt))
+	assert.Equal(t, 0, p.Vitality())
+	assert.Equal(t, int64(100), p.CurrentGrowth())
+	assert.Equal(t, 0, p.DaysToMaturity())
+	assert.Equal(t, 28, p.DaysAlive())
+	assert.Equal(t, 0, p.AddWater(10))
+}
+
+func TestDroughtRecovery(t *testing.T) {
+	t.Parallel()
+	p, currentTime := testPlant(t)
+
+	// let the plant wilt for a day (drought at 20 days, wilting from 24 days)
+	p.Update(currentTime.Add(24 * time.Hour * 25))
+	assert.Equal(t, plant.ConditionWilting, p.Condition())
+	vitality := p.Vitality()
+	assert.Less(t, vitality, int(plant.MaxVitality))
+
+	// watering ends the drought, the plant regains vitality and resumes growing
+	p.TopUp()
+	assert.Equal(t, plant.ConditionHealthy, p.Condition())
+	growth := p.CurrentGrowth()
+	p.Update(currentTime.Add(24 * time.Hour * 26))
+	assert.Greater(t, p.Vitality(), vitality)
+	assert.Greater(t, p.CurrentGrowth(), growth)
+}
+
+func TestOverwatering(t *testing.T) {
+	t.Parallel()
+	day := func(start time.Time, d float64) time.Time {
+		return start.Add(time.Duration(d * 24 * float64(time.Hour)))
+	}
+
+	t.Run("recovers", func(t *testing.T) {
+		t.Parallel()
+		p, currentTime := testPlant(t)
+
+		// 85 units is 5 units above the maximum, a bonsai consumes 2 units per day so it is overwatered for 2.5 days,
+		// its roots rot for half a day once the 2 day grace period expires
+		p.AddWater(35)
+		p.Update(day(currentTime, 1))
+		assert.Equal(t, plant.ConditionOverwatered, p.Condition())
+		assert.Equal(t, int(plant.MaxVitality), p.Vitality())
+
+		// the plant doesn't grow while its roots rot, it recovers once the excess water is consumed
+		p.Update(day(currentTime, 3))
+		assert.Equal(t, plant.ConditionHealthy, p.Condition())
+		assert.True(t, p.Health.OverwateredSince.IsZero())
+		assert.InDelta(t, 95, p.Health.Vitality, 0.001)
+		assert.InDelta(t, 12.5, p.Health.CurrentGrowth, 0.001)
+	})
+
+	t.Run("dies of root rot", func(t *testing.T) {
+		t.Parallel()
+		p, currentTime := testPlant(t)
+
+		// 100 units is 20 units above the maximum, the plant is overwatered for 10 days
+		p.AddWater(50)
+		p.Update(day(currentTime, 4))
+		assert.Equal(t, plant.ConditionRootRot, p.Condition())
+		assert.Equal(t, 60, p.Vitality())
+		assert.Equal(t, int64(10), p.CurrentGrowth())
+
+		// it loses 20 vitality per day and dies 5 days after its roots start to rot
+		p.Update(day(currentTime, 12))
+		assert.Equal(t, plant.ConditionDead, p.Condition())
+		assert.Equal(t, plant.Dead.String(), p.GrowthStage())
+		assert.True(t, day(currentTime, 7).Equal(p.DiedAt))
+		assert.Equal(t, int64(10), p.CurrentGrowth())
+	})
+}
+
+func TestStressAwareGrowth(t *testing.T) {
+	t.Parallel()
+	const day = 24 * time.Hour
+
+	// every returns update offsets at each step up to and including total
+	every := func(step, total time.Duration) []time.Duration {
+		var offsets []time.Duration
+		for offset := step; offset <= total; offset += step {
+			offsets = append(offsets, offset)
+		}
+		return offsets
+	}
+
+	tests := []struct {
+		name       string
+		variety    string
+		waterLevel float64
+		updates    []time.Duration // offsets from the creation time at which the plant is updated
+		wantGrowth int64
+		wantWater  int
+		wantStage  plant.GrowthStage
+	}{
+		{
+			// sunflowers grow 10 units a day and consume 6 units of water a day
+			name:       "watered throughout a multi-day gap",
+			variety:    "sunflower",
+			waterLevel: 100,
+			updates:    []time.Duration{5 * day},
+			wantGrowth: 50,
+			wantWater:  70,
+			wantStage:  plant.Sprouting,
+		},
+		{
+			// the water level drops below the minimum of 50 after 8⅓ days, two days later it wilts
+			name:       "dries out part way through a multi-day gap",
+			variety:    "sunflower",
+			waterLevel: 100,
+			updates:    []time.Duration{12 * day},
+			wantGrowth: 83,
+			wantWater:  28,
+			wantStage:  plant.Sprouting,
+		},
+		{
+			name:       "bone dry sunflower does not grow",
+			variety:    "sunflower",
+			waterLevel: 40,
+			updates:    []time.Duration{3 * day},
+			wantGrowth: 0,
+			wantWater:  22,
+			wantStage:  plant.Seeding,
+		},
+		{
+			name:       "bone dry sunflower does not mature on schedule",
+			variety:    "sunflower",
+			waterLevel: 0,
+			updates:    []time.Duration{25 * day},
+			wantGrowth: 0,
+			wantWater:  0,
+			wantStage:  plant.Dead,
+		},
+		{
+			// bonsais grow 5 units a day and drop below the minimum water level after 20 days, they wilt
+			// from day 24 and die on day 28
+			name:       "single multi-week gap",
+			variety:    "bonsai",
+			waterLevel: 50,
+			updates:    []time.Duration{26 * day},
+			wantGrowth: 100,
+			wantWater:  0,
+			wantStage:  plant.Sprouting,
+		},
+		{
+			name:       "daily updates match a single multi-week gap",
+			variety:    "bonsai",
+			waterLevel: 50,
+			updates:    every(day, 26*day),
+			wantGrowth: 100,
+			wantWater:  0,
+			wantStage:  plant.Sprouting,
+		},
+		{
+			name:       "irregular gaps",
+			variety:    "bonsai",
+			waterLevel: 50,
+			updates:    []time.Duration{36 * time.Hour, 7 * day, 19*day + 12*time.Hour, 26 * day},
+			wantGrowth: 100,
+			wantWater:  0,
+			wantStage:  plant.Sprouting,
+		},
+		{
+			name:       "frequent updates are not rounded away",
+			variety:    "bonsai",
+			waterLevel: 50,
+			updates:    every(5*time.Minute, day),
+			wantGrowth: 5,
+			wantWater:  48,
+			wantStage:  plant.Seeding,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, creationTime := newTestPlant(t, tt.variety, tt.waterLevel)
+			for _, offset := range tt.updates {
+				p.Update(creationTime.Add(offset))
+			}
+
+			assert.Equal(t, tt.wantGrowth, p.CurrentGrowth())
+			assert.Equal(t, tt.wantWater, p.CurrentWaterLevel())
+			assert.Equal(t, tt.wantStage.String(), p.GrowthStage())
+		})
+	}
+}
@@ -12,7 +12,9 @@ type Variety struct {
 	GrowthRatePerDay            int64  `json:"growth_rate"`       // between 4-6 weeks at max growth
 	WaterConsumptionUnitsPerDay int64  `json:"water_consumption"` // 0-1 scale per day
 	MinimumWaterLevel           int    `json:"minimum_water_level"`
-	Type                        string `json:"type,omitempty"` // duplicates key e.g. "bonsai"
+	MaximumWaterLevel           int    `json:"maximum_water_level"` // above this level the plant is overwatered, 100 if unset
+	DroughtGraceDays            int    `json:"drought_grace_days"`  // days below minimum water level before wilting
+	Type                        string `json:"type,omitempty"`      // duplicates key e.g. "bonsai"
 }
 
 type Varieties = map[string]Variety
@@ -18,7 +18,9 @@ func createTempVarietyFile(t *testing.T) (string, func()) {
         "bonsai": {
             "growth_rate": 1,
             "water_consumption": 2,
-            "minimum_water_level": 20
+            "minimum_water_level": 20,
+            "maximum_water_level": 70,
+            "drought_grace_days": 3
         }
     }`
 
@@ -46,5 +48,7 @@ func TestVarietiesFromJson(t *testing.T) {
 	assert.Equal(t, varieties["bonsai"].GrowthRatePerDay, int64(1))
 	assert.Equal(t, varieties["bonsai"].WaterConsumptionUnitsPerDay, int64(2))
 	assert.Equal(t, varieties["bonsai"].MinimumWaterLevel, int(20))
+	assert.Equal(t, varieties["bonsai"].MaximumWaterLevel, 70)
+	assert.Equal(t, varieties["bonsai"].DroughtGraceDays, 3)
 	assert.Equal(t, varieties["bonsai"].Type, "bonsai") // added field
 }
@@ -3,6 +3,7 @@ package render
 import (
 	"fmt"
 	"github.com/williamnoble/kube-botany/pkg/plant"
+	"strings"
 )
 
 // ASCIIRenderer renders the plant as ASCII art
@@ -13,30 +14,56 @@ func NewASCIIRenderer() *ASCIIRenderer {
 	return &ASCIIRenderer{}
 }
 
-// RenderText renders the plant as ASCII art depending on its growth stage
+// ContentType returns the media type of the ASCII art
+func (r *ASCIIRenderer) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// Render renders the plant as ASCII art, see RenderText
+func (r *ASCIIRenderer) Render(p *plant.Plant) ([]byte, error) {
+	return []byte(r.RenderText(p)), nil
+}
+
+// RenderColor renders the plant as ASCII art coloured for terminals, see RenderText
+func (r *ASCIIRenderer) RenderColor(p *plant.Plant) ([]byte, error) {
+	return []byte(r.renderText(p, true)), nil
+}
+
+// RenderText renders the plant as ASCII art depending on its variety and growth stage
 func (r *ASCIIRenderer) RenderText(p *plant.Plant) string {
-	asciiArt := map[string]string{
-		"seeding":   seeding,
-		"sprouting": sprouting,
-		"growing":   growing,
-		"maturing":  maturing,
-		"dead":      dead,
+	return r.renderText(p, false)
+}
+
+// renderText renders the plant as ASCII art, optionally coloured with ANSI escape codes: the art and growth stage
+// in the colour of the stage and the water level in blue
+func (r *ASCIIRenderer) renderText(p *plant.Plant, color bool) string {
+	stage := plant.GrowthStage(p.GrowthStage())
+	paint := func(text, code string) string { return text }
+	if color {
+		paint = ansiPaint
+	}
+	stageColor := ansiColors[p.RenderGrowthStage(stage).ColorClass]
+
+	variety := ""
+	if p.Variety != nil {
+		variety = p.Variety.Type
 	}
 
 	var text string
 	text += fmt.Sprintf("\nName: %s\n", p.Id)
 	text += fmt.Sprintf("FriendlyName: %s\n", p.FriendlyName)
-	waterBar := renderBar("Water Level:", p.CurrentWaterLevel(), 100)
+	waterBar := renderBar("Water Level", p.CurrentWaterLevel(), 100, func(bar string) string { return paint(bar, ansiBlue) })
 	text += waterBar + "\n"
-	text += fmt.Sprintf("Growth Stage: %s\n", p.GrowthStage())
+	text += fmt.Sprintf("Growth Stage: %s\n", paint(stage.String(), stageColor))
 	text += fmt.Sprintf("Created: %s\n", p.HumanCreationTime())
 	text += fmt.Sprintf("Day: %d (%d days to maturity)\n", p.DaysAlive(), p.DaysToMaturity())
 	text += "Image" + "\n"
-	text += asciiArt[p.GrowthStage()]
+	text += paint(asciiArt(variety, stage), stageColor)
 	return text
 }
 
-func renderBar(label string, value, max int) string {
+// renderBar renders a bar filled in proportion to value, fill styles the filled part of the bar
+func renderBar(label string, value, max int, fill func(string) string) string {
 	const barWidth = 20
 	filled := int(float64(value) / float64(max) * float64(barWidth))
 	if filled < 0 {
@@ -46,13 +73,37 @@ func renderBar(label string, value, max int) string {
 	}
 
 	bar := label + ": ["
-	for i := 0; i < barWidth; i++ {
-		if i < filled {
-			bar += "#"
-		} else {
-			bar += " "
-		}
-	}
+	bar += fill(strings.Repeat("#", filled))
+	bar += strings.Repeat(" ", barWidth-filled)
 	bar += "] " + fmt.Sprintf("%d%%", value)
 	return bar
 }
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBlue  = "\x1b[34m"
+)
+
+// ansiColors are the ANSI escape codes of the growth stages' ColorClass
+var ansiColors = map[string]string{
+	"yellow":  "\x1b[33m",
+	"lime":    "\x1b[92m",
+	"green":   "\x1b[32m",
+	"emerald": "\x1b[36m",
+	"red":     "\x1b[31m",
+}
+
+// ansiPaint colours each line of the text, so that the colour survives pagers and terminals which reset it at the
+// end of each line
+func ansiPaint(text, code string) string {
+	if code == "" {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = code + line + ansiReset
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,5 +1,9 @@
 package render
 
+import (
+	"github.com/williamnoble/kube-botany/pkg/plant"
+)
+
 const (
 	seeding = `
     .
@@ -43,3 +47,186 @@ const (
 /           \
 `
 )
+
+// varietyArt is the ASCII art of each growth stage of a variety, stages without their own art use the generic art
+// above.
+var varietyArt = map[string]map[plant.GrowthStage]string{
+	"aloe_vera": {
+		plant.Growing: `
+   \ | /
+  \ \|/ /
+ __\\|//__
+/         \
+`,
+		plant.Maturing: `
+  \  |  /
+ \ \ | / /
+  \ \|/ /
+ -\\\|///-
+ ___\|/___
+/         \
+`,
+		plant.Dead: `
+ _   .   _
+  '\ | /'
+ ~~ \|/ ~~
+ ____|____
+/         \
+`,
+	},
+	"bamboo": {
+		plant.Growing: `
+   \  |
+   |= |=
+   |  |
+   |= |=
+ __|__|__
+/        \
+`,
+		plant.Maturing: `
+  \|/ \|/
+   |=  |=  \|
+   |   |   |=
+   |=  |=  |
+   |   |   |=
+   |=  |=  |
+ __|___|___|__
+/             \
+`,
+		plant.Dead: `
+   _ /
+   |= \  |
+   |   \ |=
+   |=  | |
+ __|___|_|__
+/           \
+`,
+	},
+	"bonsai": {
+		plant.Growing: `
+   .oOo.
+    \|
+     \
+     |
+  ___|___
+ /       \
+`,
+		plant.Maturing: `
+  .oOOo.  .oOo.
+ (oOOOOo)(oOOo)
+   '--\ /'-/
+       \ /
+       |/
+    ___|___
+   /       \
+`,
+		plant.Dead: `
+    \  /
+     \/  /
+      \ /
+      |/
+   ___|___
+  /       \
+`,
+	},
+	"cactus": {
+		plant.Growing: `
+    _
+   | |
+   | |
+ __|_|__
+/       \
+`,
+		plant.Maturing: `
+      *
+     ___
+  _ |   | _
+ | ||   || |
+ |_||   ||_|
+    |   |
+  __|___|__
+ /         \
+`,
+		plant.Dead: `
+      ___
+   __/ x \
+  (__     |
+     |  x |
+   __|____|__
+  /          \
+`,
+	},
+	"orchid": {
+		plant.Growing: `
+      _
+     /
+    |
+  \_|_/
+ ___|___
+/       \
+`,
+		plant.Maturing: `
+        @
+     _@
+    / @
+   |
+ \_|_/
+ __|____
+/       \
+`,
+		plant.Dead: `
+   _
+  / \
+ x  |
+  \_|_/
+ ___|___
+/       \
+`,
+	},
+	"sunflower": {
+		plant.Growing: `
+    (o)
+   \ | /
+    \|/
+     |
+  ___|___
+ /       \
+`,
+		plant.Maturing: `
+   \ | /
+  -- @ --
+   / | \
+  \  |  /
+   \ | /
+    \|/
+  ___|___
+ /       \
+`,
+		plant.Dead: `
+       __
+    .-'  @
+   /
+   |  x
+   | /
+ __|____
+/       \
+`,
+	},
+}
+
+// genericArt is the ASCII art of each growth stage of varieties without their own art.
+var genericArt = map[plant.GrowthStage]string{
+	plant.Seeding:   seeding,
+	plant.Sprouting: sprouting,
+	plant.Growing:   growing,
+	plant.Maturing:  maturing,
+	plant.Dead:      dead,
+}
+
+// asciiArt returns the ASCII art of the variety at the growth stage, falling back to the generic art.
+func asciiArt(variety string, stage plant.GrowthStage) string {
+	if art, ok := varietyArt[variety][stage]; ok {
+		return art
+	}
+	return genericArt[stage]
+}
@@ -1,9 +1,13 @@
 package render
 
 import (
+	"flag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/plant"
 	"github.com/williamnoble/kube-botany/pkg/repository"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -27,9 +31,66 @@ func TestRenderTest(t *testing.T) {
 	output := r.RenderText(testBonsai)
 	assert.Contains(t, output, seeding)
 
-	// add 50 days to ensure the plant is fully matured
-	dayThirty := time.Now().Add(24 * time.Hour * 50)
-	testBonsai.Update(dayThirty)
+	// add 50 days to ensure the plant is fully matured, watering it along the way
+	testBonsai.TopUp()
+	testBonsai.Update(time.Now().Add(24 * time.Hour * 25))
+	testBonsai.TopUp()
+	dayFifty := time.Now().Add(24 * time.Hour * 50)
+	testBonsai.Update(dayFifty)
 	output = r.RenderText(testBonsai)
-	assert.Contains(t, output, maturing)
+	assert.Contains(t, output, asciiArt("bonsai", plant.Maturing))
+	assert.NotContains(t, output, maturing)
+}
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// assertGolden compares got with the golden file, or updates the golden file when the tests are run with -update.
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run go test ./pkg/render -update to create the golden file")
+	assert.Equal(t, string(want), got)
+}
+
+// stagePlants returns a plant of the variety at each growth stage.
+func stagePlants(variety string) []*plant.Plant {
+	var plants []*plant.Plant
+	for _, growth := range []float64{0, 60, 200, 300} {
+		plants = append(plants, testPlant("FooPlant", variety, plant.Health{CurrentGrowth: growth, CurrentWaterLevel: 50, Vitality: plant.MaxVitality}))
+	}
+	dead := testPlant("FooPlant", variety, plant.Health{CurrentGrowth: 300})
+	dead.DiedAt = dead.LastUpdated
+	return append(plants, dead)
+}
+
+func TestRenderTextGolden(t *testing.T) {
+	t.Parallel()
+	r := NewASCIIRenderer()
+
+	// each variety with its own art, and one without which falls back to the generic art
+	for _, variety := range []string{"aloe_vera", "bamboo", "bonsai", "cactus", "orchid", "sunflower", "fern"} {
+		t.Run(variety, func(t *testing.T) {
+			t.Parallel()
+			var output string
+			for _, p := range stagePlants(variety) {
+				p.FriendlyName = "My " + variety
+				output += r.RenderText(p)
+			}
+			assertGolden(t, filepath.Join("ascii", variety+".golden"), output)
+		})
+	}
+
+	var output string
+	for _, p := range stagePlants("bonsai") {
+		colored, err := r.RenderColor(p)
+		require.NoError(t, err)
+		output += string(colored)
+	}
+	assertGolden(t, filepath.Join("ascii", "color.golden"), output)
+	assert.Contains(t, output, ansiColors["emerald"]+"  .oOOo.  .oOo."+ansiReset)
 }
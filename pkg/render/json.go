@@ -0,0 +1,30 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/types"
+)
+
+// JSONRenderer renders the plant as the JSON of its PlantDTO, as returned by the API
+type JSONRenderer struct{}
+
+// NewJSONRenderer creates a new JSONRenderer
+func NewJSONRenderer() *JSONRenderer {
+	return &JSONRenderer{}
+}
+
+// ContentType returns the media type of the JSON
+func (r *JSONRenderer) ContentType() string {
+	return "application/json"
+}
+
+// Render renders the plant as JSON followed by a newline
+func (r *JSONRenderer) Render(p *plant.Plant) ([]byte, error) {
+	data, err := json.Marshal(types.IntoPlantDTO(p))
+	if err != nil {
+		return nil, fmt.Errorf("render: failed to encode %s as json: %w", p.Id, err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/types"
+	"strings"
+)
+
+// MarkdownRenderer renders the plant as a Markdown summary, a table of its state and its SVG image, for READMEs
+// and issues
+type MarkdownRenderer struct{}
+
+// NewMarkdownRenderer creates a new MarkdownRenderer
+func NewMarkdownRenderer() *MarkdownRenderer {
+	return &MarkdownRenderer{}
+}
+
+// ContentType returns the media type of the Markdown
+func (r *MarkdownRenderer) ContentType() string {
+	return "text/markdown; charset=utf-8"
+}
+
+// Render renders the plant as Markdown
+func (r *MarkdownRenderer) Render(p *plant.Plant) ([]byte, error) {
+	name := p.FriendlyName
+	if name == "" {
+		name = p.Id
+	}
+	variety := ""
+	if p.Variety != nil {
+		variety = p.Variety.Type
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", escapeMarkdown(name))
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Variety | %s |\n", escapeMarkdown(variety))
+	fmt.Fprintf(&b, "| Growth Stage | %s (%d%%) |\n", p.GrowthStage(), p.GrowthPercentage())
+	fmt.Fprintf(&b, "| Condition | %s |\n", p.Condition())
+	fmt.Fprintf(&b, "| Vitality | %d%% |\n", p.Vitality())
+	if p.Variety != nil {
+		fmt.Fprintf(&b, "| Water Level | %d%% (%d%%–%d%%) |\n", p.CurrentWaterLevel(), p.Variety.MinimumWaterLevel, p.MaximumWaterLevel())
+	} else {
+		fmt.Fprintf(&b, "| Water Level | %d%% |\n", p.CurrentWaterLevel())
+	}
+	fmt.Fprintf(&b, "| Created | %s |\n", p.HumanCreationTime())
+	fmt.Fprintf(&b, "| Day | %d (%d days to maturity) |\n", p.DaysAlive(), p.DaysToMaturity())
+	fmt.Fprintf(&b, "\n![%s](%s)\n", escapeMarkdown(name), types.FormatURL(p.Id, FormatSVG))
+	return []byte(b.String()), nil
+}
+
+// markdownEscaper escapes the characters which would otherwise be read as Markdown or break a table cell
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "|", `\|`, "#", `\#`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,479 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand/v2"
+)
+
+// DefaultPNGSize is the width and height in pixels of the images drawn by a PNGRenderer created with size 0.
+const DefaultPNGSize = 512
+
+// sceneSize is the width and height of the scene in scene units, scenes are drawn at any size by scaling.
+const sceneSize = 256
+
+// colorClasses are the colours of the growth stages' ColorClass, the Tailwind palette used by the UI.
+var colorClasses = map[string]color.RGBA{
+	"yellow":  {R: 0xfa, G: 0xcc, B: 0x15, A: 0xff},
+	"lime":    {R: 0xa3, G: 0xe6, B: 0x35, A: 0xff},
+	"green":   {R: 0x22, G: 0xc5, B: 0x5e, A: 0xff},
+	"emerald": {R: 0x10, G: 0xb9, B: 0x81, A: 0xff},
+	"red":     {R: 0xef, G: 0x44, B: 0x44, A: 0xff},
+}
+
+var (
+	skyColor      = color.RGBA{R: 0xf1, G: 0xf5, B: 0xf9, A: 0xff}
+	tableColor    = color.RGBA{R: 0x8b, G: 0x5e, B: 0x3c, A: 0xff}
+	potColor      = color.RGBA{R: 0xc2, G: 0x5b, B: 0x2c, A: 0xff}
+	potRimColor   = color.RGBA{R: 0xa8, G: 0x4a, B: 0x22, A: 0xff}
+	drySoilColor  = color.RGBA{R: 0xc8, G: 0xa2, B: 0x7a, A: 0xff}
+	wetSoilColor  = color.RGBA{R: 0x3b, G: 0x2a, B: 0x1e, A: 0xff}
+	waterColor    = color.RGBA{R: 0x38, G: 0x8b, B: 0xfd, A: 0xff}
+	gaugeColor    = color.RGBA{R: 0x47, G: 0x55, B: 0x69, A: 0xff}
+	healthyColor  = color.RGBA{R: 0x2f, G: 0x9e, B: 0x44, A: 0xff}
+	witheredColor = color.RGBA{R: 0xa1, G: 0x7a, B: 0x3a, A: 0xff}
+	deadColor     = color.RGBA{R: 0x78, G: 0x6a, B: 0x5a, A: 0xff}
+	barkColor     = color.RGBA{R: 0x6b, G: 0x48, B: 0x2b, A: 0xff}
+	petalColor    = color.RGBA{R: 0xfa, G: 0xcc, B: 0x15, A: 0xff}
+	bloomColor    = color.RGBA{R: 0xe8, G: 0x79, B: 0xc9, A: 0xff}
+	seedColor     = color.RGBA{R: 0x5c, G: 0x40, B: 0x33, A: 0xff}
+)
+
+// PNGRenderer draws a plant procedurally as a PNG, without an image provider. The plant is drawn in its pot
+// according to its variety and growth, its leaves coloured by its vitality and drooping with its condition, the
+// soil darkening with its water level and a gauge beside the pot showing the water level against the variety's
+// minimum and maximum. Drawing is deterministic: the same plant in the same state always gives the same image,
+// and the variations between plants of the same variety are seeded by the plant's ID.
+type PNGRenderer struct {
+	size int
+}
+
+// NewPNGRenderer creates a PNGRenderer drawing images size pixels square, DefaultPNGSize if size is 0.
+func NewPNGRenderer(size int) *PNGRenderer {
+	if size <= 0 {
+		size = DefaultPNGSize
+	}
+	return &PNGRenderer{size: size}
+}
+
+// ContentType returns the media type of the PNG.
+func (r *PNGRenderer) ContentType() string {
+	return "image/png"
+}
+
+// Render draws the plant as a PNG, see RenderPNG.
+func (r *PNGRenderer) Render(p *plant.Plant) ([]byte, error) {
+	return r.RenderPNG(p)
+}
+
+// RenderPNG draws the plant and encodes it as a PNG.
+func (r *PNGRenderer) RenderPNG(p *plant.Plant) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, r.Draw(p)); err != nil {
+		return nil, fmt.Errorf("render: failed to encode %s as png: %w", p.Id, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Draw draws the plant.
+func (r *PNGRenderer) Draw(p *plant.Plant) *image.RGBA {
+	c := newCanvas(r.size)
+	s := newScene(p)
+
+	c.background(s.tint)
+	c.fillRect(0, 216, sceneSize, sceneSize, tableColor)
+	if s.overwatered {
+		c.fillEllipse(128, 226, 84, 9, withAlpha(waterColor, 0x80))
+	}
+	c.fillPolygon(potColor, point{90, 224}, point{166, 224}, point{180, 172}, point{76, 172})
+	c.fillRect(70, 160, 186, 176, potRimColor)
+	c.fillEllipse(128, 162, 54, 7, s.soil)
+	if s.dry {
+		for range 4 {
+			x := 92 + s.rng.Float64()*64
+			c.line(x, 160+s.rng.Float64()*3, x+8-s.rng.Float64()*16, 163+s.rng.Float64()*3, 1.2, wetSoilColor)
+		}
+	}
+	s.draw(c)
+	c.gauge(p)
+
+	return c.img
+}
+
+// scene is everything about the plant which affects how it is drawn.
+type scene struct {
+	variety     string
+	stage       plant.GrowthStage
+	height      float64 // of the plant above the soil, in scene units
+	droop       float64 // 0 when upright, 1 when collapsed
+	leaf        color.RGBA
+	soil        color.RGBA
+	tint        color.RGBA // of the sky, from the growth stage
+	dry         bool
+	overwatered bool
+	rng         *rand.Rand
+}
+
+func newScene(p *plant.Plant) *scene {
+	stage := plant.GrowthStage(p.GrowthStage())
+	condition := p.Condition()
+
+	seed := fnv.New64a()
+	_, _ = seed.Write([]byte(p.Id))
+	s := &scene{
+		stage:  stage,
+		height: 14 + 106*float64(p.GrowthPercentage())/100,
+		tint:   mix(skyColor, colorClasses[p.RenderGrowthStage(stage).ColorClass], 0.25),
+		rng:    rand.New(rand.NewPCG(seed.Sum64(), 0x6b75626520626f74)),
+	}
+	if p.Variety != nil {
+		s.variety = p.Variety.Type
+	}
+
+	vitality := float64(p.Vitality()) / plant.MaxVitality
+	s.leaf = mix(witheredColor, healthyColor, vitality)
+	switch condition {
+	case plant.ConditionThirsty:
+		s.droop, s.dry = 0.25, true
+		s.leaf = mix(s.leaf, witheredColor, 0.2)
+	case plant.ConditionWilting:
+		s.droop, s.dry = 0.6, true
+	case plant.ConditionOverwatered:
+		s.droop, s.overwatered = 0.15, true
+	case plant.ConditionRootRot:
+		s.droop, s.overwatered = 0.5, true
+	case plant.ConditionDead:
+		s.droop = 1
+		s.leaf = deadColor
+	}
+
+	wetness := 0.0
+	if maximum := p.MaximumWaterLevel(); maximum > 0 {
+		wetness = min(float64(p.CurrentWaterLevel())/float64(maximum), 1)
+	}
+	s.soil = mix(drySoilColor, wetSoilColor, wetness)
+	return s
+}
+
+// draw draws the plant in the pot, its base at the middle of the soil.
+func (s *scene) draw(c *canvas) {
+	if s.stage == plant.Seeding {
+		c.fillEllipse(128, 160, 7, 5, seedColor)
+		if s.height > 16 {
+			stem := c.stem(point{128, 158}, s.height-6, 0.3*s.droop, 2, s.leaf)
+			c.leaf(stem[len(stem)-1], -1.1-s.droop, 8, 3, s.leaf)
+		}
+		return
+	}
+	if s.stage == plant.Sprouting {
+		s.drawGeneric(c)
+		return
+	}
+
+	switch s.variety {
+	case "cactus":
+		s.drawCactus(c)
+	case "sunflower":
+		s.drawSunflower(c)
+	case "bamboo":
+		s.drawBamboo(c)
+	case "bonsai":
+		s.drawBonsai(c)
+	case "orchid":
+		s.drawOrchid(c)
+	case "aloe_vera":
+		s.drawAloe(c)
+	default:
+		s.drawGeneric(c)
+	}
+}
+
+// lean returns the angle at which a stem leans at its tip, to a side chosen by the plant's ID.
+func (s *scene) lean(upright float64) float64 {
+	side := 1.0
+	if s.rng.IntN(2) == 0 {
+		side = -1
+	}
+	return side * (upright + 1.6*s.droop)
+}
+
+// leafAngle returns the angle of a leaf growing to the given side, lowered as the plant droops.
+func (s *scene) leafAngle(side, angle float64) float64 {
+	return side * (angle + 1.2*s.droop)
+}
+
+func (s *scene) drawGeneric(c *canvas) {
+	stem := c.stem(point{128, 160}, s.height, s.lean(0.1), 3, s.leaf)
+	pairs := 1 + int(s.height/32)
+	for i := range pairs {
+		at := stem[len(stem)-1-i*(len(stem)-1)/(pairs+1)]
+		length := 10 + s.height/7 + s.rng.Float64()*4
+		c.leaf(at, s.leafAngle(-1, 0.9), length, length/3, s.leaf)
+		c.leaf(at, s.leafAngle(1, 0.9), length, length/3, s.leaf)
+	}
+}
+
+func (s *scene) drawCactus(c *canvas) {
+	width := 12 + s.height/8
+	top := c.stem(point{128, 164}, s.height, s.lean(0)*0.4, width, s.leaf)
+	if s.height > 70 {
+		for _, side := range []float64{-1, 1} {
+			y := 160 - s.height*(0.35+0.2*s.rng.Float64())
+			elbow := point{128 + side*(width/2+12), y}
+			c.line(128, y, elbow.x, elbow.y, width*0.6, s.leaf)
+			c.stem(elbow, s.height*0.3, side*0.8*s.droop, width*0.6, s.leaf)
+		}
+	}
+	spines := mix(s.leaf, skyColor, 0.6)
+	for range int(s.height / 4) {
+		y := 160 - s.rng.Float64()*s.height*0.9
+		x := 128 + (s.rng.Float64()-0.5)*width*0.8
+		c.fillEllipse(x, y, 0.9, 0.9, spines)
+	}
+	if s.stage == plant.Maturing {
+		tip := top[len(top)-1]
+		c.flower(tip, 5, 7, bloomColor, petalColor)
+	}
+}
+
+func (s *scene) drawSunflower(c *canvas) {
+	stem := c.stem(point{128, 160}, s.height, s.lean(0.05), 4, s.leaf)
+	for i := 1; i < len(stem)-1; i += 2 {
+		side := 1.0
+		if i%4 == 1 {
+			side = -1
+		}
+		c.leaf(stem[i], s.leafAngle(side, 1.1), 14+s.height/8, 8, s.leaf)
+	}
+	head := stem[len(stem)-1]
+	switch {
+	case s.stage == plant.Dead:
+		c.fillEllipse(head.x, head.y+4, 10, 8, deadColor)
+	case s.stage == plant.Maturing:
+		c.flower(head, 14+s.height/12, 18, petalColor, seedColor)
+	default:
+		c.fillEllipse(head.x, head.y, 7, 8, mix(s.leaf, healthyColor, 0.5))
+	}
+}
+
+func (s *scene) drawBamboo(c *canvas) {
+	for i, offset := range []float64{-16, 0, 16} {
+		height := s.height * (0.7 + 0.3*s.rng.Float64())
+		if i == 1 {
+			height = s.height
+		}
+		stalk := c.stem(point{128 + offset, 160}, height, offset/40+s.lean(0)*0.5, 6, s.leaf)
+		node := mix(s.leaf, barkColor, 0.5)
+		for j := 1; j < len(stalk)-1; j++ {
+			c.line(stalk[j].x-4, stalk[j].y, stalk[j].x+4, stalk[j].y, 1.5, node)
+		}
+		tip := stalk[len(stalk)-1]
+		c.leaf(tip, s.leafAngle(-1, 0.7), 16, 4, s.leaf)
+		c.leaf(tip, s.leafAngle(1, 0.8), 14, 4, s.leaf)
+	}
+}
+
+func (s *scene) drawBonsai(c *canvas) {
+	trunk := c.stem(point{128, 160}, s.height*0.7, s.lean(0.5), 4+s.height/16, barkColor)
+	if s.stage == plant.Dead {
+		return
+	}
+	crown := trunk[len(trunk)-1]
+	radius := 10 + s.height/5
+	for range 4 {
+		dx := (s.rng.Float64() - 0.5) * radius * 1.6
+		dy := (s.rng.Float64() - 0.5) * radius * 0.5
+		c.fillEllipse(crown.x+dx, crown.y+dy+radius*0.6*s.droop, radius*0.7, radius*0.45, s.leaf)
+	}
+}
+
+func (s *scene) drawOrchid(c *canvas) {
+	for _, side := range []float64{-1, 1} {
+		c.leaf(point{128, 159}, s.leafAngle(side, 1.2), 18+s.height/8, 8, s.leaf)
+	}
+	spike := c.stem(point{128, 158}, s.height, s.lean(0.6), 2, mix(s.leaf, barkColor, 0.3))
+	if s.stage != plant.Maturing {
+		return
+	}
+	for i := len(spike) / 2; i < len(spike); i += 2 {
+		c.flower(spike[i], 5, 5, bloomColor, petalColor)
+	}
+}
+
+func (s *scene) drawAloe(c *canvas) {
+	leaves := 5 + int(s.height/25)
+	for i := range leaves {
+		angle := -1.3 + 2.6*float64(i)/float64(leaves-1)
+		angle += math.Copysign(0.6*s.droop, angle)
+		length := (0.5 + 0.3*s.rng.Float64()) * s.height * (1 - 0.3*s.droop)
+		c.leaf(point{128, 160}, angle, length, 4+length/12, s.leaf)
+	}
+}
+
+// point is a position in scene units.
+type point struct {
+	x, y float64
+}
+
+// canvas draws shapes given in scene units onto an image.
+type canvas struct {
+	img   *image.RGBA
+	scale float64 // pixels per scene unit
+}
+
+func newCanvas(size int) *canvas {
+	return &canvas{img: image.NewRGBA(image.Rect(0, 0, size, size)), scale: float64(size) / sceneSize}
+}
+
+// background fades from the tint at the top of the image to the sky colour.
+func (c *canvas) background(tint color.RGBA) {
+	b := c.img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := mix(tint, skyColor, float64(y)/float64(b.Dy()))
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c.img.SetRGBA(x, y, row)
+		}
+	}
+}
+
+// fill sets every pixel whose centre lies inside the shape, tested in scene units, within the bounds.
+func (c *canvas) fill(x0, y0, x1, y1 float64, col color.RGBA, inside func(x, y float64) bool) {
+	bounds := image.Rect(
+		int(math.Floor(x0*c.scale)), int(math.Floor(y0*c.scale)),
+		int(math.Ceil(x1*c.scale)), int(math.Ceil(y1*c.scale)),
+	).Intersect(c.img.Bounds())
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			if inside((float64(px)+0.5)/c.scale, (float64(py)+0.5)/c.scale) {
+				c.img.SetRGBA(px, py, over(c.img.RGBAAt(px, py), col))
+			}
+		}
+	}
+}
+
+func (c *canvas) fillRect(x0, y0, x1, y1 float64, col color.RGBA) {
+	c.fill(x0, y0, x1, y1, col, func(x, y float64) bool { return true })
+}
+
+func (c *canvas) fillEllipse(cx, cy, rx, ry float64, col color.RGBA) {
+	c.fill(cx-rx, cy-ry, cx+rx, cy+ry, col, func(x, y float64) bool {
+		dx, dy := (x-cx)/rx, (y-cy)/ry
+		return dx*dx+dy*dy <= 1
+	})
+}
+
+// fillPolygon fills the polygon using the even-odd rule.
+func (c *canvas) fillPolygon(col color.RGBA, pts ...point) {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range pts {
+		minX, minY, maxX, maxY = min(minX, p.x), min(minY, p.y), max(maxX, p.x), max(maxY, p.y)
+	}
+	c.fill(minX, minY, maxX, maxY, col, func(x, y float64) bool {
+		inside := false
+		for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+			a, b := pts[i], pts[j]
+			if (a.y > y) != (b.y > y) && x < (b.x-a.x)*(y-a.y)/(b.y-a.y)+a.x {
+				inside = !inside
+			}
+		}
+		return inside
+	})
+}
+
+// line draws a line of the given width with rounded ends.
+func (c *canvas) line(x0, y0, x1, y1, width float64, col color.RGBA) {
+	r := width / 2
+	dx, dy := x1-x0, y1-y0
+	length := dx*dx + dy*dy
+	c.fill(min(x0, x1)-r, min(y0, y1)-r, max(x0, x1)+r, max(y0, y1)+r, col, func(x, y float64) bool {
+		t := 0.0
+		if length > 0 {
+			t = max(0, min(1, ((x-x0)*dx+(y-y0)*dy)/length))
+		}
+		px, py := x-(x0+t*dx), y-(y0+t*dy)
+		return px*px+py*py <= r*r
+	})
+}
+
+// stem draws a stem rising height from base, curving until it leans at the given angle (radians from vertical,
+// positive to the right) at its tip. It returns the points along the stem from base to tip.
+func (c *canvas) stem(base point, height, lean, width float64, col color.RGBA) []point {
+	const segments = 8
+	pts := []point{base}
+	for i := 1; i <= segments; i++ {
+		angle := lean * math.Pow(float64(i)/segments, 2)
+		prev := pts[i-1]
+		next := point{prev.x + math.Sin(angle)*height/segments, prev.y - math.Cos(angle)*height/segments}
+		c.line(prev.x, prev.y, next.x, next.y, width, col)
+		pts = append(pts, next)
+	}
+	return pts
+}
+
+// leaf draws a leaf from base at the given angle (radians from vertical, positive to the right).
+func (c *canvas) leaf(base point, angle, length, width float64, col color.RGBA) {
+	const steps = 12
+	dx, dy := math.Sin(angle), -math.Cos(angle)
+	pts := make([]point, 0, 2*steps)
+	for i := range steps {
+		t := float64(i) / (steps - 1)
+		w := width / 2 * math.Sin(math.Pi*t)
+		pts = append(pts, point{base.x + dx*length*t - dy*w, base.y + dy*length*t + dx*w})
+	}
+	for i := steps - 1; i >= 0; i-- {
+		t := float64(i) / (steps - 1)
+		w := width / 2 * math.Sin(math.Pi*t)
+		pts = append(pts, point{base.x + dx*length*t + dy*w, base.y + dy*length*t - dx*w})
+	}
+	c.fillPolygon(col, pts...)
+}
+
+// flower draws a ring of petals around a centre.
+func (c *canvas) flower(at point, radius float64, petals int, petal, centre color.RGBA) {
+	for i := range petals {
+		angle := 2 * math.Pi * float64(i) / float64(petals)
+		c.leaf(at, angle, radius*1.6, radius*0.8, petal)
+	}
+	c.fillEllipse(at.x, at.y, radius*0.7, radius*0.7, centre)
+}
+
+// gauge draws the plant's water level beside the pot, with marks at the variety's minimum and maximum.
+func (c *canvas) gauge(p *plant.Plant) {
+	const top, bottom = 60.0, 208.0
+	level := func(v int) float64 {
+		return bottom - (bottom-top)*min(max(float64(v), 0), plant.MaxWaterCapacity)/plant.MaxWaterCapacity
+	}
+	c.fillRect(22, top-2, 36, bottom+2, gaugeColor)
+	c.fillRect(24, top, 34, bottom, skyColor)
+	c.fillRect(24, level(p.CurrentWaterLevel()), 34, bottom, waterColor)
+	if p.Variety != nil {
+		c.fillRect(18, level(p.Variety.MinimumWaterLevel)-1, 40, level(p.Variety.MinimumWaterLevel)+1, gaugeColor)
+		c.fillRect(18, level(p.MaximumWaterLevel())-1, 40, level(p.MaximumWaterLevel())+1, gaugeColor)
+	}
+}
+
+// mix linearly interpolates from a to b, t between 0 and 1.
+func mix(a, b color.RGBA, t float64) color.RGBA {
+	t = min(max(t, 0), 1)
+	lerp := func(x, y uint8) uint8 { return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t)) }
+	return color.RGBA{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: lerp(a.A, b.A)}
+}
+
+// over composites the non-premultiplied colour src over the opaque colour dst.
+func over(dst, src color.RGBA) color.RGBA {
+	if src.A == 0xff {
+		return src
+	}
+	return mix(dst, color.RGBA{R: src.R, G: src.G, B: src.B, A: 0xff}, float64(src.A)/0xff)
+}
+
+func withAlpha(c color.RGBA, a uint8) color.RGBA {
+	c.A = a
+	return c
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func testPlant(id, variety string, health plant.Health) *plant.Plant {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &plant.Plant{
+		Id:           id,
+		Variety:      &plant.Variety{Type: variety, GrowthRatePerDay: 5, MinimumWaterLevel: 20, MaximumWaterLevel: 80},
+		CreationTime: now,
+		LastUpdated:  now,
+		Health:       health,
+	}
+}
+
+func TestPNGRenderer(t *testing.T) {
+	t.Parallel()
+	renderer := NewPNGRenderer(128)
+	healthy := plant.Health{CurrentGrowth: 200, CurrentWaterLevel: 50, Vitality: plant.MaxVitality}
+
+	data, err := renderer.RenderPNG(testPlant("FooPlant", "sunflower", healthy))
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 128, img.Bounds().Dx())
+	assert.Equal(t, 128, img.Bounds().Dy())
+
+	// drawing is deterministic
+	again, err := renderer.RenderPNG(testPlant("FooPlant", "sunflower", healthy))
+	require.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	assert.Equal(t, DefaultPNGSize, NewPNGRenderer(0).Draw(testPlant("FooPlant", "sunflower", healthy)).Bounds().Dx())
+}
+
+func TestPNGRendererReflectsPlant(t *testing.T) {
+	t.Parallel()
+	renderer := NewPNGRenderer(64)
+	base := plant.Health{CurrentGrowth: 200, CurrentWaterLevel: 50, Vitality: plant.MaxVitality}
+	reference := renderer.Draw(testPlant("FooPlant", "sunflower", base)).Pix
+
+	tests := map[string]*plant.Plant{
+		"growth stage": testPlant("FooPlant", "sunflower", plant.Health{CurrentGrowth: 10, CurrentWaterLevel: 50, Vitality: plant.MaxVitality}),
+		"water level":  testPlant("FooPlant", "sunflower", plant.Health{CurrentGrowth: 200, CurrentWaterLevel: 70, Vitality: plant.MaxVitality}),
+		"vitality":     testPlant("FooPlant", "sunflower", plant.Health{CurrentGrowth: 200, CurrentWaterLevel: 50, Vitality: 30}),
+		"variety":      testPlant("FooPlant", "cactus", base),
+		"unknown":      testPlant("FooPlant", "fern", base),
+		"id":           testPlant("BarPlant", "bamboo", base),
+	}
+	for name, p := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.NotEqual(t, reference, renderer.Draw(p).Pix)
+		})
+	}
+}
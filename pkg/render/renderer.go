@@ -0,0 +1,93 @@
+package render
+
+import (
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"maps"
+	"mime"
+	"slices"
+	"strings"
+)
+
+// Formats of the default registry.
+const (
+	FormatASCII    = "ascii"
+	FormatJSON     = "json"
+	FormatSVG      = "svg"
+	FormatPNG      = "png"
+	FormatMarkdown = "markdown"
+)
+
+// Renderer renders a plant in a single format.
+type Renderer interface {
+	// ContentType is the media type of the rendered plant, including any parameters e.g. a charset.
+	ContentType() string
+
+	// Render renders the plant.
+	Render(p *plant.Plant) ([]byte, error)
+}
+
+// ColorRenderer is implemented by renderers of text which can also colour it with ANSI escape codes, for terminals.
+type ColorRenderer interface {
+	Renderer
+
+	// RenderColor renders the plant coloured with ANSI escape codes.
+	RenderColor(p *plant.Plant) ([]byte, error)
+}
+
+// Registry holds the renderers of the formats in which plants can be rendered, by name e.g. "svg".
+type Registry struct {
+	renderers map[string]Renderer
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{renderers: make(map[string]Renderer)}
+}
+
+// DefaultRegistry creates a Registry holding the renderers of every format in this package.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(FormatASCII, NewASCIIRenderer())
+	r.Register(FormatJSON, NewJSONRenderer())
+	r.Register(FormatSVG, NewSVGRenderer())
+	r.Register(FormatPNG, NewPNGRenderer(0))
+	r.Register(FormatMarkdown, NewMarkdownRenderer())
+	return r
+}
+
+// Register adds the renderer of a format, replacing any renderer already registered for it.
+func (r *Registry) Register(format string, renderer Renderer) {
+	r.renderers[format] = renderer
+}
+
+// Get returns the renderer of a format.
+func (r *Registry) Get(format string) (Renderer, bool) {
+	renderer, ok := r.renderers[format]
+	return renderer, ok
+}
+
+// Formats returns the names of the registered formats, sorted.
+func (r *Registry) Formats() []string {
+	return slices.Sorted(maps.Keys(r.renderers))
+}
+
+// ForMediaRange returns the format and renderer producing a media type within the range, e.g. "image/svg+xml" or
+// "image/*", choosing the first format by name when several match. The range "*/*" matches every format.
+func (r *Registry) ForMediaRange(mediaRange string) (string, Renderer, bool) {
+	wantType, wantSubtype, ok := strings.Cut(mediaRange, "/")
+	if !ok {
+		return "", nil, false
+	}
+	for _, format := range r.Formats() {
+		renderer := r.renderers[format]
+		mediaType, _, err := mime.ParseMediaType(renderer.ContentType())
+		if err != nil {
+			continue
+		}
+		gotType, gotSubtype, _ := strings.Cut(mediaType, "/")
+		if (wantType == "*" || wantType == gotType) && (wantSubtype == "*" || wantSubtype == gotSubtype) {
+			return format, renderer, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,73 @@
+package render
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"mime"
+	"testing"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+	t.Parallel()
+	registry := DefaultRegistry()
+	assert.Equal(t, []string{FormatASCII, FormatJSON, FormatMarkdown, FormatPNG, FormatSVG}, registry.Formats())
+
+	p := testPlant("FooPlant", "bonsai", plant.Health{CurrentGrowth: 200, CurrentWaterLevel: 50, Vitality: plant.MaxVitality})
+	for _, format := range registry.Formats() {
+		renderer, ok := registry.Get(format)
+		require.True(t, ok)
+		_, _, err := mime.ParseMediaType(renderer.ContentType())
+		assert.NoError(t, err, format)
+		data, err := renderer.Render(p)
+		require.NoError(t, err, format)
+		assert.NotEmpty(t, data, format)
+	}
+
+	_, ok := registry.Get("gif")
+	assert.False(t, ok)
+}
+
+func TestRegistryForMediaRange(t *testing.T) {
+	t.Parallel()
+	registry := DefaultRegistry()
+
+	tests := map[string]string{
+		"image/svg+xml":    FormatSVG,
+		"image/png":        FormatPNG,
+		"image/*":          FormatPNG,
+		"text/plain":       FormatASCII,
+		"text/*":           FormatASCII,
+		"text/markdown":    FormatMarkdown,
+		"application/json": FormatJSON,
+		"*/*":              FormatASCII,
+	}
+	for mediaRange, want := range tests {
+		format, _, ok := registry.ForMediaRange(mediaRange)
+		assert.True(t, ok, mediaRange)
+		assert.Equal(t, want, format, mediaRange)
+	}
+
+	for _, mediaRange := range []string{"image/gif", "application/xml", "html"} {
+		_, _, ok := registry.ForMediaRange(mediaRange)
+		assert.False(t, ok, mediaRange)
+	}
+
+	registry.Register(FormatSVG, NewASCIIRenderer())
+	renderer, _ := registry.Get(FormatSVG)
+	assert.IsType(t, &ASCIIRenderer{}, renderer)
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	t.Parallel()
+	p := testPlant("FooPlant", "bonsai", plant.Health{CurrentGrowth: 200, CurrentWaterLevel: 50, Vitality: plant.MaxVitality})
+	p.FriendlyName = "My | *Bonsai*"
+
+	data, err := NewMarkdownRenderer().Render(p)
+	require.NoError(t, err)
+	markdown := string(data)
+	assert.Contains(t, markdown, "# My \\| \\*Bonsai\\*\n")
+	assert.Contains(t, markdown, "| Growth Stage | growing (80%) |\n")
+	assert.Contains(t, markdown, "| Water Level | 50% (20%–80%) |\n")
+	assert.Contains(t, markdown, "](/api/plants/FooPlant/format/svg)\n")
+}
@@ -0,0 +1,199 @@
+package render
+
+import (
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"html"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// SVGRenderer renders the plant as an SVG image, a small vector drawing of the plant in its pot which reflects
+// its variety, growth, water level and health, captioned with its name, growth stage and water level. The output
+// is deterministic, so it can be compared in tests and embedded in READMEs and dashboards.
+type SVGRenderer struct{}
+
+// NewSVGRenderer creates a new SVGRenderer
+func NewSVGRenderer() *SVGRenderer {
+	return &SVGRenderer{}
+}
+
+// ContentType returns the media type of the SVG document
+func (r *SVGRenderer) ContentType() string {
+	return "image/svg+xml"
+}
+
+// Render renders the plant as an SVG document, see RenderSVG
+func (r *SVGRenderer) Render(p *plant.Plant) ([]byte, error) {
+	return []byte(r.RenderSVG(p)), nil
+}
+
+// RenderSVG renders the plant as an SVG document
+func (r *SVGRenderer) RenderSVG(p *plant.Plant) string {
+	stage := plant.GrowthStage(p.GrowthStage())
+	accent := colorClasses[p.RenderGrowthStage(stage).ColorClass]
+	foliage := accent
+	if stage == plant.Dead {
+		foliage = deadColor
+	}
+
+	name := p.FriendlyName
+	if name == "" {
+		name = p.Id
+	}
+	variety := ""
+	if p.Variety != nil {
+		variety = p.Variety.Type
+	}
+
+	wetness := 0.0
+	if maximum := p.MaximumWaterLevel(); maximum > 0 {
+		wetness = min(float64(p.CurrentWaterLevel())/float64(maximum), 1)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" width="200" height="260" viewBox="0 0 200 260" role="img">` + "\n")
+	fmt.Fprintf(&b, "<title>%s (%s), %s</title>\n", html.EscapeString(name), html.EscapeString(variety), stage)
+	fmt.Fprintf(&b, `<rect width="200" height="260" rx="12" fill="%s"/>`+"\n", hex(mix(skyColor, accent, 0.2)))
+	r.gauge(&b, p)
+
+	// the plant is drawn before the pot, so that the pot's rim hides the base of its stem
+	height := 20 + 110*float64(p.GrowthPercentage())/100
+	fmt.Fprintf(&b, `<g transform="rotate(%s 110 160)" fill="%s" stroke="%s">`+"\n", number(svgDroop(p.Condition())), hex(foliage), hex(foliage))
+	svgPlant(&b, variety, stage, height, foliage)
+	b.WriteString("</g>\n")
+
+	fmt.Fprintf(&b, `<polygon points="78,214 142,214 154,170 66,170" fill="%s"/>`+"\n", hex(potColor))
+	fmt.Fprintf(&b, `<rect x="60" y="158" width="100" height="14" rx="2" fill="%s"/>`+"\n", hex(potRimColor))
+	fmt.Fprintf(&b, `<ellipse cx="110" cy="160" rx="46" ry="5" fill="%s"/>`+"\n", hex(mix(drySoilColor, wetSoilColor, wetness)))
+
+	fmt.Fprintf(&b, `<text x="100" y="236" text-anchor="middle" font-family="sans-serif" font-size="14" fill="%s">%s</text>`+"\n",
+		hex(gaugeColor), html.EscapeString(name))
+	fmt.Fprintf(&b, `<text x="100" y="252" text-anchor="middle" font-family="sans-serif" font-size="11" fill="%s">%s · %d%% grown · %d%% water</text>`+"\n",
+		hex(mix(accent, gaugeColor, 0.4)), stage, p.GrowthPercentage(), p.CurrentWaterLevel())
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// gauge draws the plant's water level beside the pot, with marks at the variety's minimum and maximum.
+func (r *SVGRenderer) gauge(b *strings.Builder, p *plant.Plant) {
+	const top, height = 40.0, 170.0
+	level := func(v int) float64 {
+		return top + height - height*min(max(float64(v), 0), plant.MaxWaterCapacity)/plant.MaxWaterCapacity
+	}
+	fmt.Fprintf(b, `<rect x="16" y="%s" width="12" height="%s" fill="%s" stroke="%s" stroke-width="2"/>`+"\n",
+		number(top), number(height), hex(skyColor), hex(gaugeColor))
+	water := level(p.CurrentWaterLevel())
+	fmt.Fprintf(b, `<rect x="17" y="%s" width="10" height="%s" fill="%s"/>`+"\n", number(water), number(top+height-water), hex(waterColor))
+	if p.Variety != nil {
+		for _, mark := range []int{p.Variety.MinimumWaterLevel, p.MaximumWaterLevel()} {
+			fmt.Fprintf(b, `<line x1="12" y1="%[1]s" x2="32" y2="%[1]s" stroke="%[2]s" stroke-width="2"/>`+"\n", number(level(mark)), hex(gaugeColor))
+		}
+	}
+}
+
+// svgPlant draws the plant rising height above the soil at (110, 160), inheriting its fill and stroke.
+func svgPlant(b *strings.Builder, variety string, stage plant.GrowthStage, height float64, foliage color.RGBA) {
+	top := number(160 - height)
+	switch {
+	case stage == plant.Seeding:
+		fmt.Fprintf(b, `<ellipse cx="110" cy="158" rx="6" ry="4" fill="%s" stroke="none"/>`+"\n", hex(seedColor))
+		fmt.Fprintf(b, `<path d="M110 158 Q106 %[1]s 112 %[1]s" fill="none" stroke-width="2"/>`+"\n", top)
+	case stage == plant.Sprouting:
+		svgLeafyStem(b, height)
+	case variety == "cactus":
+		fmt.Fprintf(b, `<rect x="98" y="%s" width="24" height="%s" rx="12"/>`+"\n", top, number(height+6))
+		if height > 70 {
+			arm := number(160 - height*0.6)
+			fmt.Fprintf(b, `<path d="M98 %[1]s H84 V%[2]s M122 %[1]s H136 V%[2]s" fill="none" stroke-width="10" stroke-linecap="round"/>`+"\n",
+				arm, number(160-height*0.85))
+		}
+		if stage == plant.Maturing {
+			fmt.Fprintf(b, `<circle cx="110" cy="%s" r="5" fill="%s" stroke="none"/>`+"\n", top, hex(bloomColor))
+		}
+	case variety == "sunflower":
+		svgStem(b, top, 4)
+		fmt.Fprintf(b, `<ellipse cx="98" cy="%[1]s" rx="12" ry="5" transform="rotate(-20 98 %[1]s)"/>`+"\n", number(160-height*0.4))
+		fmt.Fprintf(b, `<ellipse cx="122" cy="%[1]s" rx="12" ry="5" transform="rotate(20 122 %[1]s)"/>`+"\n", number(160-height*0.65))
+		if stage == plant.Maturing {
+			fmt.Fprintf(b, `<circle cx="110" cy="%s" r="16" fill="%s" stroke="%s" stroke-width="6" stroke-dasharray="4 3"/>`+"\n",
+				top, hex(seedColor), hex(petalColor))
+		} else {
+			fmt.Fprintf(b, `<circle cx="110" cy="%s" r="6"/>`+"\n", top)
+		}
+	case variety == "bamboo":
+		for i, x := range []float64{96, 106, 116} {
+			stalk := height * []float64{0.75, 1, 0.85}[i]
+			fmt.Fprintf(b, `<rect x="%s" y="%s" width="7" height="%s" rx="2"/>`+"\n", number(x), number(160-stalk), number(stalk))
+			for y := 160 - 18.0; y > 160-stalk; y -= 18 {
+				fmt.Fprintf(b, `<line x1="%[1]s" y1="%[3]s" x2="%[2]s" y2="%[3]s" stroke="%[4]s" stroke-width="1.5"/>`+"\n",
+					number(x-1), number(x+8), number(y), hex(mix(foliage, barkColor, 0.5)))
+			}
+		}
+	case variety == "bonsai":
+		crown := number(160 - height*0.7)
+		fmt.Fprintf(b, `<path d="M110 160 C100 %[1]s 124 %[2]s 108 %[3]s" fill="none" stroke="%[4]s" stroke-width="%[5]s" stroke-linecap="round"/>`+"\n",
+			number(160-height*0.3), number(160-height*0.5), crown, hex(barkColor), number(4+height/20))
+		if stage != plant.Dead {
+			radius := 10 + height/5
+			fmt.Fprintf(b, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s"/>`+"\n", number(108-radius*0.4), crown, number(radius*0.8), number(radius*0.5))
+			fmt.Fprintf(b, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s"/>`+"\n", number(108+radius*0.5), number(160-height*0.7-radius*0.2),
+				number(radius*0.7), number(radius*0.45))
+		}
+	case variety == "orchid":
+		b.WriteString(`<ellipse cx="96" cy="156" rx="16" ry="5" transform="rotate(-15 96 156)"/>` + "\n")
+		b.WriteString(`<ellipse cx="124" cy="156" rx="16" ry="5" transform="rotate(15 124 156)"/>` + "\n")
+		fmt.Fprintf(b, `<path d="M110 158 Q106 %s 130 %s" fill="none" stroke-width="2"/>`+"\n", top, number(160-height*0.9))
+		if stage == plant.Maturing {
+			for _, t := range []float64{0.6, 0.8, 1} {
+				fmt.Fprintf(b, `<circle cx="%s" cy="%s" r="5" fill="%s" stroke="none"/>`+"\n",
+					number(108+20*t*t), number(160-height*(0.55+0.4*t)), hex(bloomColor))
+			}
+		}
+	case variety == "aloe_vera":
+		for _, angle := range []float64{-60, -35, -12, 12, 35, 60} {
+			fmt.Fprintf(b, `<polygon points="104,160 116,160 110,%s" transform="rotate(%s 110 160)"/>`+"\n",
+				number(160-height*(1-math.Abs(angle)/150)), number(angle))
+		}
+	default:
+		svgLeafyStem(b, height)
+	}
+}
+
+// svgLeafyStem draws a stem with pairs of leaves, the shape of a sprouting plant and of unknown varieties.
+func svgLeafyStem(b *strings.Builder, height float64) {
+	svgStem(b, number(160-height), 3)
+	for y := 160 - height*0.5; y > 160-height; y -= 16 {
+		fmt.Fprintf(b, `<ellipse cx="100" cy="%[1]s" rx="10" ry="4" transform="rotate(-25 100 %[1]s)"/>`+"\n", number(y))
+		fmt.Fprintf(b, `<ellipse cx="120" cy="%[1]s" rx="10" ry="4" transform="rotate(25 120 %[1]s)"/>`+"\n", number(y))
+	}
+}
+
+func svgStem(b *strings.Builder, top string, width int) {
+	fmt.Fprintf(b, `<line x1="110" y1="160" x2="110" y2="%s" stroke-width="%d" stroke-linecap="round"/>`+"\n", top, width)
+}
+
+// svgDroop is the angle, in degrees, by which a plant in the given condition leans over.
+func svgDroop(c plant.Condition) float64 {
+	switch c {
+	case plant.ConditionThirsty, plant.ConditionOverwatered:
+		return 5
+	case plant.ConditionWilting, plant.ConditionRootRot:
+		return 15
+	case plant.ConditionDead:
+		return 35
+	default:
+		return 0
+	}
+}
+
+// hex formats the colour as #rrggbb.
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// number formats a coordinate with at most one decimal place.
+func number(f float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", f), ".0")
+}
@@ -0,0 +1,51 @@
+package render
+
+import (
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"io"
+	"strings"
+	"testing"
+)
+
+// requireWellFormed checks that the document is well-formed XML.
+func requireWellFormed(t *testing.T, doc string) {
+	t.Helper()
+	decoder := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	t.Parallel()
+	r := NewSVGRenderer()
+
+	for _, variety := range []string{"aloe_vera", "bamboo", "bonsai", "cactus", "orchid", "sunflower", "fern"} {
+		for _, growth := range []float64{0, 60, 200, 300} {
+			p := testPlant("FooPlant", variety, plant.Health{CurrentGrowth: growth, CurrentWaterLevel: 50, Vitality: plant.MaxVitality})
+			p.FriendlyName = "Foo & <Friends>"
+			svg := r.RenderSVG(p)
+			requireWellFormed(t, svg)
+			assert.Equal(t, svg, r.RenderSVG(p), "rendering is deterministic")
+
+			stage := plant.GrowthStage(p.GrowthStage())
+			assert.Contains(t, svg, hex(colorClasses[p.RenderGrowthStage(stage).ColorClass]))
+			assert.Contains(t, svg, "Foo &amp; &lt;Friends&gt;")
+		}
+	}
+
+	seedling := r.RenderSVG(testPlant("FooPlant", "cactus", plant.Health{CurrentGrowth: 10, CurrentWaterLevel: 50, Vitality: plant.MaxVitality}))
+	assert.Contains(t, seedling, "seeding · 4% grown · 50% water")
+	dead := testPlant("FooPlant", "cactus", plant.Health{CurrentGrowth: 300, CurrentWaterLevel: 0})
+	dead.DiedAt = dead.LastUpdated
+	svg := r.RenderSVG(dead)
+	assert.Contains(t, svg, "dead · 100% grown · 0% water")
+	assert.Contains(t, svg, `rotate(35 110 160)`, "a dead plant has collapsed")
+}
@@ -0,0 +1,101 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	_ "image/jpeg" // providers may return JPEG images
+	_ "image/png"
+	"time"
+)
+
+// ErrNoFrames is returned when none of the images given to Timelapse can be decoded.
+var ErrNoFrames = errors.New("render: no images could be decoded")
+
+// TimelapseOptions configures the animated GIF produced by Timelapse.
+type TimelapseOptions struct {
+	Delay     time.Duration // how long each frame is shown, at least 20ms
+	Size      int           // frames are scaled down so that their longest side is at most Size pixels, unscaled if 0
+	MaxFrames int           // when there are more images, frames are sampled evenly between the first and last
+}
+
+// Timelapse assembles images, in the given order, into an animated GIF which loops forever. Images which can't be
+// decoded, such as image records, are skipped. Every frame is scaled to the size of the first, and dithered to
+// the Plan 9 palette. Images are sampled before they are decoded, so only the frames which are shown are decoded.
+func Timelapse(images []fs.ImageMetadata, opts TimelapseOptions) ([]byte, error) {
+	var frames []image.Image
+	for _, img := range SampleFrames(images, opts.MaxFrames) {
+		frame, _, err := image.Decode(bytes.NewReader(img.Data()))
+		if err != nil {
+			continue
+		}
+		frames = append(frames, frame)
+	}
+	if len(frames) == 0 {
+		return nil, ErrNoFrames
+	}
+
+	bounds := scaledBounds(frames[0].Bounds(), opts.Size)
+	delay := max(int(opts.Delay/(10*time.Millisecond)), 2)
+	animation := &gif.GIF{}
+	for _, frame := range frames {
+		paletted := image.NewPaletted(bounds, palette.Plan9)
+		scaled := scale(frame, bounds)
+		draw.FloydSteinberg.Draw(paletted, bounds, scaled, scaled.Bounds().Min)
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, delay)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, animation); err != nil {
+		return nil, fmt.Errorf("render: failed to encode timelapse: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SampleFrames returns at most n frames, evenly spaced and including the first and last. Timelapse samples its
+// images with MaxFrames, so callers may sample first to fetch only the images which are shown.
+func SampleFrames[T any](frames []T, n int) []T {
+	if n <= 0 || len(frames) <= n {
+		return frames
+	}
+	if n == 1 {
+		return frames[len(frames)-1:]
+	}
+	sampled := make([]T, 0, n)
+	for i := range n {
+		sampled = append(sampled, frames[i*(len(frames)-1)/(n-1)])
+	}
+	return sampled
+}
+
+// scaledBounds returns bounds at the origin, scaled down so that the longest side is at most size.
+func scaledBounds(b image.Rectangle, size int) image.Rectangle {
+	w, h := b.Dx(), b.Dy()
+	if longest := max(w, h); size > 0 && longest > size {
+		w, h = max(w*size/longest, 1), max(h*size/longest, 1)
+	}
+	return image.Rect(0, 0, w, h)
+}
+
+// scale resizes src to bounds using nearest-neighbour sampling.
+func scale(src image.Image, bounds image.Rectangle) image.Image {
+	sb := src.Bounds()
+	if sb.Size() == bounds.Size() {
+		return src
+	}
+	dst := image.NewRGBA(bounds)
+	for y := range bounds.Dy() {
+		sy := sb.Min.Y + y*sb.Dy()/bounds.Dy()
+		for x := range bounds.Dx() {
+			sx := sb.Min.X + x*sb.Dx()/bounds.Dx()
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+	return dst
+}
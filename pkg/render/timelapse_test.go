@@ -0,0 +1,88 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func solidPNG(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestTimelapse(t *testing.T) {
+	t.Parallel()
+	images := []fs.ImageMetadata{
+		fs.NewImageMetadata("2025-01-01-FooPlant.png", solidPNG(t, 64, 32, color.RGBA{R: 0xff, A: 0xff})),
+		fs.NewImageMetadata("2025-01-01-FooPlant.png.prompt.json", []byte(`{}`)),
+		fs.NewImageMetadata("2025-01-02-FooPlant.png", solidPNG(t, 128, 128, color.RGBA{G: 0xff, A: 0xff})),
+		fs.NewImageMetadata("2025-01-03-FooPlant.png", solidPNG(t, 64, 32, color.RGBA{B: 0xff, A: 0xff})),
+	}
+
+	data, err := Timelapse(images, TimelapseOptions{Delay: 250 * time.Millisecond, Size: 32})
+	require.NoError(t, err)
+	animation, err := gif.DecodeAll(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	// undecodable images are skipped, and every frame is scaled to the first
+	require.Len(t, animation.Image, 3)
+	assert.Equal(t, []int{25, 25, 25}, animation.Delay)
+	assert.Equal(t, 0, animation.LoopCount, "loops forever")
+	for _, frame := range animation.Image {
+		assert.Equal(t, image.Rect(0, 0, 32, 16), frame.Bounds())
+	}
+	r, g, b, _ := animation.Image[1].At(8, 8).RGBA()
+	assert.Equal(t, [3]uint32{0, 0xffff, 0}, [3]uint32{r, g, b}, "frames are in the given order")
+
+	_, err = Timelapse(images[1:2], TimelapseOptions{})
+	assert.ErrorIs(t, err, ErrNoFrames)
+}
+
+func TestTimelapseSampling(t *testing.T) {
+	t.Parallel()
+	var images []fs.ImageMetadata
+	for day := 1; day <= 10; day++ {
+		shade := uint8(day * 20)
+		images = append(images, fs.NewImageMetadata(fmt.Sprintf("2025-01-%02d-FooPlant.png", day),
+			solidPNG(t, 4, 4, color.Gray{Y: shade})))
+	}
+
+	data, err := Timelapse(images, TimelapseOptions{MaxFrames: 4})
+	require.NoError(t, err)
+	animation, err := gif.DecodeAll(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Len(t, animation.Image, 4)
+	assert.Equal(t, []int{2, 2, 2, 2}, animation.Delay, "the delay is at least 20ms")
+
+	// images are sampled before they are decoded, those which aren't shown are never decoded
+	for _, day := range []int{2, 3, 5, 6, 8, 9} {
+		images[day-1] = fs.NewImageMetadata(images[day-1].FileName(), []byte("not an image"))
+	}
+	data, err = Timelapse(images, TimelapseOptions{MaxFrames: 4})
+	require.NoError(t, err)
+	animation, err = gif.DecodeAll(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Len(t, animation.Image, 4)
+
+	assert.Equal(t, []int{1, 4, 7, 10}, SampleFrames([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 4),
+		"the first and last frames are kept")
+	assert.Equal(t, []int{10}, SampleFrames([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 1))
+	assert.Equal(t, []int{1, 2}, SampleFrames([]int{1, 2}, 4))
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"slices"
+)
+
+// EventFilter selects a page of a plant's events.
+type EventFilter struct {
+	Types  []plant.EventType // only events of these types are returned, all types when empty
+	Offset int               // number of matching events to skip
+	Limit  int               // maximum number of events to return, unlimited when zero
+}
+
+// matches returns true when the event is of one of the filter's types.
+func (f EventFilter) matches(e plant.Event) bool {
+	return len(f.Types) == 0 || slices.Contains(f.Types, e.Type)
+}
+
+// apply returns the page of events selected by the filter, along with the total number of matching events.
+func (f EventFilter) apply(events []plant.Event) ([]plant.Event, int) {
+	matching := make([]plant.Event, 0)
+	for _, e := range events {
+		if f.matches(e) {
+			matching = append(matching, e)
+		}
+	}
+
+	total := len(matching)
+	start := min(max(f.Offset, 0), total)
+	end := total
+	if f.Limit > 0 {
+		end = min(start+f.Limit, total)
+	}
+	return matching[start:end], total
+}
@@ -0,0 +1,430 @@
+package repository
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Names of the files, within the data directory, which hold the store.
+const (
+	snapshotFileName  = "plants.json"      // snapshot of the plants
+	eventsFileName    = "events.jsonl"     // log of the plants' events
+	imageJobsFileName = "image_jobs.jsonl" // log of the image jobs, each line is the state of a job when it was saved
+)
+
+// FileStore is a PlantRepository which holds plants in memory and writes them to disk after every change. The
+// plants are written as a JSON snapshot, while events and image jobs, whose history grows without bound, are
+// appended to logs next to it, so that the cost of a write doesn't grow with the history. The store is restored
+// when it is created, so plants survive a restart, and the image job log is then compacted to the latest state
+// of each job.
+type FileStore struct {
+	*InMemoryStore
+	path             string // snapshot of the plants
+	eventsPath       string
+	imageJobsPath    string
+	persistMu        sync.Mutex // serialises writes to the snapshot and logs
+	persistedEventId int64      // the events up to this id have been appended to the events log
+}
+
+// snapshot is the on-disk representation of a FileStore's plants. Earlier versions of the store also wrote the
+// events and image jobs to the snapshot, they are moved to the logs when such a snapshot is restored.
+type snapshot struct {
+	Plants    []plantRecord    `json:"plants"`
+	Events    []plant.Event    `json:"events,omitempty"`
+	ImageJobs []plant.ImageJob `json:"image_jobs,omitempty"`
+}
+
+// plantRecord is the serialised form of a plant.Plant, the variety is stored by name and is resolved against
+// the varieties file when the snapshot is restored.
+type plantRecord struct {
+	Id           string       `json:"id"`
+	FriendlyName string       `json:"friendly_name"`
+	Variety      string       `json:"variety"`
+	Motif        string       `json:"motif,omitempty"`
+	Generation   int64        `json:"generation"`
+	CreationTime time.Time    `json:"creation_time"`
+	LastUpdated  time.Time    `json:"last_updated"`
+	DiedAt       time.Time    `json:"died_at,omitzero"`
+	Health       healthRecord `json:"health"`
+}
+
+type healthRecord struct {
+	CurrentGrowth     float64   `json:"current_growth"`
+	CurrentWaterLevel float64   `json:"current_water_level"`
+	Vitality          float64   `json:"vitality"`
+	DroughtSince      time.Time `json:"drought_since,omitzero"`
+	OverwateredSince  time.Time `json:"overwatered_since,omitzero"`
+}
+
+// NewFileStore returns a store which persists plants under dataDir. If no snapshot exists and populateStore
+// is true, the store is populated with sample plants.
+func NewFileStore(dataDir string, populateStore bool, filePaths ...string) (PlantRepository, error) {
+	inMemoryStore, err := newInMemoryStore(varietiesPath(filePaths...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("store: failed to create data directory: %w", err)
+	}
+
+	s := &FileStore{
+		InMemoryStore: inMemoryStore,
+		path:          filepath.Join(dataDir, snapshotFileName),
+		eventsPath:    filepath.Join(dataDir, eventsFileName),
+		imageJobsPath: filepath.Join(dataDir, imageJobsFileName),
+	}
+
+	restored, err := s.restore()
+	if err != nil {
+		return nil, err
+	}
+
+	if !restored && populateStore {
+		s.populateSamplePlants()
+		if err := s.persist(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) NewPlant(id, friendlyName, plantType string, creationTime time.Time) (*plant.Plant, error) {
+	p, err := s.InMemoryStore.NewPlant(id, friendlyName, plantType, creationTime)
+	if err != nil {
+		return nil, err
+	}
+	return p, s.persist()
+}
+
+func (s *FileStore) DeletePlant(id string) error {
+	if err := s.InMemoryStore.DeletePlant(id); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+func (s *FileStore) UpdatePlants(ids []string) error {
+	updateErr := s.InMemoryStore.UpdatePlants(ids)
+	// some plants may have been updated even if others failed
+	if err := s.persist(); err != nil {
+		return errors.Join(updateErr, err)
+	}
+	return updateErr
+}
+
+// GetPlant returns the plant brought up to date, the snapshot is only written when the plant's condition changed,
+// reads which just move the plant along are persisted by the next change or growth tick.
+func (s *FileStore) GetPlant(id string) (*plant.Plant, error) {
+	p, changed, err := s.getPlant(id)
+	if err != nil || !changed {
+		return p, err
+	}
+	return p, s.persist()
+}
+
+// UpdatePlant updates the plant, the snapshot is written when the plant was saved or its condition changed.
+func (s *FileStore) UpdatePlant(id string, update func(p *plant.Plant) error) (*plant.Plant, error) {
+	p, changed, err := s.modifyPlant(id, update)
+	if err != nil && !changed {
+		return nil, err
+	}
+	if persistErr := s.persist(); persistErr != nil {
+		return nil, errors.Join(err, persistErr)
+	}
+	return p, err
+}
+
+func (s *FileStore) UpdateAllPlants() error {
+	if err := s.InMemoryStore.UpdateAllPlants(); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+func (s *FileStore) UpdatePlantById(id string) error {
+	if err := s.InMemoryStore.UpdatePlantById(id); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+func (s *FileStore) SavePlant(p *plant.Plant) error {
+	if err := s.InMemoryStore.SavePlant(p); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+// RecordEvent records the event, which is appended to the events log without writing the snapshot.
+func (s *FileStore) RecordEvent(e plant.Event) error {
+	if err := s.InMemoryStore.RecordEvent(e); err != nil {
+		return err
+	}
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	return s.appendEvents()
+}
+
+// SaveImageJob saves the job, which is appended to the image job log without writing the snapshot.
+func (s *FileStore) SaveImageJob(job *plant.ImageJob) error {
+	if err := s.InMemoryStore.SaveImageJob(job); err != nil {
+		return err
+	}
+	return s.appendImageJob(job.PlantId, job.Id)
+}
+
+func (s *FileStore) ClaimImageJob(job *plant.ImageJob, at time.Time, lease time.Duration) (bool, error) {
+	claimed, err := s.InMemoryStore.ClaimImageJob(job, at, lease)
+	if err != nil || !claimed {
+		return claimed, err
+	}
+	return true, s.appendImageJob(job.PlantId, job.Id)
+}
+
+// restore loads the snapshot and logs from disk, it returns false when no snapshot exists. The logs are then
+// compacted: the superseded states of each image job are dropped, as is a line torn by a crash, and the events
+// and image jobs of a snapshot written by an earlier version of the store are moved to the logs.
+func (s *FileStore) restore() (bool, error) {
+	var snap snapshot
+	data, err := os.ReadFile(s.path)
+	restored := err == nil
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return false, fmt.Errorf("store: failed to read snapshot: %w", err)
+	default:
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return false, fmt.Errorf("store: failed to unmarshal snapshot: %w", err)
+		}
+	}
+
+	loggedEvents, eventsTorn, err := readLog[plant.Event](s.eventsPath)
+	if err != nil {
+		return false, fmt.Errorf("store: failed to read events: %w", err)
+	}
+	loggedJobs, jobsTorn, err := readLog[plant.ImageJob](s.imageJobsPath)
+	if err != nil {
+		return false, fmt.Errorf("store: failed to read image jobs: %w", err)
+	}
+	legacy := len(snap.Events) > 0 || len(snap.ImageJobs) > 0
+	events := uniqueEvents(append(snap.Events, loggedEvents...))
+	jobs := latestImageJobs(append(snap.ImageJobs, loggedJobs...))
+
+	if err := s.load(snap.Plants, events, jobs); err != nil {
+		return false, err
+	}
+
+	if legacy || eventsTorn {
+		if err := writeLog(s.eventsPath, events); err != nil {
+			return false, fmt.Errorf("store: failed to compact events: %w", err)
+		}
+	}
+	if legacy || jobsTorn || len(loggedJobs) > len(jobs) {
+		if err := writeLog(s.imageJobsPath, jobs); err != nil {
+			return false, fmt.Errorf("store: failed to compact image jobs: %w", err)
+		}
+	}
+	if legacy {
+		if err := s.persist(); err != nil {
+			return false, err
+		}
+	}
+	return restored, nil
+}
+
+// load adds the restored plants, events and image jobs to the store.
+func (s *FileStore) load(plants []plantRecord, events []plant.Event, jobs []plant.ImageJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range plants {
+		variety, err := s.GetVarietyUnsafe(record.Variety)
+		if err != nil {
+			return fmt.Errorf("store: failed to restore plant %s: %w", record.Id, err)
+		}
+
+		p := record.toPlant(&variety)
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("store: failed to restore plant %s: %w", record.Id, err)
+		}
+
+		s.Plants[p.Id] = p
+		s.PlantsByVariety[variety.Type] = append(s.PlantsByVariety[variety.Type], p.Id)
+	}
+
+	for _, e := range events {
+		s.Events[e.PlantId] = append(s.Events[e.PlantId], e)
+		s.lastEventId = max(s.lastEventId, e.Id)
+	}
+	s.persistedEventId = s.lastEventId
+
+	for _, job := range jobs {
+		s.ImageJobs[job.PlantId] = append(s.ImageJobs[job.PlantId], job)
+		s.lastImageJobId = max(s.lastImageJobId, job.Id)
+	}
+	return nil
+}
+
+// uniqueEvents returns the events ordered by id, without duplicates.
+func uniqueEvents(events []plant.Event) []plant.Event {
+	slices.SortStableFunc(events, func(a, b plant.Event) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return slices.CompactFunc(events, func(a, b plant.Event) bool {
+		return a.Id == b.Id
+	})
+}
+
+// latestImageJobs returns the last state of each job, ordered by id.
+func latestImageJobs(states []plant.ImageJob) []plant.ImageJob {
+	latest := make(map[int64]plant.ImageJob, len(states))
+	for _, job := range states {
+		latest[job.Id] = job
+	}
+	jobs := slices.Collect(maps.Values(latest))
+	slices.SortFunc(jobs, func(a, b plant.ImageJob) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return jobs
+}
+
+// appendImageJob appends the current state of a job to the image job log. The state is read while holding
+// persistMu, so that the log's last line for the job is its latest state whichever order saves are appended in.
+func (s *FileStore) appendImageJob(plantId string, id int64) error {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	s.mu.RLock()
+	jobs := s.ImageJobs[plantId]
+	i := slices.IndexFunc(jobs, func(j plant.ImageJob) bool { return j.Id == id })
+	if i < 0 {
+		s.mu.RUnlock()
+		return errImageJobNotFound
+	}
+	job := jobs[i]
+	s.mu.RUnlock()
+
+	if err := appendLog(s.imageJobsPath, []plant.ImageJob{job}); err != nil {
+		return fmt.Errorf("store: failed to append image job %d: %w", id, err)
+	}
+	return nil
+}
+
+// appendEvents appends the events recorded since the last append to the events log, persistMu must be held.
+// Each plant's events are ordered by id, so only the end of each plant's history is read.
+func (s *FileStore) appendEvents() error {
+	s.mu.RLock()
+	var events []plant.Event
+	for _, history := range s.Events {
+		for i := len(history) - 1; i >= 0 && history[i].Id > s.persistedEventId; i-- {
+			events = append(events, history[i])
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+	slices.SortFunc(events, func(a, b plant.Event) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	if err := appendLog(s.eventsPath, events); err != nil {
+		return fmt.Errorf("store: failed to append events: %w", err)
+	}
+	s.persistedEventId = events[len(events)-1].Id
+	return nil
+}
+
+// persist appends the events recorded since the last write to the events log, then atomically replaces the
+// snapshot on disk with the current plants.
+func (s *FileStore) persist() error {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	if err := s.appendEvents(); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	snap := snapshot{Plants: make([]plantRecord, 0, len(s.Plants))}
+	for _, p := range s.Plants {
+		snap.Plants = append(snap.Plants, newPlantRecord(p))
+	}
+	s.mu.RUnlock()
+
+	// map iteration order is random, sort to keep the snapshot stable between writes
+	sort.Slice(snap.Plants, func(i, j int) bool {
+		return snap.Plants[i].Id < snap.Plants[j].Id
+	})
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("store: failed to marshal snapshot: %w", err)
+	}
+
+	if err := fs.WriteFileAtomic(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("store: failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+func newPlantRecord(p *plant.Plant) plantRecord {
+	return plantRecord{
+		Id:           p.Id,
+		FriendlyName: p.FriendlyName,
+		Variety:      p.Variety.Type,
+		Motif:        p.Motif,
+		Generation:   p.Generation,
+		CreationTime: p.CreationTime,
+		LastUpdated:  p.LastUpdated,
+		DiedAt:       p.DiedAt,
+		Health: healthRecord{
+			CurrentGrowth:     p.Health.CurrentGrowth,
+			CurrentWaterLevel: p.Health.CurrentWaterLevel,
+			Vitality:          p.Health.Vitality,
+			DroughtSince:      p.Health.DroughtSince,
+			OverwateredSince:  p.Health.OverwateredSince,
+		},
+	}
+}
+
+func (r plantRecord) toPlant(variety *plant.Variety) *plant.Plant {
+	vitality := r.Health.Vitality
+	// snapshots written before vitality was introduced don't record it, only a dead plant has no vitality
+	if vitality == 0 && r.DiedAt.IsZero() {
+		vitality = plant.MaxVitality
+	}
+	// likewise for generation, which starts at 1
+	generation := max(r.Generation, 1)
+
+	return &plant.Plant{
+		Id:           r.Id,
+		FriendlyName: r.FriendlyName,
+		Variety:      variety,
+		Motif:        r.Motif,
+		Generation:   generation,
+		CreationTime: r.CreationTime,
+		LastUpdated:  r.LastUpdated,
+		DiedAt:       r.DiedAt,
+		Health: plant.Health{
+			CurrentGrowth:     r.Health.CurrentGrowth,
+			CurrentWaterLevel: r.Health.CurrentWaterLevel,
+			Vitality:          vitality,
+			DroughtSince:      r.Health.DroughtSince,
+			OverwateredSince:  r.Health.OverwateredSince,
+		},
+	}
+}
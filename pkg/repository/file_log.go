@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"os"
+)
+
+// appendLog appends records to the JSON lines log at path, creating it if it doesn't exist, and syncs it.
+func appendLog[T any](path string, records []T) error {
+	if len(records) == 0 {
+		return nil
+	}
+	data, err := marshalLog(records)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeLog atomically replaces the JSON lines log at path with records.
+func writeLog[T any](path string, records []T) error {
+	data, err := marshalLog(records)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFileAtomic(path, data, 0o600)
+}
+
+// readLog returns the records of the JSON lines log at path, none when it doesn't exist. A last line which is
+// incomplete, because a crash interrupted its append, is skipped and torn is true.
+func readLog[T any](path string) (records []T, torn bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var record T
+		if err := json.Unmarshal(line, &record); err != nil {
+			if i == len(lines)-1 {
+				return records, true, nil
+			}
+			return nil, false, err
+		}
+		records = append(records, record)
+	}
+	return records, false, nil
+}
+
+func marshalLog[T any](records []T) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
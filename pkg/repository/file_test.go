@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testVarietiesPath = "../plant/varieties.json"
+
+func TestFileStoreSurvivesRestart(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+
+	s, err := NewFileStore(dataDir, false, testVarietiesPath)
+	require.NoError(t, err)
+
+	creationTime := time.Now().Add(-72 * time.Hour)
+	_, err = s.NewPlant("FooPlant", "MyBonsai", "bonsai", creationTime)
+	require.NoError(t, err)
+	_, err = s.NewPlant("BarPlant", "MySunflower", "sunflower", creationTime)
+	require.NoError(t, err)
+
+	// let the bonsai die of drought, then persist the changes
+	p, err := s.GetPlant("FooPlant")
+	require.NoError(t, err)
+	p.Update(creationTime.Add(30 * 24 * time.Hour))
+	require.True(t, p.Dead())
+	require.NoError(t, s.SavePlant(p))
+	require.NoError(t, s.DeletePlant("BarPlant"))
+
+	// "restart" by creating a new store over the same data directory
+	restarted, err := NewFileStore(dataDir, true, testVarietiesPath)
+	require.NoError(t, err)
+
+	assert.Len(t, restarted.ListAllPlants(), 1, "sample plants are not added to an existing store")
+	restored, err := restarted.GetPlant("FooPlant")
+	require.NoError(t, err)
+	assert.Equal(t, "MyBonsai", restored.FriendlyName)
+	assert.Equal(t, "bonsai", restored.Variety.Type)
+	assert.Equal(t, p.CurrentGrowth(), restored.CurrentGrowth())
+	assert.Equal(t, p.CurrentWaterLevel(), restored.CurrentWaterLevel())
+	assert.True(t, p.Health.DroughtSince.Equal(restored.Health.DroughtSince))
+	assert.True(t, creationTime.Equal(restored.CreationTime))
+	assert.True(t, p.LastUpdated.Equal(restored.LastUpdated))
+	assert.True(t, p.DiedAt.Equal(restored.DiedAt))
+	assert.Equal(t, plant.Dead.String(), restored.GrowthStage())
+
+	byType, err := restarted.ListPlantsByType("bonsai")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FooPlant"}, byType["bonsai"])
+
+	_, err = restarted.GetPlant("BarPlant")
+	assert.Error(t, err)
+}
+
+// logLines returns the number of lines in a log of the file store.
+func logLines(t *testing.T, dataDir, name string) int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dataDir, name))
+	require.NoError(t, err)
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestFileStoreLogs(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+	start := time.Now()
+
+	s, err := NewFileStore(dataDir, false, testVarietiesPath)
+	require.NoError(t, err)
+	_, err = s.NewPlant("FooPlant", "MyBonsai", "bonsai", start)
+	require.NoError(t, err)
+	snap, err := os.ReadFile(filepath.Join(dataDir, snapshotFileName))
+	require.NoError(t, err)
+
+	// events and image jobs are appended to their logs, without rewriting the snapshot
+	require.NoError(t, s.RecordEvent(plant.NewEvent("FooPlant", plant.EventWatered, start, "watered")))
+	job := plant.NewImageJob("FooPlant", "2025-01-01-FooPlant.png", 5, start)
+	require.NoError(t, s.SaveImageJob(&job))
+	claimed, err := s.ClaimImageJob(&job, start, time.Hour)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	unchanged, err := os.ReadFile(filepath.Join(dataDir, snapshotFileName))
+	require.NoError(t, err)
+	assert.Equal(t, snap, unchanged)
+	assert.Equal(t, 2, logLines(t, dataDir, eventsFileName))
+	assert.Equal(t, 2, logLines(t, dataDir, imageJobsFileName))
+
+	// a line torn by a crash is skipped
+	f, err := os.OpenFile(filepath.Join(dataDir, eventsFileName), os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"id": 3, "plant_id": "Foo`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// on restart the latest state of each job is restored, and the logs are compacted
+	restarted, err := NewFileStore(dataDir, false, testVarietiesPath)
+	require.NoError(t, err)
+	events, total, err := restarted.ListEvents("FooPlant", EventFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, plant.EventWatered, events[1].Type)
+	jobs, err := restarted.ListImageJobs("FooPlant")
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, plant.ImageJobRunning, jobs[0].State)
+	assert.Equal(t, 1, logLines(t, dataDir, imageJobsFileName))
+	assert.Equal(t, 2, logLines(t, dataDir, eventsFileName))
+
+	// ids carry on from the restored logs
+	require.NoError(t, restarted.RecordEvent(plant.NewEvent("FooPlant", plant.EventWatered, start, "watered")))
+	events, _, err = restarted.ListEvents("FooPlant", EventFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), events[2].Id)
+}
+
+func TestFileStoreMigratesSnapshot(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// a snapshot written by an earlier version of the store holds the events and image jobs
+	legacy := `{
+  "plants": [{"id": "FooPlant", "friendly_name": "MyBonsai", "variety": "bonsai", "generation": 1,
+    "creation_time": "2025-01-01T00:00:00Z", "last_updated": "2025-01-01T00:00:00Z",
+    "health": {"current_water_level": 50, "vitality": 100}}],
+  "events": [{"id": 1, "plant_id": "FooPlant", "type": "created", "time": "2025-01-01T00:00:00Z"}],
+  "image_jobs": [{"id": 1, "plant_id": "FooPlant", "image": "2025-01-01-FooPlant.png", "state": "pending",
+    "max_attempts": 5, "created_at": "2025-01-01T00:00:00Z", "updated_at": "2025-01-01T00:00:00Z"}]
+}`
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, snapshotFileName), []byte(legacy), 0o600))
+
+	s, err := NewFileStore(dataDir, true, testVarietiesPath)
+	require.NoError(t, err)
+	assert.Len(t, s.ListAllPlants(), 1)
+	_, total, err := s.ListEvents("FooPlant", EventFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	due, err := s.DueImageJobs(start)
+	require.NoError(t, err)
+	assert.Len(t, due, 1)
+
+	// they are moved to the logs
+	snap, err := os.ReadFile(filepath.Join(dataDir, snapshotFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(snap), "image_jobs")
+	assert.NotContains(t, string(snap), "events")
+	assert.Equal(t, 1, logLines(t, dataDir, eventsFileName))
+	assert.Equal(t, 1, logLines(t, dataDir, imageJobsFileName))
+}
+
+func TestFileStorePopulatesEmptyStore(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewFileStore(t.TempDir(), true, testVarietiesPath)
+	require.NoError(t, err)
+	assert.Len(t, s.ListAllPlants(), 2)
+}
+
+func TestNewUnsupportedDriver(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(Options{Driver: "carrier-pigeon", VarietiesPath: testVarietiesPath})
+	assert.Error(t, err)
+}
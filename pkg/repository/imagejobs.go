@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"cmp"
+	"errors"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"slices"
+	"time"
+)
+
+// errImageJobNotFound is returned when a job to be updated doesn't exist.
+var errImageJobNotFound = errors.New("image job not found")
+
+// SaveImageJob creates the job when its Id is zero, assigning it the next Id, otherwise it replaces the job.
+func (s *InMemoryStore) SaveImageJob(job *plant.ImageJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.Id == 0 {
+		s.lastImageJobId++
+		job.Id = s.lastImageJobId
+		s.ImageJobs[job.PlantId] = append(s.ImageJobs[job.PlantId], *job)
+		return nil
+	}
+
+	jobs := s.ImageJobs[job.PlantId]
+	i := slices.IndexFunc(jobs, func(j plant.ImageJob) bool { return j.Id == job.Id })
+	if i < 0 {
+		return errImageJobNotFound
+	}
+	jobs[i] = *job
+	return nil
+}
+
+// ListImageJobs returns the plant's image jobs, newest first.
+func (s *InMemoryStore) ListImageJobs(plantID string) ([]plant.ImageJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := slices.Clone(s.ImageJobs[plantID])
+	slices.Reverse(jobs)
+	if jobs == nil {
+		jobs = make([]plant.ImageJob, 0)
+	}
+	return jobs, nil
+}
+
+// DueImageJobs returns the jobs of every plant which are due at the given time, oldest first.
+func (s *InMemoryStore) DueImageJobs(at time.Time) ([]plant.ImageJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var due []plant.ImageJob
+	for _, jobs := range s.ImageJobs {
+		for _, job := range jobs {
+			if job.Due(at) {
+				due = append(due, job)
+			}
+		}
+	}
+	slices.SortFunc(due, func(a, b plant.ImageJob) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return due, nil
+}
+
+// ClaimImageJob starts an attempt at a due job, unless the job has changed or been removed since it was read.
+func (s *InMemoryStore) ClaimImageJob(job *plant.ImageJob, at time.Time, lease time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := s.ImageJobs[job.PlantId]
+	i := slices.IndexFunc(jobs, func(j plant.ImageJob) bool { return j.Id == job.Id })
+	if i < 0 {
+		return false, nil
+	}
+	stored := jobs[i]
+	if stored.State != job.State || !stored.UpdatedAt.Equal(job.UpdatedAt) || !stored.Due(at) {
+		return false, nil
+	}
+	stored.Claim(at, lease)
+	jobs[i] = stored
+	*job = stored
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrations holds the versioned schema migrations, files are named NNNN_description.sql and are applied in
+// order of their version number.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations returns the embedded migrations sorted by version.
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("migrate: failed to read migrations: %w", err)
+	}
+
+	var result []migration
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migrate: invalid migration name %q", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: invalid migration version %q: %w", name, err)
+		}
+		data, err := migrations.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: failed to read migration %q: %w", name, err)
+		}
+		result = append(result, migration{version: version, name: name, sql: string(data)})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].version < result[j].version
+	})
+	return result, nil
+}
+
+// migrate applies any migrations which have not yet been recorded in the schema_migrations table. Each
+// migration runs in its own transaction, so a failed migration leaves the schema at the previous version.
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("migrate: failed to create schema_migrations: %w", err)
+	}
+
+	all, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range all {
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("migrate: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// another replica may have applied the migration since we started
+	var applied int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, m.version).Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("migrate: failed to query schema version: %w", err)
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return fmt.Errorf("migrate: failed to apply %s: %w", m.name, err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, formatTime(time.Now()))
+	if err != nil {
+		return fmt.Errorf("migrate: failed to record %s: %w", m.name, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,27 @@
+CREATE TABLE varieties (
+    name                TEXT PRIMARY KEY,
+    growth_rate         INTEGER NOT NULL,
+    water_consumption   INTEGER NOT NULL,
+    minimum_water_level INTEGER NOT NULL
+);
+
+CREATE TABLE plants (
+    id                  TEXT PRIMARY KEY,
+    friendly_name       TEXT NOT NULL,
+    variety             TEXT NOT NULL REFERENCES varieties (name),
+    creation_time       TEXT NOT NULL,
+    last_updated        TEXT NOT NULL,
+    current_growth      INTEGER NOT NULL,
+    current_water_level INTEGER NOT NULL
+);
+
+-- replaces InMemoryStore.PlantsByVariety
+CREATE INDEX plants_variety_idx ON plants (variety);
+
+CREATE TABLE images (
+    plant_id   TEXT NOT NULL REFERENCES plants (id) ON DELETE CASCADE,
+    file_name  TEXT NOT NULL,
+    data       BLOB NOT NULL,
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (plant_id, file_name)
+);
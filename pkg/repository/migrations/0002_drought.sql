@@ -0,0 +1,5 @@
+ALTER TABLE varieties ADD COLUMN drought_grace_days INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE plants ADD COLUMN vitality REAL NOT NULL DEFAULT 100;
+ALTER TABLE plants ADD COLUMN drought_since TEXT;
+ALTER TABLE plants ADD COLUMN died_at TEXT;
@@ -0,0 +1,12 @@
+-- growth and water levels are fractional, SQLite cannot change a column's type in place so the columns
+-- are recreated with REAL affinity.
+ALTER TABLE plants ADD COLUMN growth REAL NOT NULL DEFAULT 0;
+ALTER TABLE plants ADD COLUMN water_level REAL NOT NULL DEFAULT 0;
+
+UPDATE plants SET growth = current_growth, water_level = current_water_level;
+
+ALTER TABLE plants DROP COLUMN current_growth;
+ALTER TABLE plants DROP COLUMN current_water_level;
+
+ALTER TABLE plants RENAME COLUMN growth TO current_growth;
+ALTER TABLE plants RENAME COLUMN water_level TO current_water_level;
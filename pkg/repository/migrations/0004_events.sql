@@ -0,0 +1,12 @@
+-- events are not removed when a plant is deleted, so plant_id does not reference plants
+CREATE TABLE events (
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    plant_id TEXT NOT NULL,
+    type     TEXT NOT NULL,
+    time     TEXT NOT NULL,
+    message  TEXT NOT NULL,
+    before   TEXT,
+    after    TEXT
+);
+
+CREATE INDEX events_plant_idx ON events (plant_id, id);
@@ -0,0 +1,3 @@
+ALTER TABLE varieties ADD COLUMN maximum_water_level INTEGER NOT NULL DEFAULT 100;
+
+ALTER TABLE plants ADD COLUMN overwatered_since TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE plants ADD COLUMN motif TEXT NOT NULL DEFAULT '';
+ALTER TABLE plants ADD COLUMN generation INTEGER NOT NULL DEFAULT 1;
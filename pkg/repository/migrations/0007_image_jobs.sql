@@ -0,0 +1,16 @@
+-- like events, image jobs are not removed when a plant is deleted, so plant_id does not reference plants
+CREATE TABLE image_jobs (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    plant_id     TEXT    NOT NULL,
+    image        TEXT    NOT NULL,
+    state        TEXT    NOT NULL,
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    last_error   TEXT    NOT NULL DEFAULT '',
+    created_at   TEXT    NOT NULL,
+    updated_at   TEXT    NOT NULL,
+    next_attempt TEXT,
+    UNIQUE (plant_id, image)
+);
+
+CREATE INDEX image_jobs_state_idx ON image_jobs (state);
@@ -3,6 +3,7 @@ package repository
 import (
 	"errors"
 	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/clock"
 	"github.com/williamnoble/kube-botany/pkg/fs"
 	"github.com/williamnoble/kube-botany/pkg/plant"
 	"maps"
@@ -12,11 +13,19 @@ import (
 	"time"
 )
 
+var (
+	// ErrPlantNotFound is returned when no plant has the given id
+	ErrPlantNotFound = errors.New("plant not found")
+
+	// ErrPlantExists is returned when a plant is created with the id of an existing plant
+	ErrPlantExists = errors.New("plant already exists")
+)
+
 type PlantRepository interface {
-	// NewPlant Create a new plant
+	// NewPlant Create a new plant, it fails with ErrPlantExists when a plant has the id
 	NewPlant(id, friendlyName, plantType string, creationTime time.Time) (*plant.Plant, error)
 
-	// GetPlant Retrieve a plant by ID
+	// GetPlant Retrieve a copy of a plant by ID, its state is brought up to date first
 	GetPlant(id string) (*plant.Plant, error)
 
 	// DeletePlant Delete a plant
@@ -25,15 +34,26 @@ type PlantRepository interface {
 	// ListPlantsByType List plants by type
 	ListPlantsByType(plantType string) (map[string][]string, error)
 
-	// ListAllPlants List all plants
+	// ListAllPlants List copies of all plants
 	ListAllPlants() map[string]*plant.Plant
 
 	// UpdatePlants Update all plants' state
 	UpdatePlants(ids []string) error
 
+	// UpdateAllPlants Updates the state of every plant in the store
+	UpdateAllPlants() error
+
 	// UpdatePlantById Updates a specific plant's state
 	UpdatePlantById(id string) error
 
+	// SavePlant persists changes made to a plant previously returned by the store
+	SavePlant(p *plant.Plant) error
+
+	// UpdatePlant brings a plant up to date, applies update to it and saves it, without the plant changing in
+	// between. Nothing is saved when update returns an error, which is returned. A copy of the saved plant is
+	// returned.
+	UpdatePlant(id string, update func(p *plant.Plant) error) (*plant.Plant, error)
+
 	// GetVarietyUnsafe Get plant type characteristics. This is not thread-safe.
 	GetVarietyUnsafe(plantType string) (plant.Variety, error)
 
@@ -43,11 +63,34 @@ type PlantRepository interface {
 	// Variety returns the characteristics of a particular variety of plant
 	Variety(variety string) (plant.Variety, error)
 
+	// Images returns the store holding the plants' images, keyed by plant ID
+	Images() fs.ImageStore
+
 	// ImageExists returns true when an image exists for the given key
 	ImageExists(key string, fileName string) bool
 
 	// SetImage saves an image using the given key
-	SetImage(id string, fileName string, image []byte)
+	SetImage(id string, fileName string, image []byte) error
+
+	// RecordEvent appends an event to a plant's history
+	RecordEvent(e plant.Event) error
+
+	// ListEvents returns a page of a plant's events, oldest first, along with the total number of matching events.
+	// A plant's history is retained after the plant is deleted.
+	ListEvents(plantID string, filter EventFilter) ([]plant.Event, int, error)
+
+	// SaveImageJob creates an image generation job when its Id is zero, assigning it an Id, otherwise it updates it
+	SaveImageJob(job *plant.ImageJob) error
+
+	// ListImageJobs returns a plant's image generation jobs, newest first
+	ListImageJobs(plantID string) ([]plant.ImageJob, error)
+
+	// DueImageJobs returns the image generation jobs of every plant which are due at the given time, oldest first
+	DueImageJobs(at time.Time) ([]plant.ImageJob, error)
+
+	// ClaimImageJob starts an attempt at a due job, holding a lease on it, see plant.ImageJob.Claim. It returns false
+	// when the job has changed since it was read, e.g. because it has been claimed by another replica.
+	ClaimImageJob(job *plant.ImageJob, at time.Time, lease time.Duration) (bool, error)
 }
 
 type InMemoryStore struct {
@@ -55,17 +98,36 @@ type InMemoryStore struct {
 	PlantsByVariety map[string][]string
 	Varieties       plant.Varieties
 	ImageStore      fs.ImageStore
+	Events          map[string][]plant.Event    // Events by plant ID
+	ImageJobs       map[string][]plant.ImageJob // Image generation jobs by plant ID, oldest first
+	Clock           clock.Clock                 // Source of the time to which plants are updated
+	lastEventId     int64
+	lastImageJobId  int64
 	mu              sync.RWMutex // Mutex for thread-safe access to plants
 }
 
 func NewInMemoryStore(populateStore bool, filePaths ...string) (PlantRepository, error) {
-	defaultPath := filepath.Join("pkg/plant/", "varieties.json")
-	varietiesFilePath := defaultPath
+	s, err := newInMemoryStore(varietiesPath(filePaths...))
+	if err != nil {
+		return nil, err
+	}
+
+	if populateStore {
+		s.populateSamplePlants()
+	}
+
+	return s, nil
+}
 
+// varietiesPath returns the first non-empty path or the default location of varieties.json.
+func varietiesPath(filePaths ...string) string {
 	if len(filePaths) > 0 && filePaths[0] != "" {
-		varietiesFilePath = filePaths[0]
+		return filePaths[0]
 	}
+	return filepath.Join("pkg/plant/", "varieties.json")
+}
 
+func newInMemoryStore(varietiesFilePath string) (*InMemoryStore, error) {
 	props, err := plant.VarietiesFromJson(varietiesFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("store: failed to create in-memory store: %w", err)
@@ -76,10 +138,9 @@ func NewInMemoryStore(populateStore bool, filePaths ...string) (PlantRepository,
 		Varieties:       props,
 		PlantsByVariety: make(map[string][]string),
 		ImageStore:      fs.NewInMemoryImageStore(),
-	}
-
-	if populateStore {
-		s.populateSamplePlants()
+		Events:          make(map[string][]plant.Event),
+		ImageJobs:       make(map[string][]plant.ImageJob),
+		Clock:           clock.System{},
 	}
 
 	return &s, nil
@@ -93,11 +154,16 @@ func (s *InMemoryStore) NewPlant(
 	health := plant.Health{
 		CurrentGrowth:     0,
 		CurrentWaterLevel: 50, // 50% watered
+		Vitality:          plant.MaxVitality,
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if _, exists := s.Plants[id]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrPlantExists, id)
+	}
+
 	variety, err := s.GetVarietyUnsafe(varietyType)
 	if err != nil {
 		return nil, err
@@ -107,6 +173,7 @@ func (s *InMemoryStore) NewPlant(
 		Id:           id,
 		FriendlyName: friendlyName,
 		Variety:      &variety,
+		Generation:   1,
 		CreationTime: creationTime,
 		LastUpdated:  creationTime,
 		Health:       health,
@@ -119,18 +186,65 @@ func (s *InMemoryStore) NewPlant(
 	s.Plants[id] = p
 	s.PlantsByVariety[varietyType] = append(s.PlantsByVariety[varietyType], id)
 
-	return p, nil
+	created := plant.NewEvent(id, plant.EventCreated, creationTime, "plant created")
+	after := p.Snapshot()
+	created.After = &after
+	s.recordEventsUnsafe(created)
+
+	return copyPlant(p), nil
+}
+
+// copyPlant returns a copy of a plant held by the store, so that callers never share the store's plants. The
+// variety is shared, varieties are never changed.
+func copyPlant(p *plant.Plant) *plant.Plant {
+	c := *p
+	return &c
 }
 
 func (s *InMemoryStore) GetPlant(id string) (*plant.Plant, error) {
+	p, _, err := s.getPlant(id)
+	return p, err
+}
+
+// getPlant returns a copy of the plant brought up to date, and true when updating it recorded events.
+func (s *InMemoryStore) getPlant(id string) (*plant.Plant, bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	p, ok := s.Plants[id]
 	if !ok {
-		return nil, errors.New("plant not found")
+		return nil, false, ErrPlantNotFound
 	}
-	return p, nil
+	changed := s.updatePlantUnsafe(p)
+	return copyPlant(p), changed, nil
+}
+
+func (s *InMemoryStore) UpdatePlant(id string, update func(p *plant.Plant) error) (*plant.Plant, error) {
+	p, _, err := s.modifyPlant(id, update)
+	return p, err
+}
+
+// modifyPlant is UpdatePlant, it also returns true when bringing the plant up to date recorded events, which are
+// kept even if update fails.
+func (s *InMemoryStore) modifyPlant(id string, update func(p *plant.Plant) error) (*plant.Plant, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.Plants[id]
+	if !ok {
+		return nil, false, ErrPlantNotFound
+	}
+	changed := s.updatePlantUnsafe(p)
+
+	updated := copyPlant(p)
+	if err := update(updated); err != nil {
+		return nil, changed, err
+	}
+	if updated.Id != id {
+		return nil, changed, fmt.Errorf("store: cannot change the id of plant %s", id)
+	}
+	s.Plants[id] = updated
+	return copyPlant(updated), changed, nil
 }
 
 func (s *InMemoryStore) UpdatePlantById(id string) error {
@@ -139,10 +253,43 @@ func (s *InMemoryStore) UpdatePlantById(id string) error {
 
 	p, ok := s.Plants[id]
 	if !ok {
-		return errors.New("plant not found")
+		return ErrPlantNotFound
+	}
+
+	s.updatePlantUnsafe(p)
+	return nil
+}
+
+func (s *InMemoryStore) UpdateAllPlants() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.Plants {
+		s.updatePlantUnsafe(p)
+	}
+	return nil
+}
+
+// updatePlantUnsafe updates the plant to the time of the store's clock and records the events of any change in
+// its condition, it returns true when events were recorded. The caller must hold the lock.
+func (s *InMemoryStore) updatePlantUnsafe(p *plant.Plant) bool {
+	before := p.Snapshot()
+	p.Update(s.Clock.Now())
+	events := p.ChangeEvents(before)
+	s.recordEventsUnsafe(events...)
+	return len(events) > 0
+}
+
+// SavePlant stores the given plant, the plant must already exist in the store.
+func (s *InMemoryStore) SavePlant(p *plant.Plant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.Plants[p.Id]; !ok {
+		return ErrPlantNotFound
 	}
 
-	p.Update(time.Now())
+	s.Plants[p.Id] = copyPlant(p)
 	return nil
 }
 
@@ -152,10 +299,16 @@ func (s *InMemoryStore) DeletePlant(id string) error {
 
 	p, ok := s.Plants[id]
 	if !ok {
-		return errors.New("plant not found")
+		return ErrPlantNotFound
 	}
 
 	delete(s.Plants, id)
+	s.ImageStore.DeleteKey(id)
+
+	deleted := plant.NewEvent(id, plant.EventDeleted, s.Clock.Now(), "plant deleted")
+	before := p.Snapshot()
+	deleted.Before = &before
+	s.recordEventsUnsafe(deleted)
 
 	if typeIDs, ok := s.PlantsByVariety[p.Variety.Type]; ok {
 		s.PlantsByVariety[p.Variety.Type] = slices.DeleteFunc(typeIDs, func(plantID string) bool {
@@ -184,7 +337,11 @@ func (s *InMemoryStore) ListAllPlants() map[string]*plant.Plant {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.Plants
+	plants := make(map[string]*plant.Plant, len(s.Plants))
+	for id, p := range s.Plants {
+		plants[id] = copyPlant(p)
+	}
+	return plants
 }
 
 func (s *InMemoryStore) UpdatePlants(ids []string) error {
@@ -196,10 +353,10 @@ func (s *InMemoryStore) UpdatePlants(ids []string) error {
 	for _, id := range ids {
 		p, ok := s.Plants[id]
 		if !ok {
-			failedErrs = append(failedErrs, fmt.Errorf("plant not found: %s", id))
+			failedErrs = append(failedErrs, fmt.Errorf("%w: %s", ErrPlantNotFound, id))
 			continue
 		}
-		p.Update(time.Now())
+		s.updatePlantUnsafe(p)
 	}
 
 	if len(failedErrs) > 0 {
@@ -224,17 +381,24 @@ func (s *InMemoryStore) populateSamplePlants() {
 		"DefaultBonsai123",
 		"my-bonsai",
 		"bonsai",
-		time.Now(),
+		s.Clock.Now(),
 	)
 	_, _ = s.NewPlant(
 		"DefaultSunflower234",
 		"my-sunflower",
 		"sunflower",
-		time.Now(),
+		s.Clock.Now(),
 	)
 
 }
 
+// setClock sets the source of the time to which plants are updated.
+func (s *InMemoryStore) setClock(c clock.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Clock = c
+}
+
 func (s *InMemoryStore) ListSupportedVarieties() []string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -253,6 +417,10 @@ func (s *InMemoryStore) Variety(variety string) (plant.Variety, error) {
 	return s.Varieties[variety], nil
 }
 
+func (s *InMemoryStore) Images() fs.ImageStore {
+	return s.ImageStore
+}
+
 func (s *InMemoryStore) ImageExists(key string, fileName string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -263,8 +431,33 @@ func (s *InMemoryStore) ImageExists(key string, fileName string) bool {
 	return true
 }
 
-func (s *InMemoryStore) SetImage(id string, fileName string, image []byte) {
+func (s *InMemoryStore) SetImage(id string, fileName string, image []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.ImageStore.SaveImage(id, fileName, image)
+	return s.ImageStore.SaveImage(id, fileName, image)
+}
+
+func (s *InMemoryStore) RecordEvent(e plant.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordEventsUnsafe(e)
+	return nil
+}
+
+// recordEventsUnsafe assigns each event the next id and appends it to the plant's history. This function should
+// ONLY be called by another function which has a mutex lock on the underlying data.
+func (s *InMemoryStore) recordEventsUnsafe(events ...plant.Event) {
+	for _, e := range events {
+		s.lastEventId++
+		e.Id = s.lastEventId
+		s.Events[e.PlantId] = append(s.Events[e.PlantId], e)
+	}
+}
+
+func (s *InMemoryStore) ListEvents(plantID string, filter EventFilter) ([]plant.Event, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events, total := filter.apply(s.Events[plantID])
+	return events, total, nil
 }
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoresUpdatePlants(t *testing.T) {
+	t.Parallel()
+	for _, driver := range []string{DriverMemory, DriverFile, DriverSQLite} {
+		t.Run(driver, func(t *testing.T) {
+			t.Parallel()
+			start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+			clk := clock.NewFrozen(start)
+			dir := t.TempDir()
+			s, err := New(Options{
+				Driver:        driver,
+				DataDir:       dir,
+				DatabasePath:  filepath.Join(dir, "plants.db"),
+				VarietiesPath: testVarietiesPath,
+				Clock:         clk,
+			})
+			require.NoError(t, err)
+
+			_, err = s.NewPlant("FooPlant", "MyBonsai", "bonsai", clk.Now())
+			require.NoError(t, err)
+			_, err = s.NewPlant("BarPlant", "MySunflower", "sunflower", clk.Now())
+			require.NoError(t, err)
+			_, err = s.NewPlant("FooPlant", "MyOrchid", "orchid", clk.Now())
+			assert.ErrorIs(t, err, ErrPlantExists, "an existing plant isn't replaced")
+
+			// a plant is brought up to date when it is read
+			require.NoError(t, clk.Advance(24*time.Hour))
+			p, err := s.GetPlant("FooPlant")
+			require.NoError(t, err)
+			assert.True(t, start.Add(24*time.Hour).Equal(p.LastUpdated))
+			assert.Less(t, p.CurrentWaterLevel(), p.MaximumWaterLevel())
+
+			// every plant is brought up to date by the growth tick, without being read
+			require.NoError(t, clk.Advance(24*time.Hour))
+			require.NoError(t, s.UpdateAllPlants())
+			for id, p := range s.ListAllPlants() {
+				assert.True(t, start.Add(48*time.Hour).Equal(p.LastUpdated), id)
+			}
+
+			// plants are changed atomically, and callers are given copies rather than the store's plants
+			require.NoError(t, clk.Advance(time.Hour))
+			p, err = s.UpdatePlant("FooPlant", func(p *plant.Plant) error {
+				assert.True(t, start.Add(49*time.Hour).Equal(p.LastUpdated), "brought up to date first")
+				p.FriendlyName = "MyRenamedBonsai"
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "MyRenamedBonsai", p.FriendlyName)
+			p.FriendlyName = "NotSaved"
+			s.ListAllPlants()["FooPlant"].FriendlyName = "NotSavedEither"
+			_, err = s.UpdatePlant("FooPlant", func(p *plant.Plant) error {
+				p.FriendlyName = "NotSavedOnError"
+				return assert.AnError
+			})
+			assert.ErrorIs(t, err, assert.AnError)
+			p, err = s.GetPlant("FooPlant")
+			require.NoError(t, err)
+			assert.Equal(t, "MyRenamedBonsai", p.FriendlyName)
+			_, err = s.UpdatePlant("MissingPlant", func(*plant.Plant) error { return nil })
+			assert.ErrorIs(t, err, ErrPlantNotFound)
+		})
+	}
+}
+
+func TestStoresImageJobs(t *testing.T) {
+	t.Parallel()
+	for _, driver := range []string{DriverMemory, DriverFile, DriverSQLite} {
+		t.Run(driver, func(t *testing.T) {
+			t.Parallel()
+			start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+			dir := t.TempDir()
+			opts := Options{
+				Driver:        driver,
+				DataDir:       dir,
+				DatabasePath:  filepath.Join(dir, "plants.db"),
+				VarietiesPath: testVarietiesPath,
+			}
+			s, err := New(opts)
+			require.NoError(t, err)
+
+			failed := plant.NewImageJob("FooPlant", "2025-01-01-FooPlant.png", 5, start)
+			require.NoError(t, s.SaveImageJob(&failed))
+			assert.NotZero(t, failed.Id)
+			failed.State = plant.ImageJobFailed
+			failed.Attempts = 1
+			failed.LastError = "transient"
+			failed.NextAttempt = start.Add(time.Hour)
+			require.NoError(t, s.SaveImageJob(&failed))
+
+			pending := plant.NewImageJob("FooPlant", "2025-01-02-FooPlant.png", 5, start.Add(24*time.Hour))
+			require.NoError(t, s.SaveImageJob(&pending))
+			dead := plant.NewImageJob("BarPlant", "2025-01-01-BarPlant.png", 5, start)
+			dead.State = plant.ImageJobDead
+			require.NoError(t, s.SaveImageJob(&dead))
+
+			missing := plant.ImageJob{Id: 100, PlantId: "FooPlant"}
+			assert.Error(t, s.SaveImageJob(&missing))
+
+			// jobs survive a restart of the stores which persist them
+			if driver != DriverMemory {
+				s, err = New(opts)
+				require.NoError(t, err)
+			}
+
+			jobs, err := s.ListImageJobs("FooPlant")
+			require.NoError(t, err)
+			require.Len(t, jobs, 2)
+			assert.Equal(t, pending.Id, jobs[0].Id, "newest first")
+			assert.Equal(t, plant.ImageJobFailed, jobs[1].State)
+			assert.Equal(t, "transient", jobs[1].LastError)
+			assert.True(t, start.Add(time.Hour).Equal(jobs[1].NextAttempt))
+			jobs, err = s.ListImageJobs("BazPlant")
+			require.NoError(t, err)
+			assert.Empty(t, jobs)
+
+			due, err := s.DueImageJobs(start.Add(time.Hour))
+			require.NoError(t, err)
+			require.Len(t, due, 1)
+			assert.Equal(t, failed.Id, due[0].Id)
+			due, err = s.DueImageJobs(start.Add(48 * time.Hour))
+			require.NoError(t, err)
+			require.Len(t, due, 2)
+			assert.Equal(t, []int64{failed.Id, pending.Id}, []int64{due[0].Id, due[1].Id})
+
+			// a job is claimed once, and is due again only once the claim's lease expires
+			at := start.Add(48 * time.Hour)
+			job, stale := due[0], due[0]
+			claimed, err := s.ClaimImageJob(&job, at, time.Hour)
+			require.NoError(t, err)
+			assert.True(t, claimed)
+			assert.Equal(t, plant.ImageJobRunning, job.State)
+			assert.Equal(t, 2, job.Attempts)
+			claimed, err = s.ClaimImageJob(&stale, at, time.Hour)
+			require.NoError(t, err)
+			assert.False(t, claimed, "the job was claimed since it was read")
+			claimed, err = s.ClaimImageJob(&job, at.Add(time.Minute), time.Hour)
+			require.NoError(t, err)
+			assert.False(t, claimed, "the job's lease hasn't expired")
+			due, err = s.DueImageJobs(at.Add(time.Minute))
+			require.NoError(t, err)
+			require.Len(t, due, 1)
+			assert.Equal(t, pending.Id, due[0].Id)
+			due, err = s.DueImageJobs(at.Add(time.Hour))
+			require.NoError(t, err)
+			require.Len(t, due, 2)
+			claimed, err = s.ClaimImageJob(&due[0], at.Add(time.Hour), time.Hour)
+			require.NoError(t, err)
+			assert.True(t, claimed, "a job whose lease expired is claimed again")
+			assert.Equal(t, 3, due[0].Attempts)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+)
+
+// Supported store drivers.
+const (
+	DriverMemory = "memory"
+	DriverFile   = "file"
+	DriverSQLite = "sqlite"
+)
+
+// Options configures the store returned by New.
+type Options struct {
+	Driver        string // one of DriverMemory, DriverFile or DriverSQLite
+	DataDir       string // directory used by the file driver
+	DatabasePath  string // path of the database used by the sqlite driver
+	VarietiesPath string // path to varieties.json, the default location is used when empty
+	Populate      bool   // populate an empty store with sample plants
+
+	// Images replaces the driver's own image store when set, e.g. with an fs.S3ImageStore
+	Images fs.ImageStore
+
+	// Clock is the source of the time to which plants are updated, the system clock when nil
+	Clock clock.Clock
+}
+
+// clockSetter is implemented by every store, so that New can replace the system clock.
+type clockSetter interface {
+	setClock(c clock.Clock)
+}
+
+// New returns the PlantRepository selected by opts.Driver.
+func New(opts Options) (PlantRepository, error) {
+	var (
+		store PlantRepository
+		err   error
+	)
+	switch opts.Driver {
+	case DriverMemory, "":
+		store, err = NewInMemoryStore(opts.Populate, opts.VarietiesPath)
+	case DriverFile:
+		store, err = NewFileStore(opts.DataDir, opts.Populate, opts.VarietiesPath)
+	case DriverSQLite:
+		store, err = NewSQLiteStore(opts.DatabasePath, opts.Populate, opts.VarietiesPath)
+	default:
+		return nil, fmt.Errorf("store: unsupported driver %q", opts.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.Clock != nil {
+		store.(clockSetter).setClock(opts.Clock)
+	}
+	if opts.Images == nil {
+		return store, nil
+	}
+	return &imageStoreOverride{PlantRepository: store, images: opts.Images}, nil
+}
+
+// imageStoreOverride keeps a repository's images in a different fs.ImageStore.
+type imageStoreOverride struct {
+	PlantRepository
+	images fs.ImageStore
+}
+
+// DeletePlant deletes the plant, then its images.
+func (s *imageStoreOverride) DeletePlant(id string) error {
+	if err := s.PlantRepository.DeletePlant(id); err != nil {
+		return err
+	}
+	s.images.DeleteKey(id)
+	return nil
+}
+
+func (s *imageStoreOverride) Images() fs.ImageStore {
+	return s.images
+}
+
+func (s *imageStoreOverride) ImageExists(key string, fileName string) bool {
+	_, err := s.images.GetImage(key, fileName)
+	return err == nil
+}
+
+func (s *imageStoreOverride) SetImage(id string, fileName string, image []byte) error {
+	return s.images.SaveImage(id, fileName, image)
+}
@@ -0,0 +1,689 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" driver
+)
+
+// SQLiteStore is a PlantRepository backed by a SQLite database. Unlike InMemoryStore the state lives entirely
+// in the database, so several replicas may share a single database file.
+type SQLiteStore struct {
+	db    *sql.DB
+	clock clock.Clock // Source of the time to which plants are updated
+}
+
+// plantColumns are the columns selected by scanPlant, varieties are joined so a plant can be built from a single row.
+const plantColumns = `p.id, p.friendly_name, p.motif, p.generation, p.creation_time, p.last_updated, p.died_at,
+	p.current_growth, p.current_water_level, p.vitality, p.drought_since, p.overwatered_since,
+	v.name, v.growth_rate, v.water_consumption, v.minimum_water_level, v.maximum_water_level, v.drought_grace_days`
+
+const plantsFrom = `plants p JOIN varieties v ON v.name = p.variety`
+
+// NewSQLiteStore opens (or creates) the database at path, applies schema migrations and synchronises the varieties
+// table with varieties.json. If the database has no plants and populateStore is true, sample plants are added.
+func NewSQLiteStore(path string, populateStore bool, filePaths ...string) (PlantRepository, error) {
+	varieties, err := plant.VarietiesFromJson(varietiesPath(filePaths...))
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to create sqlite store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("store: failed to create database directory: %w", err)
+	}
+
+	// busy_timeout lets concurrent writers (other replicas) wait for the lock rather than failing immediately,
+	// _txlock=immediate takes the write lock when a transaction begins to avoid deadlocking read-then-write
+	// transactions.
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to open database: %w", err)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLiteStore{db: db, clock: clock.System{}}
+	if err := s.syncVarieties(varieties); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if populateStore {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM plants`).Scan(&count); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("store: failed to count plants: %w", err)
+		}
+		if count == 0 {
+			s.populateSamplePlants()
+		}
+	}
+
+	return s, nil
+}
+
+// setClock sets the source of the time to which plants are updated.
+func (s *SQLiteStore) setClock(c clock.Clock) {
+	s.clock = c
+}
+
+// Close closes the underlying database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// syncVarieties upserts the varieties read from varieties.json, so changes to the file apply to existing plants.
+func (s *SQLiteStore) syncVarieties(varieties plant.Varieties) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("store: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for name, v := range varieties {
+		_, err := tx.Exec(`INSERT INTO varieties
+			(name, growth_rate, water_consumption, minimum_water_level, maximum_water_level, drought_grace_days)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (name) DO UPDATE SET
+				growth_rate = excluded.growth_rate,
+				water_consumption = excluded.water_consumption,
+				minimum_water_level = excluded.minimum_water_level,
+				maximum_water_level = excluded.maximum_water_level,
+				drought_grace_days = excluded.drought_grace_days`,
+			name, v.GrowthRatePerDay, v.WaterConsumptionUnitsPerDay, v.MinimumWaterLevel, v.MaximumWaterLevel,
+			v.DroughtGraceDays)
+		if err != nil {
+			return fmt.Errorf("store: failed to sync variety %s: %w", name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) NewPlant(id, friendlyName, varietyType string, creationTime time.Time) (*plant.Plant, error) {
+	variety, err := s.Variety(varietyType)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plant.Plant{
+		Id:           id,
+		FriendlyName: friendlyName,
+		Variety:      &variety,
+		Generation:   1,
+		CreationTime: creationTime,
+		LastUpdated:  creationTime,
+		Health: plant.Health{
+			CurrentGrowth:     0,
+			CurrentWaterLevel: 50, // 50% watered
+			Vitality:          plant.MaxVitality,
+		},
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// the transaction holds the write lock from the start, so no other plant can be created between the check and
+	// the insert
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM plants WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("store: failed to check plant %s: %w", id, err)
+	}
+	if exists {
+		return nil, fmt.Errorf("%w: %s", ErrPlantExists, id)
+	}
+
+	_, err = tx.Exec(`INSERT INTO plants
+		(id, friendly_name, variety, generation, creation_time, last_updated,
+			current_growth, current_water_level, vitality)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Id, p.FriendlyName, variety.Type, p.Generation, formatTime(p.CreationTime), formatTime(p.LastUpdated),
+		p.Health.CurrentGrowth, p.Health.CurrentWaterLevel, p.Health.Vitality)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to insert plant %s: %w", id, err)
+	}
+
+	created := plant.NewEvent(id, plant.EventCreated, creationTime, "plant created")
+	after := p.Snapshot()
+	created.After = &after
+	if err := recordEvents(tx, created); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("store: failed to commit plant %s: %w", id, err)
+	}
+	return p, nil
+}
+
+// GetPlant returns the plant brought up to date, the update is saved before the plant is returned.
+func (s *SQLiteStore) GetPlant(id string) (*plant.Plant, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p, err := getPlant(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := updatePlant(tx, p, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("store: failed to commit plant update: %w", err)
+	}
+	return p, nil
+}
+
+func (s *SQLiteStore) DeletePlant(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("store: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p, err := getPlant(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM plants WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("store: failed to delete plant %s: %w", id, err)
+	}
+
+	deleted := plant.NewEvent(id, plant.EventDeleted, s.clock.Now(), "plant deleted")
+	before := p.Snapshot()
+	deleted.Before = &before
+	if err := recordEvents(tx, deleted); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) ListPlantsByType(plantType string) (map[string][]string, error) {
+	if _, err := s.Variety(plantType); err != nil {
+		return make(map[string][]string), errors.New("plant type not found")
+	}
+
+	rows, err := s.db.Query(`SELECT id FROM plants WHERE variety = ? ORDER BY creation_time, id`, plantType)
+	if err != nil {
+		return make(map[string][]string), fmt.Errorf("store: failed to list plants: %w", err)
+	}
+	defer rows.Close()
+
+	var plantIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return make(map[string][]string), fmt.Errorf("store: failed to scan plant id: %w", err)
+		}
+		plantIDs = append(plantIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return make(map[string][]string), fmt.Errorf("store: failed to list plants: %w", err)
+	}
+
+	result := make(map[string][]string)
+	result[plantType] = plantIDs
+	return result, nil
+}
+
+// ListAllPlants returns every plant in the database. The interface does not allow an error to be returned,
+// so a failed query yields the plants read so far.
+func (s *SQLiteStore) ListAllPlants() map[string]*plant.Plant {
+	plants := make(map[string]*plant.Plant)
+
+	list, _ := listPlants(s.db)
+	for _, p := range list {
+		plants[p.Id] = p
+	}
+	return plants
+}
+
+// UpdatePlants updates the given plants within a single transaction, either every plant which exists is updated
+// or none are.
+func (s *SQLiteStore) UpdatePlants(ids []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("store: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var failedErrs []error
+	now := s.clock.Now()
+
+	for _, id := range ids {
+		p, err := getPlant(tx, id)
+		if err != nil {
+			failedErrs = append(failedErrs, fmt.Errorf("%w: %s", ErrPlantNotFound, id))
+			continue
+		}
+		if err := updatePlant(tx, p, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("store: failed to commit plant updates: %w", err)
+	}
+
+	if len(failedErrs) > 0 {
+		return fmt.Errorf("failed to update plants: %v", errors.Join(failedErrs...))
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) UpdatePlantById(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("store: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p, err := getPlant(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := updatePlant(tx, p, s.clock.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateAllPlants updates every plant within a single transaction.
+func (s *SQLiteStore) UpdateAllPlants() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("store: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	plants, err := listPlants(tx)
+	if err != nil {
+		return err
+	}
+	now := s.clock.Now()
+	for _, p := range plants {
+		if err := updatePlant(tx, p, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("store: failed to commit plant updates: %w", err)
+	}
+	return nil
+}
+
+// UpdatePlant updates the plant within a single transaction, the write lock taken when the transaction begins
+// keeps other writers, including other replicas, from changing the plant in between.
+func (s *SQLiteStore) UpdatePlant(id string, update func(p *plant.Plant) error) (*plant.Plant, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p, err := getPlant(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := updatePlant(tx, p, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	if err := update(p); err != nil {
+		// the plant is still brought up to date
+		if commitErr := tx.Commit(); commitErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("store: failed to commit plant update: %w", commitErr))
+		}
+		return nil, err
+	}
+	if p.Id != id {
+		return nil, fmt.Errorf("store: cannot change the id of plant %s", id)
+	}
+	if err := savePlant(tx, p); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("store: failed to commit plant %s: %w", id, err)
+	}
+	return p, nil
+}
+
+func (s *SQLiteStore) SavePlant(p *plant.Plant) error {
+	return savePlant(s.db, p)
+}
+
+// GetVarietyUnsafe reads the variety from the database, unlike InMemoryStore this is safe for concurrent use.
+func (s *SQLiteStore) GetVarietyUnsafe(plantType string) (plant.Variety, error) {
+	return s.Variety(plantType)
+}
+
+func (s *SQLiteStore) ListSupportedVarieties() []string {
+	var varieties []string
+
+	rows, err := s.db.Query(`SELECT name FROM varieties ORDER BY name`)
+	if err != nil {
+		return varieties
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return varieties
+		}
+		varieties = append(varieties, name)
+	}
+	return varieties
+}
+
+func (s *SQLiteStore) Variety(variety string) (plant.Variety, error) {
+	var v plant.Variety
+	err := s.db.QueryRow(`SELECT name, growth_rate, water_consumption, minimum_water_level, maximum_water_level,
+		drought_grace_days FROM varieties WHERE name = ?`, variety).
+		Scan(&v.Type, &v.GrowthRatePerDay, &v.WaterConsumptionUnitsPerDay, &v.MinimumWaterLevel, &v.MaximumWaterLevel,
+			&v.DroughtGraceDays)
+	if errors.Is(err, sql.ErrNoRows) {
+		return plant.Variety{}, errors.New("variety not found")
+	}
+	if err != nil {
+		return plant.Variety{}, fmt.Errorf("store: failed to read variety %s: %w", variety, err)
+	}
+	return v, nil
+}
+
+func (s *SQLiteStore) Images() fs.ImageStore {
+	return &sqliteImageStore{db: s.db}
+}
+
+func (s *SQLiteStore) ImageExists(key string, fileName string) bool {
+	_, err := s.Images().GetImage(key, fileName)
+	return err == nil
+}
+
+func (s *SQLiteStore) SetImage(id string, fileName string, image []byte) error {
+	return s.Images().SaveImage(id, fileName, image)
+}
+
+func (s *SQLiteStore) RecordEvent(e plant.Event) error {
+	return recordEvents(s.db, e)
+}
+
+func (s *SQLiteStore) ListEvents(plantID string, filter EventFilter) ([]plant.Event, int, error) {
+	where := `WHERE plant_id = ?`
+	args := []any{plantID}
+	if len(filter.Types) > 0 {
+		where += ` AND type IN (?` + strings.Repeat(`, ?`, len(filter.Types)-1) + `)`
+		for _, t := range filter.Types {
+			args = append(args, t.String())
+		}
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM events `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("store: failed to count events: %w", err)
+	}
+
+	// a negative limit is no limit in SQLite
+	limit := -1
+	if filter.Limit > 0 {
+		limit = filter.Limit
+	}
+	rows, err := s.db.Query(`SELECT id, plant_id, type, time, message, before, after FROM events `+where+
+		` ORDER BY id LIMIT ? OFFSET ?`, append(args, limit, max(filter.Offset, 0))...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("store: failed to list events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]plant.Event, 0)
+	for rows.Next() {
+		var (
+			e             plant.Event
+			at            string
+			before, after sql.NullString
+		)
+		if err := rows.Scan(&e.Id, &e.PlantId, &e.Type, &at, &e.Message, &before, &after); err != nil {
+			return nil, 0, fmt.Errorf("store: failed to scan event: %w", err)
+		}
+		if e.Time, err = parseTime(at); err != nil {
+			return nil, 0, err
+		}
+		if e.Before, err = unmarshalSnapshot(before); err != nil {
+			return nil, 0, err
+		}
+		if e.After, err = unmarshalSnapshot(after); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("store: failed to list events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+func (s *SQLiteStore) populateSamplePlants() {
+	_, _ = s.NewPlant(
+		"DefaultBonsai123",
+		"my-bonsai",
+		"bonsai",
+		s.clock.Now(),
+	)
+	_, _ = s.NewPlant(
+		"DefaultSunflower234",
+		"my-sunflower",
+		"sunflower",
+		s.clock.Now(),
+	)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func getPlant(q querier, id string) (*plant.Plant, error) {
+	row := q.QueryRow(`SELECT `+plantColumns+` FROM `+plantsFrom+` WHERE p.id = ?`, id)
+	p, err := scanPlant(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPlantNotFound
+	}
+	return p, err
+}
+
+// listPlants returns every plant, or those read before an error.
+func listPlants(q querier) ([]*plant.Plant, error) {
+	rows, err := q.Query(`SELECT ` + plantColumns + ` FROM ` + plantsFrom)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to list plants: %w", err)
+	}
+	defer rows.Close()
+
+	var plants []*plant.Plant
+	for rows.Next() {
+		p, err := scanPlant(rows)
+		if err != nil {
+			return plants, err
+		}
+		plants = append(plants, p)
+	}
+	return plants, rows.Err()
+}
+
+// updatePlant updates the plant to now, then saves it and the events of any change in its condition.
+func updatePlant(q querier, p *plant.Plant, now time.Time) error {
+	before := p.Snapshot()
+	p.Update(now)
+	if err := savePlant(q, p); err != nil {
+		return err
+	}
+	return recordEvents(q, p.ChangeEvents(before)...)
+}
+
+func savePlant(q querier, p *plant.Plant) error {
+	result, err := q.Exec(`UPDATE plants SET
+		friendly_name = ?, motif = ?, generation = ?, last_updated = ?, died_at = ?,
+		current_growth = ?, current_water_level = ?, vitality = ?, drought_since = ?, overwatered_since = ?
+		WHERE id = ?`,
+		p.FriendlyName, p.Motif, p.Generation, formatTime(p.LastUpdated), formatNullTime(p.DiedAt),
+		p.Health.CurrentGrowth, p.Health.CurrentWaterLevel, p.Health.Vitality, formatNullTime(p.Health.DroughtSince),
+		formatNullTime(p.Health.OverwateredSince), p.Id)
+	if err != nil {
+		return fmt.Errorf("store: failed to save plant %s: %w", p.Id, err)
+	}
+	return expectOneRow(result)
+}
+
+func scanPlant(row scanner) (*plant.Plant, error) {
+	var (
+		p                                      plant.Plant
+		v                                      plant.Variety
+		creationTime, lastUpdated              string
+		diedAt, droughtSince, overwateredSince sql.NullString
+	)
+
+	err := row.Scan(&p.Id, &p.FriendlyName, &p.Motif, &p.Generation, &creationTime, &lastUpdated, &diedAt,
+		&p.Health.CurrentGrowth, &p.Health.CurrentWaterLevel, &p.Health.Vitality, &droughtSince,
+		&overwateredSince, &v.Type, &v.GrowthRatePerDay, &v.WaterConsumptionUnitsPerDay, &v.MinimumWaterLevel,
+		&v.MaximumWaterLevel, &v.DroughtGraceDays)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.CreationTime, err = parseTime(creationTime); err != nil {
+		return nil, err
+	}
+	if p.LastUpdated, err = parseTime(lastUpdated); err != nil {
+		return nil, err
+	}
+	if p.DiedAt, err = parseNullTime(diedAt); err != nil {
+		return nil, err
+	}
+	if p.Health.DroughtSince, err = parseNullTime(droughtSince); err != nil {
+		return nil, err
+	}
+	if p.Health.OverwateredSince, err = parseNullTime(overwateredSince); err != nil {
+		return nil, err
+	}
+	p.Variety = &v
+
+	return &p, nil
+}
+
+func recordEvents(q querier, events ...plant.Event) error {
+	for _, e := range events {
+		before, err := marshalSnapshot(e.Before)
+		if err != nil {
+			return err
+		}
+		after, err := marshalSnapshot(e.After)
+		if err != nil {
+			return err
+		}
+		_, err = q.Exec(`INSERT INTO events (plant_id, type, time, message, before, after) VALUES (?, ?, ?, ?, ?, ?)`,
+			e.PlantId, e.Type.String(), formatTime(e.Time), e.Message, before, after)
+		if err != nil {
+			return fmt.Errorf("store: failed to record %s event for plant %s: %w", e.Type, e.PlantId, err)
+		}
+	}
+	return nil
+}
+
+// marshalSnapshot stores a snapshot as JSON text, nil snapshots are stored as NULL.
+func marshalSnapshot(snapshot *plant.Snapshot) (sql.NullString, error) {
+	if snapshot == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("store: failed to marshal snapshot: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func unmarshalSnapshot(s sql.NullString) (*plant.Snapshot, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	var snapshot plant.Snapshot
+	if err := json.Unmarshal([]byte(s.String), &snapshot); err != nil {
+		return nil, fmt.Errorf("store: failed to unmarshal snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+func expectOneRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("store: failed to read affected rows: %w", err)
+	}
+	if n == 0 {
+		return ErrPlantNotFound
+	}
+	return nil
+}
+
+// formatTime stores times as RFC3339 text so the database remains readable with the sqlite3 CLI.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// formatNullTime stores the zero time as NULL.
+func formatNullTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(t), Valid: true}
+}
+
+func parseNullTime(s sql.NullString) (time.Time, error) {
+	if !s.Valid {
+		return time.Time{}, nil
+	}
+	return parseTime(s.String)
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("store: failed to parse time %q: %w", s, err)
+	}
+	return t.Local(), nil
+}
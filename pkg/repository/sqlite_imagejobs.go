@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"time"
+)
+
+const imageJobColumns = `id, plant_id, image, state, attempts, max_attempts, last_error, created_at, updated_at,
+	next_attempt`
+
+// SaveImageJob creates the job when its Id is zero, assigning it the next Id, otherwise it updates the job.
+func (s *SQLiteStore) SaveImageJob(job *plant.ImageJob) error {
+	if job.Id == 0 {
+		result, err := s.db.Exec(`INSERT INTO image_jobs (plant_id, image, state, attempts, max_attempts, last_error,
+			created_at, updated_at, next_attempt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			job.PlantId, job.Image, job.State.String(), job.Attempts, job.MaxAttempts, job.LastError,
+			formatTime(job.CreatedAt), formatTime(job.UpdatedAt), formatNullTime(job.NextAttempt))
+		if err != nil {
+			return fmt.Errorf("store: failed to create image job for plant %s: %w", job.PlantId, err)
+		}
+		if job.Id, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("store: failed to read image job id: %w", err)
+		}
+		return nil
+	}
+
+	result, err := s.db.Exec(`UPDATE image_jobs SET state = ?, attempts = ?, max_attempts = ?, last_error = ?,
+		updated_at = ?, next_attempt = ? WHERE id = ?`,
+		job.State.String(), job.Attempts, job.MaxAttempts, job.LastError, formatTime(job.UpdatedAt),
+		formatNullTime(job.NextAttempt), job.Id)
+	if err != nil {
+		return fmt.Errorf("store: failed to update image job %d: %w", job.Id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errImageJobNotFound
+	}
+	return nil
+}
+
+// ListImageJobs returns the plant's image jobs, newest first.
+func (s *SQLiteStore) ListImageJobs(plantID string) ([]plant.ImageJob, error) {
+	return s.queryImageJobs(`SELECT `+imageJobColumns+` FROM image_jobs WHERE plant_id = ? ORDER BY id DESC`, plantID)
+}
+
+// DueImageJobs returns the jobs of every plant which are due at the given time, oldest first. Times are stored
+// as text which doesn't sort in time order, so the jobs which may be due are selected and then filtered.
+func (s *SQLiteStore) DueImageJobs(at time.Time) ([]plant.ImageJob, error) {
+	jobs, err := s.queryImageJobs(`SELECT `+imageJobColumns+` FROM image_jobs WHERE state IN (?, ?, ?) ORDER BY id`,
+		plant.ImageJobPending.String(), plant.ImageJobFailed.String(), plant.ImageJobRunning.String())
+	if err != nil {
+		return nil, err
+	}
+
+	due := jobs[:0]
+	for _, job := range jobs {
+		if job.Due(at) {
+			due = append(due, job)
+		}
+	}
+	return due, nil
+}
+
+// ClaimImageJob starts an attempt at a due job. The job is only updated while its state and update time are those
+// it was read with, so that of the replicas sharing the database only one claims it.
+func (s *SQLiteStore) ClaimImageJob(job *plant.ImageJob, at time.Time, lease time.Duration) (bool, error) {
+	if !job.Due(at) {
+		return false, nil
+	}
+	claimed := *job
+	claimed.Claim(at, lease)
+	result, err := s.db.Exec(`UPDATE image_jobs SET state = ?, attempts = ?, updated_at = ?, next_attempt = ?
+		WHERE id = ? AND state = ? AND updated_at = ?`,
+		claimed.State.String(), claimed.Attempts, formatTime(claimed.UpdatedAt), formatNullTime(claimed.NextAttempt),
+		job.Id, job.State.String(), formatTime(job.UpdatedAt))
+	if err != nil {
+		return false, fmt.Errorf("store: failed to claim image job %d: %w", job.Id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("store: failed to claim image job %d: %w", job.Id, err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+	*job = claimed
+	return true, nil
+}
+
+func (s *SQLiteStore) queryImageJobs(query string, args ...any) ([]plant.ImageJob, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to list image jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]plant.ImageJob, 0)
+	for rows.Next() {
+		var (
+			job                  plant.ImageJob
+			createdAt, updatedAt string
+			nextAttempt          sql.NullString
+		)
+		if err := rows.Scan(&job.Id, &job.PlantId, &job.Image, &job.State, &job.Attempts, &job.MaxAttempts,
+			&job.LastError, &createdAt, &updatedAt, &nextAttempt); err != nil {
+			return nil, fmt.Errorf("store: failed to scan image job: %w", err)
+		}
+		if job.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		if job.UpdatedAt, err = parseTime(updatedAt); err != nil {
+			return nil, err
+		}
+		if job.NextAttempt, err = parseNullTime(nextAttempt); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to list image jobs: %w", err)
+	}
+	return jobs, nil
+}
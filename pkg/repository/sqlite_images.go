@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"time"
+)
+
+// sqliteImageStore is an fs.ImageStore backed by the images table. Keys are plant ids, a plant's images are
+// deleted along with the plant.
+type sqliteImageStore struct {
+	db *sql.DB
+}
+
+func (s *sqliteImageStore) GetImage(key, fileName string) (*fs.ImageMetadata, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM images WHERE plant_id = ? AND file_name = ?`, key, fileName).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		if s.CountByKey(key) == 0 {
+			return nil, fs.ErrKeyNotFound
+		}
+		return nil, fs.ErrImageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	image := fs.NewImageMetadata(fileName, data)
+	return &image, nil
+}
+
+func (s *sqliteImageStore) SaveImage(key string, fileName string, imageData []byte) error {
+	_, err := s.db.Exec(`INSERT INTO images (plant_id, file_name, data, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (plant_id, file_name) DO UPDATE SET data = excluded.data, created_at = excluded.created_at`,
+		key, fileName, imageData, formatTime(time.Now()))
+	return err
+}
+
+func (s *sqliteImageStore) DeleteImage(key, fileName string) error {
+	result, err := s.db.Exec(`DELETE FROM images WHERE plant_id = ? AND file_name = ?`, key, fileName)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		if s.CountByKey(key) == 0 {
+			return fs.ErrKeyNotFound
+		}
+		return fs.ErrImageNotFound
+	}
+	return nil
+}
+
+func (s *sqliteImageStore) DeleteKey(key string) bool {
+	result, err := s.db.Exec(`DELETE FROM images WHERE plant_id = ?`, key)
+	if err != nil {
+		return false
+	}
+	n, err := result.RowsAffected()
+	return err == nil && n > 0
+}
+
+func (s *sqliteImageStore) GetImagesForKey(key string) ([]fs.ImageMetadata, bool) {
+	rows, err := s.db.Query(`SELECT file_name, data FROM images WHERE plant_id = ? ORDER BY file_name`, key)
+	if err != nil {
+		return nil, false
+	}
+	defer rows.Close()
+
+	var images []fs.ImageMetadata
+	for rows.Next() {
+		var (
+			fileName string
+			data     []byte
+		)
+		if err := rows.Scan(&fileName, &data); err != nil {
+			return nil, false
+		}
+		images = append(images, fs.NewImageMetadata(fileName, data))
+	}
+	if rows.Err() != nil {
+		return nil, false
+	}
+	return images, len(images) > 0
+}
+
+func (s *sqliteImageStore) StatImages(key string) ([]fs.ImageInfo, error) {
+	rows, err := s.db.Query(`SELECT file_name, length(data), created_at FROM images WHERE plant_id = ?
+		ORDER BY file_name`, key)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to list images of %s: %w", key, err)
+	}
+	defer rows.Close()
+
+	var infos []fs.ImageInfo
+	for rows.Next() {
+		var (
+			info    fs.ImageInfo
+			savedAt string
+		)
+		if err := rows.Scan(&info.FileName, &info.Size, &savedAt); err != nil {
+			return nil, fmt.Errorf("store: failed to scan image of %s: %w", key, err)
+		}
+		if info.SavedAt, err = parseTime(savedAt); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to list images of %s: %w", key, err)
+	}
+	if len(infos) == 0 {
+		return nil, fs.ErrKeyNotFound
+	}
+	return infos, nil
+}
+
+func (s *sqliteImageStore) List() []string {
+	rows, err := s.db.Query(`SELECT DISTINCT plant_id FROM images ORDER BY plant_id`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (s *sqliteImageStore) CountByKey(key string) int {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM images WHERE plant_id = ?`, key).Scan(&count); err != nil {
+		return 0
+	}
+	return count
+}
+
+func (s *sqliteImageStore) Clear() {
+	_, _ = s.db.Exec(`DELETE FROM images`)
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newSQLiteTestStore(t *testing.T, path string) *SQLiteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(path, false, testVarietiesPath)
+	require.NoError(t, err)
+	store := s.(*SQLiteStore)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "botany.db")
+	s := newSQLiteTestStore(t, path)
+
+	creationTime := time.Now().Add(-72 * time.Hour)
+	_, err := s.NewPlant("FooPlant", "MyBonsai", "bonsai", creationTime)
+	require.NoError(t, err)
+	_, err = s.NewPlant("BarPlant", "MySunflower", "sunflower", creationTime)
+	require.NoError(t, err)
+
+	// plant ids are unique
+	_, err = s.NewPlant("FooPlant", "MyBonsai", "bonsai", creationTime)
+	assert.ErrorIs(t, err, ErrPlantExists)
+
+	// unknown varieties are rejected
+	_, err = s.NewPlant("BazPlant", "MyFern", "fern", creationTime)
+	assert.Error(t, err)
+
+	p, err := s.GetPlant("FooPlant")
+	require.NoError(t, err)
+	assert.Equal(t, "bonsai", p.Variety.Type)
+	assert.Equal(t, int64(5), p.Variety.GrowthRatePerDay)
+	assert.True(t, creationTime.Equal(p.CreationTime))
+
+	p.TopUp()
+	p.Motif = "gopher"
+	p.Generation = 2
+	require.NoError(t, s.SavePlant(p))
+
+	byType, err := s.ListPlantsByType("sunflower")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"BarPlant"}, byType["sunflower"])
+
+	require.NoError(t, s.UpdatePlants([]string{"FooPlant", "BarPlant"}))
+	assert.Error(t, s.UpdatePlants([]string{"FooPlant", "MissingPlant"}))
+
+	require.NoError(t, s.DeletePlant("BarPlant"))
+	assert.Error(t, s.DeletePlant("BarPlant"))
+	assert.Len(t, s.ListAllPlants(), 1)
+
+	require.NoError(t, s.SetImage("FooPlant", "2025-01-01-FooPlant.png", []byte("fake-image")))
+	assert.True(t, s.ImageExists("FooPlant", "2025-01-01-FooPlant.png"))
+	assert.False(t, s.ImageExists("FooPlant", "2025-01-02-FooPlant.png"))
+	images, ok := s.Images().GetImagesForKey("FooPlant")
+	require.True(t, ok)
+	require.Len(t, images, 1)
+	assert.Equal(t, []byte("fake-image"), images[0].Data())
+	infos, err := s.Images().StatImages("FooPlant")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "2025-01-01-FooPlant.png", infos[0].FileName)
+	assert.Equal(t, int64(len("fake-image")), infos[0].Size)
+	assert.False(t, infos[0].SavedAt.IsZero())
+	_, err = s.Images().StatImages("BarPlant")
+	assert.ErrorIs(t, err, fs.ErrKeyNotFound)
+	_, err = s.Images().GetImage("BarPlant", "2025-01-01-FooPlant.png")
+	assert.ErrorIs(t, err, fs.ErrKeyNotFound)
+	assert.Equal(t, []string{"FooPlant"}, s.Images().List())
+
+	// the history of a deleted plant is retained, the sunflower started wilting when it was updated
+	events, total, err := s.ListEvents("BarPlant", EventFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, events, 3)
+	assert.Equal(t, plant.EventCreated, events[0].Type)
+	assert.Equal(t, plant.EventConditionChanged, events[1].Type)
+	assert.Equal(t, plant.ConditionWilting.String(), events[1].After.Condition)
+	assert.Equal(t, plant.EventDeleted, events[2].Type)
+
+	watered := plant.NewEvent("FooPlant", plant.EventWatered, time.Now(), "watered")
+	require.NoError(t, s.RecordEvent(watered))
+	events, total, err = s.ListEvents("FooPlant", EventFilter{Types: []plant.EventType{plant.EventWatered}, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, events, 1)
+	assert.Nil(t, events[0].Before)
+
+	// a second store (replica) sharing the database sees the same state, migrations are not re-applied
+	replica := newSQLiteTestStore(t, path)
+	restored, err := replica.GetPlant("FooPlant")
+	require.NoError(t, err)
+	assert.Greater(t, restored.CurrentGrowth(), int64(0))
+	assert.Equal(t, p.Vitality(), restored.Vitality())
+	assert.Equal(t, 4, restored.Variety.DroughtGraceDays)
+	assert.Equal(t, "gopher", restored.Motif)
+	assert.Equal(t, int64(2), restored.Generation)
+	assert.True(t, replica.ImageExists("FooPlant", "2025-01-01-FooPlant.png"))
+	assert.ElementsMatch(t, s.ListSupportedVarieties(), replica.ListSupportedVarieties())
+}
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	t.Parallel()
+
+	all, err := loadMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	for i, m := range all {
+		assert.Equal(t, i+1, m.version, "migration %s is out of sequence", m.name)
+	}
+}
@@ -0,0 +1,144 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/gen"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"log/slog"
+	"time"
+)
+
+// Report describes what a collection reclaimed.
+type Report struct {
+	Plants        int   // plants whose images were checked
+	ImagesDeleted int   // images, and image records, deleted
+	KeysDeleted   int   // keys deleted because their plant no longer exists
+	Bytes         int64 // size of everything deleted
+}
+
+// DefaultRecordGrace is the least time for which a new image, or image record, is left alone when it looks
+// orphaned: an image record is saved before its image is uploaded, and a plant's images may be saved after the
+// collection listed the plants.
+const DefaultRecordGrace = 10 * time.Minute
+
+// Collector deletes the images which a plant's retention policy no longer keeps, along with their image
+// records, and the images of plants which no longer exist.
+type Collector struct {
+	store    repository.PlantRepository
+	policies Policies
+	grace    time.Duration
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+// NewCollector returns a collector which applies policies to the images in store. Images saved within grace are
+// never treated as orphans, grace should be at least as long as it takes to generate and upload an image.
+func NewCollector(store repository.PlantRepository, policies Policies, grace time.Duration,
+	logger *slog.Logger) *Collector {
+	return &Collector{
+		store:    store,
+		policies: policies,
+		grace:    grace,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Run collects once, logging what the collection reclaimed. It is run by the server's job scheduler.
+func (c *Collector) Run(ctx context.Context) error {
+	report, err := c.Collect(ctx)
+	c.logger.Info("image collection complete",
+		"default_policy", c.policies.Default.String(),
+		"plants", report.Plants,
+		"images_deleted", report.ImagesDeleted,
+		"keys_deleted", report.KeysDeleted,
+		"bytes_reclaimed", report.Bytes)
+	return err
+}
+
+// Collect applies the retention policies once, stopping between keys when the context is done. Images are listed
+// without being read. Collection continues past errors, skipping any key whose images can't be listed, and the
+// report describes what was reclaimed even when an error is returned.
+func (c *Collector) Collect(ctx context.Context) (Report, error) {
+	var (
+		report Report
+		errs   []error
+	)
+	plants := c.store.ListAllPlants()
+	images := c.store.Images()
+	now := c.now()
+
+	for _, key := range images.List() {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("retention: collection stopped: %w", err))
+			break
+		}
+
+		stored, err := images.StatImages(key)
+		if errors.Is(err, fs.ErrKeyNotFound) {
+			continue // deleted since it was listed
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("retention: failed to list the images of %s: %w", key, err))
+			continue
+		}
+		sizes := make(map[string]int64, len(stored))
+		recent := make(map[string]bool)
+		for _, image := range stored {
+			sizes[image.FileName] = image.Size
+			if now.Sub(image.SavedAt) < c.grace {
+				recent[image.FileName] = true
+			}
+		}
+
+		if _, exists := plants[key]; !exists {
+			// recent images may belong to a plant created since the plants were listed
+			if len(recent) == 0 && images.DeleteKey(key) {
+				report.KeysDeleted++
+				for _, size := range sizes {
+					report.Bytes += size
+				}
+			}
+			continue
+		}
+		report.Plants++
+
+		var names []string
+		for name := range sizes {
+			if !gen.IsRecord(name) {
+				names = append(names, name)
+			}
+		}
+
+		// records are deleted along with their image, as are records whose image is already gone, unless the
+		// image may still be being uploaded
+		var deletions []string
+		for _, name := range c.policies.For(key).Expired(names) {
+			deletions = append(deletions, name)
+			if _, exists := sizes[gen.RecordFileName(name)]; exists {
+				deletions = append(deletions, gen.RecordFileName(name))
+			}
+		}
+		for name := range sizes {
+			if _, exists := sizes[gen.RecordImage(name)]; gen.IsRecord(name) && !exists && !recent[name] {
+				deletions = append(deletions, name)
+			}
+		}
+
+		for _, name := range deletions {
+			if err := images.DeleteImage(key, name); err != nil {
+				if !errors.Is(err, fs.ErrImageNotFound) && !errors.Is(err, fs.ErrKeyNotFound) {
+					errs = append(errs, fmt.Errorf("retention: failed to delete %s/%s: %w", key, name, err))
+				}
+				continue
+			}
+			report.ImagesDeleted++
+			report.Bytes += sizes[name]
+		}
+	}
+
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,131 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/gen"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestCollect(t *testing.T) {
+	t.Parallel()
+	store, err := repository.NewInMemoryStore(false, "../plant/varieties.json")
+	require.NoError(t, err)
+	_, err = store.NewPlant("FooPlant", "Foo", "bonsai", time.Now())
+	require.NoError(t, err)
+	_, err = store.NewPlant("BarPlant", "Bar", "cactus", time.Now())
+	require.NoError(t, err)
+
+	images := store.Images()
+	for _, name := range dailyImages("FooPlant", 10) {
+		require.NoError(t, images.SaveImage("FooPlant", name, []byte("image")))
+		require.NoError(t, images.SaveImage("FooPlant", gen.RecordFileName(name), []byte("{}")))
+	}
+	require.NoError(t, images.SaveImage("FooPlant", gen.RecordFileName("2024-12-31-FooPlant.png"), []byte("{}")))
+	for _, name := range dailyImages("BarPlant", 10) {
+		require.NoError(t, images.SaveImage("BarPlant", name, []byte("image")))
+	}
+	// images left behind by a plant which no longer exists
+	require.NoError(t, images.SaveImage("BazPlant", "2025-01-01-BazPlant.png", []byte("image")))
+
+	policies, err := NewPolicies(Policy{KeepLast: 5}, map[string]string{"BarPlant": "8/0"})
+	require.NoError(t, err)
+	collector := NewCollector(store, policies, DefaultRecordGrace, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	collector.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	report, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	// FooPlant loses five images, their records and the orphaned record, BarPlant two images
+	assert.Equal(t, Report{
+		Plants:        2,
+		ImagesDeleted: 5 + 5 + 1 + 2,
+		KeysDeleted:   1,
+		Bytes:         int64(5*len("image") + 6*len("{}") + 2*len("image") + len("image")),
+	}, report)
+
+	// images are deleted along with their records, and orphaned records are deleted
+	assert.Equal(t, 10, images.CountByKey("FooPlant"))
+	_, err = images.GetImage("FooPlant", "2025-01-06-FooPlant.png")
+	assert.NoError(t, err)
+	_, err = images.GetImage("FooPlant", gen.RecordFileName("2025-01-06-FooPlant.png"))
+	assert.NoError(t, err)
+	_, err = images.GetImage("FooPlant", gen.RecordFileName("2025-01-05-FooPlant.png"))
+	assert.Error(t, err)
+	assert.Equal(t, 8, images.CountByKey("BarPlant"))
+	assert.Equal(t, 0, images.CountByKey("BazPlant"))
+
+	// collecting again reclaims nothing
+	report, err = collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Report{Plants: 2}, report)
+}
+
+// unlistableImages fails to list the images of one key, as a store which is briefly unavailable would.
+type unlistableImages struct {
+	fs.ImageStore
+	key string
+}
+
+var errUnlistable = errors.New("connection reset")
+
+func (s unlistableImages) StatImages(key string) ([]fs.ImageInfo, error) {
+	if key == s.key {
+		return nil, errUnlistable
+	}
+	return s.ImageStore.StatImages(key)
+}
+
+func TestCollectSkips(t *testing.T) {
+	t.Parallel()
+	images := unlistableImages{ImageStore: fs.NewInMemoryImageStore(), key: "BarPlant"}
+	store, err := repository.New(repository.Options{VarietiesPath: "../plant/varieties.json", Images: images})
+	require.NoError(t, err)
+	for _, id := range []string{"FooPlant", "BarPlant"} {
+		_, err = store.NewPlant(id, id, "bonsai", time.Now())
+		require.NoError(t, err)
+	}
+
+	// a record whose image is still being uploaded, an orphaned record from an hour ago and the images of a
+	// plant created since the collection listed the plants
+	require.NoError(t, images.SaveImage("FooPlant", gen.RecordFileName("2025-01-02-FooPlant.png"), []byte("{}")))
+	require.NoError(t, images.SaveImage("FooPlant", gen.RecordFileName("2025-01-01-FooPlant.png"), []byte("{}")))
+	require.NoError(t, images.SaveImage("BazPlant", "2025-01-01-BazPlant.png", []byte("image")))
+	require.NoError(t, images.SaveImage("BarPlant", gen.RecordFileName("2025-01-01-BarPlant.png"), []byte("{}")))
+
+	policies, err := NewPolicies(Policy{KeepLast: 5}, nil)
+	require.NoError(t, err)
+	collector := NewCollector(store, policies, DefaultRecordGrace, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	saved := time.Now()
+	collector.now = func() time.Time { return saved }
+
+	// nothing is old enough to be an orphan, and a key whose images can't be listed is skipped
+	report, err := collector.Collect(context.Background())
+	assert.ErrorIs(t, err, errUnlistable)
+	assert.Equal(t, Report{Plants: 1}, report)
+	assert.Equal(t, 2, images.CountByKey("FooPlant"))
+	assert.Equal(t, 1, images.CountByKey("BarPlant"))
+	assert.Equal(t, 1, images.CountByKey("BazPlant"))
+
+	// once the grace period has passed the orphans are deleted, the unlisted key is still left alone
+	collector.now = func() time.Time { return saved.Add(DefaultRecordGrace + time.Minute) }
+	report, err = collector.Collect(context.Background())
+	assert.ErrorIs(t, err, errUnlistable)
+	assert.Equal(t, Report{Plants: 1, ImagesDeleted: 2, KeysDeleted: 1, Bytes: int64(2*len("{}") + len("image"))},
+		report)
+	assert.Equal(t, 0, images.CountByKey("FooPlant"))
+	assert.Equal(t, 1, images.CountByKey("BarPlant"))
+
+	// a cancelled collection stops before the next key
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err = collector.Collect(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, Report{}, report)
+}
@@ -0,0 +1,123 @@
+package retention
+
+import (
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy decides which of a plant's daily images are kept. The KeepLast most recent images are kept, along with
+// the most recent image of each of the KeepWeekly most recent ISO weeks which have an image, so that a long-lived
+// plant keeps a weekly record of its growth without keeping every day.
+type Policy struct {
+	KeepLast   int `json:"keep_last"`
+	KeepWeekly int `json:"keep_weekly"`
+}
+
+// Validate checks that the policy keeps at least the latest image, which is the one shown for the plant.
+func (p Policy) Validate() error {
+	if p.KeepLast < 1 {
+		return fmt.Errorf("retention: keep last must be at least 1, got %d", p.KeepLast)
+	}
+	if p.KeepWeekly < 0 {
+		return fmt.Errorf("retention: keep weekly must not be negative, got %d", p.KeepWeekly)
+	}
+	return nil
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("%d/%d", p.KeepLast, p.KeepWeekly)
+}
+
+// ParsePolicy parses a policy written as "<keep last>/<keep weekly>", e.g. "7/4".
+func ParsePolicy(s string) (Policy, error) {
+	last, weekly, ok := strings.Cut(s, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("retention: invalid policy %q, expected <keep last>/<keep weekly>", s)
+	}
+	var (
+		p   Policy
+		err error
+	)
+	if p.KeepLast, err = strconv.Atoi(strings.TrimSpace(last)); err != nil {
+		return Policy{}, fmt.Errorf("retention: invalid policy %q: %w", s, err)
+	}
+	if p.KeepWeekly, err = strconv.Atoi(strings.TrimSpace(weekly)); err != nil {
+		return Policy{}, fmt.Errorf("retention: invalid policy %q: %w", s, err)
+	}
+	return p, p.Validate()
+}
+
+// Policies holds the default policy and the policies of individual plants.
+type Policies struct {
+	Default Policy
+	Plants  map[string]Policy // by plant ID
+}
+
+// NewPolicies returns the default policy with per-plant overrides, parsed from a map of plant ID to policy as
+// accepted by ParsePolicy.
+func NewPolicies(defaultPolicy Policy, plants map[string]string) (Policies, error) {
+	if err := defaultPolicy.Validate(); err != nil {
+		return Policies{}, err
+	}
+	policies := Policies{Default: defaultPolicy, Plants: make(map[string]Policy, len(plants))}
+	for id, s := range plants {
+		p, err := ParsePolicy(s)
+		if err != nil {
+			return Policies{}, fmt.Errorf("retention: plant %s: %w", id, err)
+		}
+		policies.Plants[id] = p
+	}
+	return policies, nil
+}
+
+// For returns the policy of the plant.
+func (p Policies) For(id string) Policy {
+	if policy, ok := p.Plants[id]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+// Expired returns the images which the policy doesn't keep, newest first. Only file names beginning with a date
+// are daily images, other files are never expired.
+func (p Policy) Expired(fileNames []string) []string {
+	type dated struct {
+		name string
+		date time.Time
+	}
+	var images []dated
+	for _, name := range fileNames {
+		date, ok := plant.ImageDate(name)
+		if !ok {
+			continue
+		}
+		images = append(images, dated{name: name, date: date})
+	}
+	slices.SortFunc(images, func(a, b dated) int {
+		if c := b.date.Compare(a.date); c != 0 {
+			return c
+		}
+		return strings.Compare(b.name, a.name)
+	})
+
+	type week struct{ year, week int }
+	weeks := make(map[week]bool)
+	var expired []string
+	for i, image := range images {
+		year, n := image.date.ISOWeek()
+		w := week{year, n}
+		keep := i < p.KeepLast
+		if !weeks[w] && len(weeks) < p.KeepWeekly {
+			weeks[w] = true
+			keep = true
+		}
+		if !keep {
+			expired = append(expired, image.name)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,60 @@
+package retention
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"testing"
+)
+
+// dailyImages returns an image for each day of January 2025 up to and including the given day.
+func dailyImages(id string, days int) []string {
+	var images []string
+	for day := 1; day <= days; day++ {
+		images = append(images, fmt.Sprintf("2025-01-%02d-%s.png", day, id))
+	}
+	return images
+}
+
+func TestPolicyExpired(t *testing.T) {
+	t.Parallel()
+	images := append(dailyImages("FooPlant", 21), "botany.png")
+
+	// the last two days, then the latest image of each of the three most recent weeks: the 21st is the latest
+	// of its week, the 19th and 12th are Sundays
+	expired := Policy{KeepLast: 2, KeepWeekly: 3}.Expired(images)
+	assert.Len(t, expired, 17)
+	for _, kept := range []string{"2025-01-21-FooPlant.png", "2025-01-20-FooPlant.png",
+		"2025-01-19-FooPlant.png", "2025-01-12-FooPlant.png", "botany.png"} {
+		assert.NotContains(t, expired, kept)
+	}
+	assert.Equal(t, "2025-01-18-FooPlant.png", expired[0], "newest first")
+
+	// keeping more than there are expires nothing
+	assert.Empty(t, Policy{KeepLast: 30}.Expired(images))
+	assert.Equal(t, dailyImages("FooPlant", 20), slices.Sorted(slices.Values(Policy{KeepLast: 1}.Expired(images))))
+}
+
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+	p, err := ParsePolicy("7/4")
+	require.NoError(t, err)
+	assert.Equal(t, Policy{KeepLast: 7, KeepWeekly: 4}, p)
+	assert.Equal(t, "7/4", p.String())
+
+	for _, invalid := range []string{"", "7", "a/4", "7/b", "0/4", "7/-1"} {
+		_, err := ParsePolicy(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	policies, err := NewPolicies(Policy{KeepLast: 30, KeepWeekly: 52}, map[string]string{"FooPlant": "7/0"})
+	require.NoError(t, err)
+	assert.Equal(t, Policy{KeepLast: 7}, policies.For("FooPlant"))
+	assert.Equal(t, Policy{KeepLast: 30, KeepWeekly: 52}, policies.For("BarPlant"))
+
+	_, err = NewPolicies(Policy{}, nil)
+	assert.Error(t, err)
+	_, err = NewPolicies(Policy{KeepLast: 30}, map[string]string{"FooPlant": "never"})
+	assert.ErrorContains(t, err, "FooPlant")
+}
@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a job runs.
+type Schedule interface {
+	// Next returns the first time after the given time at which the job runs
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// Every returns a schedule which runs a job at a fixed interval.
+func Every(interval time.Duration) Schedule {
+	return every(interval)
+}
+
+type every time.Duration
+
+func (e every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+func (e every) String() string {
+	return "@every " + time.Duration(e).String()
+}
+
+// Parse parses a schedule, which is either an interval such as "5m" or "@every 5m", a cron expression such as
+// "*/15 * * * *", or one of the cron macros @hourly, @daily, @weekly, @monthly and @yearly.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		spec = strings.TrimSpace(interval)
+	}
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval <= 0 {
+			return nil, fmt.Errorf("scheduler: interval must be positive, got %s", interval)
+		}
+		return Every(interval), nil
+	}
+	return ParseCron(spec)
+}
+
+// cronMacros are the cron expressions of the supported macros.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField is the set of values matched by one field of a cron expression, as a bit set.
+type cronField uint64
+
+func (f cronField) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+// cronBounds are the minimum and maximum values of each field of a cron expression.
+var cronBounds = [5]struct{ min, max int }{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0 and 7 are both Sunday
+}
+
+// Cron is a schedule described by a standard five field cron expression: minute, hour, day of month, month and
+// day of week. Fields are a "*", a value, a range "1-5", or a list of these "1,3,5", each optionally with a step
+// "*/15". As in cron, when both the day of month and day of week are restricted a day matching either runs.
+type Cron struct {
+	spec                         string
+	minute, hour, dom, month     cronField
+	dow                          cronField
+	domRestricted, dowRestricted bool
+}
+
+// ParseCron parses a cron expression, or a cron macro such as @daily.
+func ParseCron(spec string) (*Cron, error) {
+	expr := spec
+	if macro, ok := cronMacros[spec]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("scheduler: cron expression %q must have 5 fields, got %d", spec, len(fields))
+	}
+
+	var parsed [5]cronField
+	for i, field := range fields {
+		f, err := parseCronField(field, cronBounds[i].min, cronBounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("scheduler: cron expression %q: %w", spec, err)
+		}
+		parsed[i] = f
+	}
+	// Sunday may be written as 7
+	if parsed[4].has(7) {
+		parsed[4] |= 1
+	}
+
+	return &Cron{
+		spec:          spec,
+		minute:        parsed[0],
+		hour:          parsed[1],
+		dom:           parsed[2],
+		month:         parsed[3],
+		dow:           parsed[4],
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}, nil
+}
+
+// parseCronField parses one comma separated field of a cron expression.
+func parseCronField(field string, min, max int) (cronField, error) {
+	var f cronField
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			loPart, hiPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(loPart); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiPart); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if hasStep {
+				// "5/15" runs from 5 to the maximum in steps of 15
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+// Next returns the first minute after the given time matched by the expression, in the location of the given time.
+// A zero time is returned when no time within five years matches, e.g. for "0 0 30 2 *".
+func (c *Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	loc := after.Location()
+
+	for t.Before(limit) {
+		if !c.month.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.hour.has(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !c.minute.has(t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchesDay returns true when the day of t is matched by the day of month and day of week fields.
+func (c *Cron) matchesDay(t time.Time) bool {
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func (c *Cron) String() string {
+	return c.spec
+}
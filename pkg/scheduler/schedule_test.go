@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	// Wednesday
+	after := time.Date(2025, 1, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"5m", after.Add(5 * time.Minute)},
+		{"@every 1h30m", after.Add(90 * time.Minute)},
+		{"* * * * *", time.Date(2025, 1, 1, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 6 * * *", time.Date(2025, 1, 2, 6, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * *", time.Date(2025, 1, 1, 13, 30, 0, 0, time.UTC)},
+		{"0 0 * * 6,7", time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 1", time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)}, // the 15th or a Monday
+		{"@hourly", time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.next, s.Next(after), tt.spec)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+	for _, spec := range []string{"", "-5m", "@every never", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"*/0 * * * *", "5-1 * * * *", "a * * * *", "@fortnightly"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
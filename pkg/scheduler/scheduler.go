@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownJob is returned when no job has the given name
+	ErrUnknownJob = errors.New("scheduler: unknown job")
+	// ErrRunning is returned when a job is triggered while it is still running
+	ErrRunning = errors.New("scheduler: job is already running")
+	// ErrNotStarted is returned when a job is triggered while the scheduler isn't running
+	ErrNotStarted = errors.New("scheduler: scheduler is not running")
+)
+
+// Job is a named task run on a schedule.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context) error
+
+	// Timeout cancels the context of a run which takes longer, runs are not timed out when it is zero
+	Timeout time.Duration
+	// Jitter delays each scheduled run by a random duration up to Jitter, so that jobs don't all run at once
+	Jitter time.Duration
+	// Immediate runs the job as soon as the scheduler starts, as well as on its schedule
+	Immediate bool
+}
+
+// Status describes a job and its most recent run.
+type Status struct {
+	Name         string    `json:"name"`
+	Schedule     string    `json:"schedule"`
+	Running      bool      `json:"running"`
+	Runs         int       `json:"runs"`                    // completed runs
+	Skipped      int       `json:"skipped"`                 // scheduled runs skipped because the job was still running
+	LastRun      time.Time `json:"last_run,omitzero"`       // start of the most recent run
+	LastDuration string    `json:"last_duration,omitempty"` // duration of the most recent completed run
+	LastError    string    `json:"last_error,omitempty"`    // error of the most recent completed run, if it failed
+	NextRun      time.Time `json:"next_run,omitzero"`       // time of the next scheduled run
+}
+
+// Scheduler runs jobs on their schedules. A job never runs concurrently with itself: a scheduled run is skipped
+// while the previous run is still going, and a manual trigger is refused.
+type Scheduler struct {
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	jobs    map[string]*entry
+	ctx     context.Context // the context of Run, nil until the scheduler is running
+	stopped bool            // true once Run has stopped starting jobs
+	runs    sync.WaitGroup
+}
+
+type entry struct {
+	job    Job
+	status Status // guarded by Scheduler.mu
+}
+
+// New returns a scheduler without jobs
+func New(logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		logger: logger,
+		jobs:   make(map[string]*entry),
+	}
+}
+
+// Add adds a job, jobs must be added before the scheduler is run.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return errors.New("scheduler: a job needs a name, a schedule and a function to run")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return fmt.Errorf("scheduler: cannot add job %q to a running scheduler", job.Name)
+	}
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("scheduler: duplicate job %q", job.Name)
+	}
+	s.jobs[job.Name] = &entry{
+		job:    job,
+		status: Status{Name: job.Name, Schedule: job.Schedule.String()},
+	}
+	return nil
+}
+
+// Run runs jobs on their schedules until the context is cancelled, then waits for running jobs to return.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	entries := make([]*entry, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+
+	var loops sync.WaitGroup
+	for _, e := range entries {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.loop(ctx, e)
+		}()
+	}
+	loops.Wait()
+
+	// runs are added under the lock, so none start once stopped is set
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	s.runs.Wait()
+}
+
+// loop starts the runs of a job at its scheduled times.
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	if e.job.Immediate {
+		s.start(ctx, e)
+	}
+	for {
+		next := e.job.Schedule.Next(time.Now())
+		if next.IsZero() {
+			s.logger.Warn("job has no further runs", "job", e.job.Name, "schedule", e.job.Schedule.String())
+			return
+		}
+		if e.job.Jitter > 0 {
+			next = next.Add(rand.N(e.job.Jitter))
+		}
+		s.mu.Lock()
+		e.status.NextRun = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			if !s.start(ctx, e) {
+				s.logger.Warn("skipping job, the previous run is still going", "job", e.job.Name)
+			}
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Trigger starts a run of the named job now, outside its schedule.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	e, ok := s.jobs[name]
+	ctx, stopped := s.ctx, s.stopped
+	s.mu.Unlock()
+
+	switch {
+	case ctx == nil, stopped:
+		return ErrNotStarted
+	case !ok:
+		return fmt.Errorf("%w: %q", ErrUnknownJob, name)
+	case !s.start(ctx, e):
+		return fmt.Errorf("%w: %q", ErrRunning, name)
+	}
+	return nil
+}
+
+// start starts a run of the job unless it is already running, it returns false when the run was skipped.
+func (s *Scheduler) start(ctx context.Context, e *entry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return false
+	}
+	if e.status.Running {
+		e.status.Skipped++
+		return false
+	}
+	e.status.Running = true
+	e.status.LastRun = time.Now()
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		s.run(ctx, e)
+	}()
+	return true
+}
+
+// run runs the job once and records the outcome.
+func (s *Scheduler) run(ctx context.Context, e *entry) {
+	if e.job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.job.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := runJob(ctx, e.job)
+	duration := time.Since(start)
+	if err != nil {
+		s.logger.Error("job failed", "job", e.job.Name, "duration", duration, "error", err)
+	} else {
+		s.logger.Info("job complete", "job", e.job.Name, "duration", duration)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.status.Running = false
+	e.status.Runs++
+	e.status.LastDuration = duration.String()
+	e.status.LastError = ""
+	if err != nil {
+		e.status.LastError = err.Error()
+	}
+}
+
+// runJob runs the job, recovering a panic as an error so that one bad run doesn't stop the scheduler.
+func runJob(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("scheduler: job %q panicked: %v", job.Name, r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// Status returns the status of every job, ordered by name.
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		statuses = append(statuses, e.status)
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return statuses
+}
+
+// JobStatus returns the status of the named job.
+func (s *Scheduler) JobStatus(name string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.jobs[name]
+	if !ok {
+		return Status{}, fmt.Errorf("%w: %q", ErrUnknownJob, name)
+	}
+	return e.status, nil
+}
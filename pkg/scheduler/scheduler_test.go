@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// runScheduler runs the scheduler until the test ends
+func runScheduler(t *testing.T, s *Scheduler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	require.Eventually(t, func() bool {
+		return !errors.Is(s.Trigger(""), ErrNotStarted)
+	}, time.Second, time.Millisecond)
+}
+
+func TestSchedulerRunsJobs(t *testing.T) {
+	t.Parallel()
+	s := New(slog.New(slog.DiscardHandler))
+	runs := make(chan struct{}, 10)
+	require.NoError(t, s.Add(Job{
+		Name:     "tick",
+		Schedule: Every(10 * time.Millisecond),
+		Jitter:   time.Millisecond,
+		Run: func(ctx context.Context) error {
+			runs <- struct{}{}
+			return errors.New("wilted")
+		},
+	}))
+	assert.Error(t, s.Add(Job{Name: "tick", Schedule: Every(time.Hour), Run: func(context.Context) error { return nil }}))
+	assert.Error(t, s.Add(Job{Name: "incomplete"}))
+
+	runScheduler(t, s)
+	for range 2 {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatal("job did not run")
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		status, err := s.JobStatus("tick")
+		return err == nil && status.Runs >= 2
+	}, time.Second, time.Millisecond)
+	statuses := s.Status()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "@every 10ms", statuses[0].Schedule)
+	assert.Equal(t, "wilted", statuses[0].LastError)
+	assert.False(t, statuses[0].LastRun.IsZero())
+	assert.False(t, statuses[0].NextRun.IsZero())
+	assert.Error(t, s.Add(Job{Name: "late", Schedule: Every(time.Hour), Run: func(context.Context) error { return nil }}))
+}
+
+func TestSchedulerPreventsOverlap(t *testing.T) {
+	t.Parallel()
+	s := New(slog.New(slog.DiscardHandler))
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	require.NoError(t, s.Add(Job{
+		Name:      "slow",
+		Schedule:  Every(time.Millisecond),
+		Immediate: true,
+		Run: func(ctx context.Context) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		},
+	}))
+	runScheduler(t, s)
+	<-started
+
+	assert.ErrorIs(t, s.Trigger("slow"), ErrRunning)
+	assert.ErrorIs(t, s.Trigger("missing"), ErrUnknownJob)
+	assert.Eventually(t, func() bool {
+		status, _ := s.JobStatus("slow")
+		return status.Running && status.Skipped > 0
+	}, time.Second, time.Millisecond)
+	close(release)
+}
+
+func TestSchedulerTimeoutAndTrigger(t *testing.T) {
+	t.Parallel()
+	s := New(slog.New(slog.DiscardHandler))
+	require.NoError(t, s.Add(Job{
+		Name:     "patient",
+		Schedule: Every(time.Hour),
+		Timeout:  10 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}))
+	require.NoError(t, s.Add(Job{
+		Name:     "panicky",
+		Schedule: Every(time.Hour),
+		Run: func(ctx context.Context) error {
+			panic("root rot")
+		},
+	}))
+	assert.ErrorIs(t, s.Trigger("patient"), ErrNotStarted)
+	runScheduler(t, s)
+
+	require.NoError(t, s.Trigger("patient"))
+	require.NoError(t, s.Trigger("panicky"))
+	assert.Eventually(t, func() bool {
+		statuses := s.Status()
+		return statuses[0].Runs == 1 && statuses[1].Runs == 1
+	}, time.Second, time.Millisecond)
+	statuses := s.Status()
+	assert.Equal(t, "panicky", statuses[0].Name)
+	assert.Contains(t, statuses[0].LastError, "root rot")
+	assert.Equal(t, context.DeadlineExceeded.Error(), statuses[1].LastError)
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	chi "github.com/go-chi/chi/v5"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/scheduler"
+	"net/http"
+	"time"
+)
+
+// ClockResponse is the response returned by the admin clock endpoint
+type ClockResponse struct {
+	clock.State
+	Simulated bool `json:"simulated"` // False when the server runs on the system clock, which cannot be changed
+}
+
+// ClockRequest changes simulated time, fields which are not set are left unchanged. Changes are applied in the
+// order set, advance, factor then frozen, so a clock can be moved to a time and frozen there in one request.
+type ClockRequest struct {
+	Set     *time.Time `json:"set,omitempty"`     // Simulated time to move forward to
+	Advance string     `json:"advance,omitempty"` // Duration to move simulated time forward by, e.g. "72h"
+	Factor  *float64   `json:"factor,omitempty"`  // Simulated seconds per real second while running
+	Frozen  *bool      `json:"frozen,omitempty"`  // True to stop simulated time, false to restart it
+}
+
+// now returns the time of the simulation
+func (s *Server) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
+// clockResponse describes the server's clock
+func (s *Server) clockResponse() ClockResponse {
+	if simulated, ok := s.clock.(*clock.Simulated); ok {
+		return ClockResponse{State: simulated.State(), Simulated: true}
+	}
+	return ClockResponse{State: clock.State{Now: s.now(), Factor: 1}}
+}
+
+// HandleGetClock returns the time of the simulation, and how it passes
+func (s *Server) HandleGetClock(w http.ResponseWriter, r *http.Request) {
+	if err := s.encodeJsonResponse(w, r, http.StatusOK, s.clockResponse()); err != nil {
+		s.InternalServerErrorResponse(w, err)
+	}
+}
+
+// HandleUpdateClock advances, freezes or accelerates simulated time. Plants are brought up to date with the new
+// time before the clock is returned, so that a fast-forwarded garden can be inspected straight away.
+func (s *Server) HandleUpdateClock(w http.ResponseWriter, r *http.Request) {
+	simulated, ok := s.clock.(*clock.Simulated)
+	if !ok {
+		http.Error(w, "The server is running on the system clock, set SIMULATED_CLOCK to change time",
+			http.StatusConflict)
+		return
+	}
+
+	var req ClockRequest
+	if err := s.decodeJsonRequest(r, &req); err != nil {
+		http.Error(w, "Error decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var advance time.Duration
+	if req.Advance != "" {
+		var err error
+		if advance, err = time.ParseDuration(req.Advance); err != nil {
+			http.Error(w, "Invalid advance duration: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := applyClockRequest(simulated, req, advance); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.UpdateAllPlants(); err != nil {
+		s.Logger.Error("failed to update plants after changing the clock", "error", err)
+	}
+
+	if err := s.encodeJsonResponse(w, r, http.StatusOK, s.clockResponse()); err != nil {
+		s.InternalServerErrorResponse(w, err)
+	}
+}
+
+// applyClockRequest applies the changes of a request to a simulated clock, in the documented order
+func applyClockRequest(c *clock.Simulated, req ClockRequest, advance time.Duration) error {
+	if req.Set != nil {
+		if err := c.Set(*req.Set); err != nil {
+			return err
+		}
+	}
+	if advance != 0 {
+		if err := c.Advance(advance); err != nil {
+			return err
+		}
+	}
+	if req.Factor != nil {
+		if err := c.SetFactor(*req.Factor); err != nil {
+			return err
+		}
+	}
+	if req.Frozen != nil {
+		if *req.Frozen {
+			c.Freeze()
+		} else {
+			c.Resume()
+		}
+	}
+	return nil
+}
+
+// JobsResponse is the response returned by the admin jobs endpoint
+type JobsResponse struct {
+	Jobs []scheduler.Status `json:"jobs"` // Background jobs, ordered by name
+}
+
+// HandleListJobs returns the schedule and most recent run of each background job
+func (s *Server) HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	response := JobsResponse{Jobs: []scheduler.Status{}}
+	if s.jobs != nil {
+		response.Jobs = s.jobs.Status()
+	}
+	if err := s.encodeJsonResponse(w, r, http.StatusOK, response); err != nil {
+		s.InternalServerErrorResponse(w, err)
+	}
+}
+
+// HandleRunJob starts a run of a background job outside its schedule, the run continues after the response.
+func (s *Server) HandleRunJob(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if s.jobs == nil {
+		http.Error(w, "Background jobs are not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	err := s.jobs.Trigger(name)
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, scheduler.ErrRunning):
+		http.Error(w, "Job is already running", http.StatusConflict)
+		return
+	case errors.Is(err, scheduler.ErrNotStarted):
+		http.Error(w, "Background jobs are not running", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		s.InternalServerErrorResponse(w, err)
+		return
+	}
+
+	status, err := s.jobs.JobStatus(name)
+	if err != nil {
+		s.InternalServerErrorResponse(w, err)
+		return
+	}
+	if err := s.encodeJsonResponse(w, r, http.StatusAccepted, status); err != nil {
+		s.InternalServerErrorResponse(w, err)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFrozen(start)
+	s, err := repository.New(repository.Options{VarietiesPath: "../plant/varieties.json", Clock: clk})
+	require.NoError(t, err)
+	server := &Server{store: s, clock: clk}
+
+	send := func(method, body string) (int, ClockResponse) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/api/admin/clock", strings.NewReader(body))
+		server.Routes().ServeHTTP(rr, req)
+		var response ClockResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		}
+		return rr.Code, response
+	}
+
+	code, response := send(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, ClockResponse{State: clock.State{Now: start, Factor: 1, Frozen: true}, Simulated: true}, response)
+
+	// plants created over the API are planted at the simulated time, and age when it is advanced
+	rr := httptest.NewRecorder()
+	server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/plants",
+		strings.NewReader(`{"id": "TestPlant", "friendly_name": "TestBonsai", "variety": "bonsai"}`)))
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	code, response = send(http.MethodPost, `{"advance": "72h", "factor": 3600, "frozen": true}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, start.Add(72*time.Hour), response.Now)
+	assert.Equal(t, 3600.0, response.Factor)
+
+	p, err := s.GetPlant("TestPlant")
+	require.NoError(t, err)
+	assert.Equal(t, start, p.CreationTime)
+	assert.Equal(t, start.Add(72*time.Hour), p.LastUpdated)
+	assert.Equal(t, 3, p.DaysAlive())
+
+	for _, body := range []string{`{"advance": "-1h"}`, `{"advance": "soon"}`, `{"set": "2024-01-01T00:00:00Z"}`,
+		`{"factor": 0}`} {
+		code, _ = send(http.MethodPost, body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+
+	// the system clock can be read but not changed
+	server = &Server{store: s, clock: clock.System{}}
+	code, response = send(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.False(t, response.Simulated)
+	code, _ = send(http.MethodPost, `{"advance": "1h"}`)
+	assert.Equal(t, http.StatusConflict, code)
+}
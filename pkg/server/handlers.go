@@ -1,10 +1,23 @@
 package server
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	chi "github.com/go-chi/chi/v5"
+	"github.com/williamnoble/kube-botany/pkg/controller"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/render"
+	"github.com/williamnoble/kube-botany/pkg/repository"
 	"github.com/williamnoble/kube-botany/pkg/types"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,7 +34,10 @@ func (s *Server) HandleListPlants(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// HandleGetPlant returns a single plant by ID as JSON
+// HandleGetPlant returns a single plant by ID in the format preferred by the request's Accept header: the plant
+// detail page for browsers, and JSON for clients which accept any media type, unless they are command line clients
+// such as curl which are sent ASCII art coloured for the terminal. Any format of the server's renderers may be
+// requested by its media type, e.g. image/svg+xml.
 func (s *Server) HandleGetPlant(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	p, err := s.store.GetPlant(id)
@@ -29,55 +45,158 @@ func (s *Server) HandleGetPlant(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Plant not found", http.StatusNotFound)
 		return
 	}
-	plantDTO := types.IntoPlantDTO(p)
-	err = s.encodeJsonResponse(w, r, http.StatusOK, plantDTO)
-	if err != nil {
-		s.InternalServerErrorResponse(w, err)
+
+	w.Header().Set("Vary", "Accept, User-Agent")
+	for _, mediaRange := range acceptedMediaRanges(r) {
+		if mediaRange == "text/html" {
+			s.renderPlantPage(w, p)
+			return
+		}
+		if mediaRange == "*/*" {
+			format := render.FormatJSON
+			if isTerminalClient(r) {
+				format = render.FormatASCII
+			}
+			if renderer, ok := s.formats().Get(format); ok {
+				s.writeRenderedPlant(w, r, p, renderer)
+				return
+			}
+		}
+		if _, renderer, ok := s.formats().ForMediaRange(mediaRange); ok {
+			s.writeRenderedPlant(w, r, p, renderer)
+			return
+		}
 	}
+	http.Error(w, "Not acceptable, supported formats: text/html, "+strings.Join(s.formats().Formats(), ", "),
+		http.StatusNotAcceptable)
 }
 
-// HandleGetPlantAscii returns a single plant by ID as ASCII text including an ASCII image
-// which varies depending on the plant's growth stage
-func (s *Server) HandleGetPlantAscii(w http.ResponseWriter, r *http.Request) {
+// HandleGetPlantFormat returns a single plant by ID rendered in the format named by the URL e.g. ascii or svg
+func (s *Server) HandleGetPlantFormat(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	format := chi.URLParam(r, "format")
 	p, err := s.store.GetPlant(id)
 	if err != nil {
 		http.Error(w, "Plant not found", http.StatusNotFound)
 		return
 	}
 
-	text := s.renderer.RenderText(p)
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	_, err = w.Write([]byte(text))
-	if err != nil {
+	renderer, ok := s.formats().Get(format)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unsupported format %q, supported formats: %s", format,
+			strings.Join(s.formats().Formats(), ", ")), http.StatusNotFound)
+		return
+	}
+	s.writeRenderedPlant(w, r, p, renderer)
+}
+
+// HandleGetPlantImage serves one of a plant's images from the repository's image store. The ETag is derived from
+// the image's contents, so clients can revalidate their cached copy once it expires.
+func (s *Server) HandleGetPlantImage(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	fileName := chi.URLParam(r, "file")
+
+	img, err := s.store.Images().GetImage(id, fileName)
+	switch {
+	case errors.Is(err, fs.ErrKeyNotFound), errors.Is(err, fs.ErrImageNotFound):
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	case err != nil:
 		s.InternalServerErrorResponse(w, err)
+		return
+	}
+
+	data := img.Data()
+	contentType := mime.TypeByExtension(path.Ext(fileName))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
 	}
+	digest := sha256.Sum256(data)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(digest[:16])+`"`)
+	w.Header().Set("Cache-Control", imageCacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// ServeContent answers conditional and range requests using the headers set above
+	http.ServeContent(w, r, fileName, time.Time{}, bytes.NewReader(data))
 }
 
-// HandlePlantDelete deletes a plant by ID
+// HandlePlantDelete deletes a plant by ID, along with its images
 // It returns 204 No Content if successful, or 404 Not Found if the plant doesn't exist
 func (s *Server) HandlePlantDelete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	err := s.store.DeletePlant(id)
+	if errors.Is(err, repository.ErrPlantNotFound) {
+		http.Error(w, "Plant not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		s.InternalServerErrorResponse(w, err)
+		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// HandleWaterPlant adds water; a single request waters a plant to 100%
+// HandleWaterPlant adds water to a plant. The request body optionally specifies the amount of water to add,
+// without an amount the plant is topped up to its variety's maximum water level.
 func (s *Server) HandleWaterPlant(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	p, err := s.store.GetPlant(id)
-	if err != nil {
+
+	var req WaterRequest
+	if r.ContentLength != 0 {
+		if err := s.decodeJsonRequest(r, &req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Id != "" && req.Id != id {
+		http.Error(w, "Plant id does not match the URL", http.StatusBadRequest)
+		return
+	}
+	if req.Amount < 0 {
+		http.Error(w, "Amount of water cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	// the plant is watered under the store's lock, so concurrent waterings and growth ticks aren't lost
+	var (
+		message string
+		before  plant.Snapshot
+	)
+	p, err := s.store.UpdatePlant(id, func(p *plant.Plant) error {
+		message = "plant is fully watered and cannot be watered anymore."
+		before = p.Snapshot()
+		var unitsAdded int
+		if req.Amount > 0 {
+			unitsAdded = p.AddWater(req.Amount)
+		} else {
+			unitsAdded = p.TopUp()
+		}
+		if unitsAdded > 0 {
+			message = fmt.Sprintf("added %d units of water to %s (%d%% watered).", unitsAdded, p.Id,
+				p.CurrentWaterLevel())
+		}
+		if p.Overwatered() {
+			message += fmt.Sprintf(" warning: %s is overwatered, its maximum water level is %d.", p.Id,
+				p.MaximumWaterLevel())
+		}
+		return nil
+	})
+	if errors.Is(err, repository.ErrPlantNotFound) {
 		http.Error(w, "Plant not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		s.InternalServerErrorResponse(w, err)
+		return
+	}
 
-	message := "plant is fully watered and cannot be watered anymore."
-	unitsAdded := p.AddWater()
-	if unitsAdded > 0 {
-		message = fmt.Sprintf("added %d units of water to %s (%d%% watered).", unitsAdded, p.Id, p.CurrentWaterLevel())
+	watered := plant.NewEvent(p.Id, plant.EventWatered, s.now(), message)
+	after := p.Snapshot()
+	watered.Before, watered.After = &before, &after
+	if err := s.store.RecordEvent(watered); err != nil {
+		s.InternalServerErrorResponse(w, err)
+		return
 	}
 
 	response := WaterResponse{
@@ -124,12 +243,17 @@ func (s *Server) HandlePlantDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.renderPlantPage(w, p)
+}
+
+// renderPlantPage renders the plant.html template for the plant
+func (s *Server) renderPlantPage(w http.ResponseWriter, p *plant.Plant) {
 	plantDTO := types.IntoPlantDTO(p)
 	if plantDTO.FriendlyName == "" {
 		plantDTO.FriendlyName = plantDTO.Id
 	}
 
-	err = s.templates["plant"].ExecuteTemplate(w, "layout.html", plantDTO)
+	err := s.templates["plant"].ExecuteTemplate(w, "layout.html", plantDTO)
 	if err != nil {
 		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
 		s.Logger.Error("template error", "error", err)
@@ -149,9 +273,13 @@ func (s *Server) HandleCreatePlant(w http.ResponseWriter, r *http.Request) {
 		dto.Id,
 		dto.FriendlyName,
 		dto.Variety,
-		time.Now(),
+		s.now(),
 	)
 
+	if errors.Is(err, repository.ErrPlantExists) {
+		http.Error(w, "Error creating plant: "+err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error creating plant: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -159,3 +287,136 @@ func (s *Server) HandleCreatePlant(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusCreated)
 }
+
+// HandlePutPlant idempotently applies a Plant custom resource, creating the plant if it doesn't exist. It is the
+// sync endpoint used by kube-botany-operator: the spec is applied, the status is returned, and the plant's
+// generation tracks the resource's metadata.generation so that the operator can set status.observedGeneration.
+// A resource older than the plant's generation, or one which changes the plant's variety, is rejected.
+func (s *Server) HandlePutPlant(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var resource types.PlantResource
+	if err := s.decodeJsonRequest(r, &resource); err != nil {
+		http.Error(w, "Error decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := controller.Apply(s.store, id, resource, s.now())
+	switch {
+	case errors.Is(err, controller.ErrInvalidResource):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, controller.ErrUnsupportedVariety):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, controller.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		s.InternalServerErrorResponse(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+	if err := s.encodeJsonResponse(w, r, status, types.IntoPlantResource(result.Plant)); err != nil {
+		s.InternalServerErrorResponse(w, err)
+	}
+}
+
+// HandleListPlantEvents returns a page of a plant's history, oldest first.
+// The query parameters limit and offset select the page, type (repeated or comma-separated) filters by event type.
+// A deleted plant's history remains available.
+func (s *Server) HandleListPlantEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	filter, err := eventFilterFromQuery(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, total, err := s.store.ListEvents(id, filter)
+	if err != nil {
+		s.InternalServerErrorResponse(w, err)
+		return
+	}
+
+	if total == 0 {
+		if _, err := s.store.GetPlant(id); err != nil {
+			http.Error(w, "Plant not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	response := EventsResponse{
+		Events: events,
+		Total:  total,
+		Offset: filter.Offset,
+		Limit:  filter.Limit,
+	}
+	err = s.encodeJsonResponse(w, r, http.StatusOK, response)
+	if err != nil {
+		s.InternalServerErrorResponse(w, err)
+	}
+}
+
+// HandleListPlantImageJobs returns the jobs which generate a plant's images, newest first, so that clients can show
+// whether today's image is pending, has failed and will be retried, or was dead-lettered. A deleted plant's jobs
+// remain available.
+func (s *Server) HandleListPlantImageJobs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	jobs, err := s.store.ListImageJobs(id)
+	if err != nil {
+		s.InternalServerErrorResponse(w, err)
+		return
+	}
+
+	if len(jobs) == 0 {
+		if _, err := s.store.GetPlant(id); err != nil {
+			http.Error(w, "Plant not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if err := s.encodeJsonResponse(w, r, http.StatusOK, ImageJobsResponse{Jobs: jobs}); err != nil {
+		s.InternalServerErrorResponse(w, err)
+	}
+}
+
+// eventFilterFromQuery builds an event filter from the request's query parameters.
+func eventFilterFromQuery(r *http.Request) (repository.EventFilter, error) {
+	query := r.URL.Query()
+	filter := repository.EventFilter{Limit: defaultEventsLimit}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxEventsLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxEventsLimit)
+		}
+		filter.Limit = n
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("offset must be a non-negative integer")
+		}
+		filter.Offset = n
+	}
+
+	for _, value := range query["type"] {
+		for _, name := range strings.Split(value, ",") {
+			eventType, err := plant.ParseEventType(strings.TrimSpace(name))
+			if err != nil {
+				return filter, err
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	return filter, nil
+}
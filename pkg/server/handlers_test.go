@@ -6,9 +6,14 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/plant"
 	"github.com/williamnoble/kube-botany/pkg/repository"
+	"github.com/williamnoble/kube-botany/pkg/types"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -69,6 +74,11 @@ func TestCreatePlant(t *testing.T) {
 	server.Routes().ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusCreated, rr.Code)
 	fmt.Println(rr.Body.String())
+
+	// an existing plant isn't replaced
+	rr = httptest.NewRecorder()
+	server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/plants", bytes.NewReader(js)))
+	assert.Equal(t, http.StatusConflict, rr.Code)
 }
 
 func TestDeletePlant(t *testing.T) {
@@ -76,8 +86,9 @@ func TestDeletePlant(t *testing.T) {
 	s := newInMemoryTestStore(t)
 	req := httptest.NewRequest(http.MethodGet, "/api/plants/TestPlant", nil)
 	// create a test plant
-	_, err := s.NewPlant("TestPlant", "TestBonsai", "bonsai", time.Now())
+	p, err := s.NewPlant("TestPlant", "TestBonsai", "bonsai", time.Now())
 	require.NoError(t, err)
+	require.NoError(t, s.Images().SaveImage(p.Id, p.Image(), []byte("fake-image")))
 	server := &Server{store: s}
 	// delete a test plant
 	req = httptest.NewRequest(http.MethodDelete, "/api/plants/TestPlant", nil)
@@ -85,5 +96,429 @@ func TestDeletePlant(t *testing.T) {
 	server.Routes().ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusNoContent, rr.Code)
+	// along with its images
+	assert.Equal(t, 0, s.Images().CountByKey("TestPlant"))
 	//assert.Contains(t, rr.Body.String(), "\"id\":\"TestPlant\"")
+
+	// a plant which doesn't exist isn't found
+	rr = httptest.NewRecorder()
+	server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/plants/TestPlant", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestWaterPlant(t *testing.T) {
+	t.Parallel()
+	s := newInMemoryTestStore(t)
+	_, err := s.NewPlant("TestPlant", "TestBonsai", "bonsai", time.Now())
+	require.NoError(t, err)
+	server := &Server{store: s}
+
+	water := func(body string) (int, WaterResponse) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/plants/water/TestPlant", bytes.NewReader([]byte(body)))
+		server.Routes().ServeHTTP(rr, req)
+		var response WaterResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		}
+		return rr.Code, response
+	}
+
+	code, response := water(`{"amount": 10}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 60, response.Plant.CurrentWaterLevel)
+	assert.Equal(t, 80, response.Plant.MaxWaterLevel)
+
+	// watering above the maximum is allowed, but the plant is overwatered
+	code, response = water(`{"id": "TestPlant", "amount": 30}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 90, response.Plant.CurrentWaterLevel)
+	assert.Equal(t, plant.ConditionOverwatered.String(), response.Plant.Condition)
+	assert.NotNil(t, response.Plant.OverwateredSince)
+	assert.Contains(t, response.Message, "overwatered")
+
+	code, _ = water(`{"amount": -10}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = water(`{"id": "OtherPlant", "amount": 10}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestWaterPlantDuringGrowth(t *testing.T) {
+	t.Parallel()
+	clk, err := clock.NewSimulated(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), 1)
+	require.NoError(t, err)
+	s, err := repository.New(repository.Options{VarietiesPath: "../plant/varieties.json", Clock: clk})
+	require.NoError(t, err)
+	_, err = s.NewPlant("TestPlant", "TestBonsai", "bonsai", clk.Now())
+	require.NoError(t, err)
+	server := &Server{store: s, clock: clk}
+
+	// waterings race with growth ticks and with each other, none of them may be lost
+	const waterings = 20
+	var wg sync.WaitGroup
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				assert.NoError(t, s.UpdateAllPlants())
+			}
+		}
+	}()
+	for range waterings {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/plants/water/TestPlant",
+				strings.NewReader(`{"amount": 1}`)))
+			assert.Equal(t, http.StatusOK, rr.Code)
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-stopped
+
+	// a watering adds a whole unit, whereas a few seconds of simulated time consume a tiny fraction of one
+	clk.Freeze()
+	p, err := s.GetPlant("TestPlant")
+	require.NoError(t, err)
+	assert.Equal(t, 50+waterings, p.CurrentWaterLevel())
+	_, total, err := s.ListEvents("TestPlant", repository.EventFilter{Types: []plant.EventType{plant.EventWatered}})
+	require.NoError(t, err)
+	assert.Equal(t, waterings, total)
+}
+
+func TestPutPlant(t *testing.T) {
+	t.Parallel()
+	s := newInMemoryTestStore(t)
+	server := &Server{store: s}
+
+	put := func(resource string) (int, types.PlantResource) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/plants/TestPlant", bytes.NewReader([]byte(resource)))
+		server.Routes().ServeHTTP(rr, req)
+		var response types.PlantResource
+		if rr.Code == http.StatusOK || rr.Code == http.StatusCreated {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		}
+		return rr.Code, response
+	}
+
+	// the first PUT creates the plant
+	resource := `{"kind": "Plant", "metadata": {"name": "TestPlant", "generation": 1},
+		"spec": {"variety": "bonsai", "friendlyName": "TestBonsai", "motif": "gopher"}}`
+	code, response := put(resource)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, types.PlantKind, response.Kind)
+	assert.Equal(t, "gopher", response.Spec.Motif)
+	assert.Equal(t, int64(1), response.Status.ObservedGeneration)
+	assert.Equal(t, plant.Seeding.String(), response.Status.GrowthStage)
+	assert.Equal(t, 50, response.Status.CurrentWaterLevel)
+	assert.NotEmpty(t, response.Status.ImageURL)
+
+	// repeating it is a no-op
+	code, response = put(resource)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(1), response.Status.ObservedGeneration)
+
+	// a new generation updates the spec
+	code, response = put(`{"metadata": {"generation": 2},
+		"spec": {"variety": "bonsai", "friendlyName": "RenamedBonsai"}}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(2), response.Status.ObservedGeneration)
+	assert.Equal(t, "RenamedBonsai", response.Spec.FriendlyName)
+	assert.Empty(t, response.Spec.Motif)
+	p, err := s.GetPlant("TestPlant")
+	require.NoError(t, err)
+	assert.Equal(t, "RenamedBonsai", p.FriendlyName)
+
+	updates, _, err := s.ListEvents("TestPlant", repository.EventFilter{Types: []plant.EventType{plant.EventUpdated}})
+	require.NoError(t, err)
+	assert.Len(t, updates, 1)
+
+	// stale generations, variety changes and invalid resources are rejected
+	code, _ = put(resource)
+	assert.Equal(t, http.StatusConflict, code)
+	code, _ = put(`{"metadata": {"generation": 3}, "spec": {"variety": "sunflower"}}`)
+	assert.Equal(t, http.StatusConflict, code)
+	code, _ = put(`{"metadata": {"name": "OtherPlant"}, "spec": {"variety": "bonsai"}}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = put(`{"spec": {}}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestPutPlantUnsupportedVariety(t *testing.T) {
+	t.Parallel()
+	server := &Server{store: newInMemoryTestStore(t)}
+
+	rr := httptest.NewRecorder()
+	body := bytes.NewReader([]byte(`{"spec": {"variety": "fern"}}`))
+	server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/api/plants/TestPlant", body))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
+func TestListPlantEvents(t *testing.T) {
+	t.Parallel()
+	s := newInMemoryTestStore(t)
+	_, err := s.NewPlant("TestPlant", "TestBonsai", "bonsai", time.Now())
+	require.NoError(t, err)
+	server := &Server{store: s}
+
+	// water the plant twice, then delete it
+	for range 2 {
+		rr := httptest.NewRecorder()
+		server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/plants/water/TestPlant", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+	rr := httptest.NewRecorder()
+	server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/plants/TestPlant", nil))
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	listEvents := func(query string) (int, EventsResponse) {
+		rr := httptest.NewRecorder()
+		server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/plants/TestPlant/events"+query, nil))
+		var response EventsResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		}
+		return rr.Code, response
+	}
+
+	// the history outlives the plant
+	code, response := listEvents("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 4, response.Total)
+	require.Len(t, response.Events, 4)
+	assert.Equal(t, plant.EventCreated, response.Events[0].Type)
+	assert.Equal(t, plant.EventDeleted, response.Events[3].Type)
+	assert.Nil(t, response.Events[0].Before)
+	assert.Nil(t, response.Events[3].After)
+
+	// the first watering tops the plant up from 50% to the bonsai's maximum of 80%
+	watered := response.Events[1]
+	assert.Equal(t, plant.EventWatered, watered.Type)
+	assert.Equal(t, 50, watered.Before.CurrentWaterLevel)
+	assert.Equal(t, 80, watered.After.CurrentWaterLevel)
+
+	// filter and paginate
+	code, response = listEvents("?type=watered&limit=1&offset=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, response.Total)
+	require.Len(t, response.Events, 1)
+	assert.Equal(t, plant.EventWatered, response.Events[0].Type)
+	assert.Greater(t, response.Events[0].Id, watered.Id)
+
+	code, response = listEvents("?type=created,deleted")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, response.Total)
+
+	code, _ = listEvents("?type=pruned")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = listEvents("?limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	rr = httptest.NewRecorder()
+	server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/plants/MissingPlant/events", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestListPlantImageJobs(t *testing.T) {
+	t.Parallel()
+	s := newInMemoryTestStore(t)
+	now := time.Now()
+	_, err := s.NewPlant("TestPlant", "TestBonsai", "bonsai", now)
+	require.NoError(t, err)
+	_, err = s.NewPlant("OtherPlant", "OtherBonsai", "bonsai", now)
+	require.NoError(t, err)
+	for _, image := range []string{"2025-01-01-TestPlant.png", "2025-01-02-TestPlant.png"} {
+		job := plant.NewImageJob("TestPlant", image, 5, now)
+		require.NoError(t, s.SaveImageJob(&job))
+	}
+	server := &Server{store: s}
+
+	listJobs := func(id string) (int, ImageJobsResponse) {
+		rr := httptest.NewRecorder()
+		server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/plants/"+id+"/images/jobs", nil))
+		var response ImageJobsResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		}
+		return rr.Code, response
+	}
+
+	code, response := listJobs("TestPlant")
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, response.Jobs, 2)
+	assert.Equal(t, "2025-01-02-TestPlant.png", response.Jobs[0].Image)
+	assert.Equal(t, plant.ImageJobPending, response.Jobs[0].State)
+
+	code, response = listJobs("OtherPlant")
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, response.Jobs)
+	assert.Empty(t, response.Jobs)
+
+	code, _ = listJobs("MissingPlant")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestGetPlantImage(t *testing.T) {
+	t.Parallel()
+	s := newInMemoryTestStore(t)
+	p, err := s.NewPlant("TestPlant", "TestBonsai", "bonsai", time.Now())
+	require.NoError(t, err)
+	image := []byte("\x89PNG\r\n\x1a\nfake-image")
+	require.NoError(t, s.Images().SaveImage(p.Id, p.Image(), image))
+	server := &Server{store: s}
+
+	get := func(url, etag string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		server.Routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	// the plant's DTO links to the image
+	url := types.IntoPlantDTO(p).Image
+	assert.Equal(t, "/api/plants/TestPlant/images/"+p.Image(), url)
+
+	rr := get(url, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, image, rr.Body.Bytes())
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Equal(t, imageCacheControl, rr.Header().Get("Cache-Control"))
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// a client with an up-to-date copy is told it hasn't changed
+	rr = get(url, etag)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.Bytes())
+
+	rr = get("/api/plants/TestPlant/images/missing.png", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = get("/api/plants/OtherPlant/images/"+p.Image(), "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetPlantSVG(t *testing.T) {
+	t.Parallel()
+	s := newInMemoryTestStore(t)
+	_, err := s.NewPlant("TestPlant", "TestBonsai", "bonsai", time.Now())
+	require.NoError(t, err)
+	server := &Server{store: s}
+
+	rr := httptest.NewRecorder()
+	server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/plants/TestPlant/format/svg", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(rr.Body.String(), "<svg "))
+	assert.Contains(t, rr.Body.String(), "TestBonsai")
+
+	rr = httptest.NewRecorder()
+	server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/plants/MissingPlant/format/svg", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetPlantFormat(t *testing.T) {
+	t.Parallel()
+	s := newInMemoryTestStore(t)
+	_, err := s.NewPlant("TestPlant", "TestBonsai", "bonsai", time.Now())
+	require.NoError(t, err)
+	server := &Server{store: s}
+
+	get := func(format string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/plants/TestPlant/format/"+format, nil))
+		return rr
+	}
+
+	tests := map[string]string{
+		"ascii":    "text/plain; charset=utf-8",
+		"json":     "application/json",
+		"markdown": "text/markdown; charset=utf-8",
+		"png":      "image/png",
+		"svg":      "image/svg+xml",
+	}
+	for format, contentType := range tests {
+		rr := get(format)
+		assert.Equal(t, http.StatusOK, rr.Code, format)
+		assert.Equal(t, contentType, rr.Header().Get("Content-Type"), format)
+		assert.NotEmpty(t, rr.Body.Bytes(), format)
+	}
+
+	rr := get("gif")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "ascii, json, markdown, png, svg")
+
+	// text is coloured for terminals, or on request
+	colored := func(url, userAgent string) bool {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("User-Agent", userAgent)
+		server.Routes().ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		return strings.Contains(rr.Body.String(), "\x1b[")
+	}
+	assert.False(t, colored("/api/plants/TestPlant/format/ascii", "Mozilla/5.0"))
+	assert.True(t, colored("/api/plants/TestPlant/format/ascii", "curl/8.7.1"))
+	assert.True(t, colored("/api/plants/TestPlant/format/ascii?color=true", "Mozilla/5.0"))
+	assert.False(t, colored("/api/plants/TestPlant/format/ascii?color=false", "curl/8.7.1"))
+	assert.False(t, colored("/api/plants/TestPlant/format/markdown?color=true", "curl/8.7.1"))
+}
+
+func TestGetPlantNegotiation(t *testing.T) {
+	t.Parallel()
+	s := newInMemoryTestStore(t)
+	_, err := s.NewPlant("TestPlant", "TestBonsai", "bonsai", time.Now())
+	require.NoError(t, err)
+	server, err := NewServer(s)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		accept      string
+		userAgent   string
+		code        int
+		contentType string
+	}{
+		{name: "no accept header", code: http.StatusOK, contentType: "application/json"},
+		{name: "any", accept: "*/*", userAgent: "Go-http-client/1.1", code: http.StatusOK, contentType: "application/json"},
+		{name: "curl", accept: "*/*", userAgent: "curl/8.7.1", code: http.StatusOK, contentType: "text/plain; charset=utf-8"},
+		{name: "curl asking for json", accept: "application/json", userAgent: "curl/8.7.1", code: http.StatusOK, contentType: "application/json"},
+		{
+			name:        "browser",
+			accept:      "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+			userAgent:   "Mozilla/5.0",
+			code:        http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+		},
+		{name: "quality", accept: "text/plain;q=0.5, image/svg+xml", code: http.StatusOK, contentType: "image/svg+xml"},
+		{name: "wildcard", accept: "text/*", code: http.StatusOK, contentType: "text/plain; charset=utf-8"},
+		{name: "not acceptable", accept: "application/xml, image/svg+xml;q=0", code: http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "/api/plants/TestPlant", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			req.Header.Set("User-Agent", tt.userAgent)
+			rr := httptest.NewRecorder()
+			server.Routes().ServeHTTP(rr, req)
+			assert.Equal(t, tt.code, rr.Code)
+			if tt.code == http.StatusOK {
+				assert.Equal(t, tt.contentType, rr.Header().Get("Content-Type"))
+				assert.Contains(t, rr.Body.String(), "TestBonsai")
+			}
+			assert.Equal(t, "Accept, User-Agent", rr.Header().Get("Vary"))
+		})
+	}
 }
@@ -3,8 +3,19 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/render"
 	"github.com/williamnoble/kube-botany/pkg/types"
 	"net/http"
+	"strconv"
+)
+
+const (
+	defaultEventsLimit = 50  // events returned when no limit is requested
+	maxEventsLimit     = 500 // the largest page of events which may be requested
+
+	// images are named by date and rarely regenerated, clients revalidate them with the ETag after a day
+	imageCacheControl = "public, max-age=86400"
 )
 
 // Encode serializes a value to JSON and writes it to the HTTP response.
@@ -24,6 +35,29 @@ func (s *Server) encodeJsonResponse(w http.ResponseWriter, r *http.Request, stat
 	return nil
 }
 
+// writeRenderedPlant renders the plant and writes it to the HTTP response with the renderer's content type. Text is
+// coloured for terminals when the request wants colour and the renderer supports it.
+func (s *Server) writeRenderedPlant(w http.ResponseWriter, r *http.Request, p *plant.Plant, renderer render.Renderer) {
+	var (
+		data []byte
+		err  error
+	)
+	if colorRenderer, ok := renderer.(render.ColorRenderer); ok && wantsColor(r) {
+		data, err = colorRenderer.RenderColor(p)
+	} else {
+		data, err = renderer.Render(p)
+	}
+	if err != nil {
+		s.InternalServerErrorResponse(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", renderer.ContentType())
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if _, err := w.Write(data); err != nil {
+		s.Logger.Error("failed to write rendered plant", "plant", p.Id, "error", err)
+	}
+}
+
 // Decode deserializes a JSON request body into a value
 // It logs an error if decoding fails
 func (s *Server) decodeJsonRequest(r *http.Request, v interface{}) error {
@@ -56,7 +90,21 @@ type WaterResponse struct {
 	Plant   types.PlantDTO `json:"plant"`   // Updated plant information
 }
 
-// WaterRequest contains the Id identifier of the plant being watered
+// WaterRequest is the optional body of a water request
 type WaterRequest struct {
-	Id string `json:"id"` // ID of the plant to water
+	Id     string `json:"id,omitempty"`     // ID of the plant to water, must match the URL when set
+	Amount int    `json:"amount,omitempty"` // Units of water to add, the plant is topped up to its maximum when zero
+}
+
+// EventsResponse is the response returned by the events endpoint
+type EventsResponse struct {
+	Events []plant.Event `json:"events"` // Events in the requested page, oldest first
+	Total  int           `json:"total"`  // Total number of events matching the filter
+	Offset int           `json:"offset"` // Offset of the first event in the page
+	Limit  int           `json:"limit"`  // Maximum number of events in the page
+}
+
+// ImageJobsResponse is the response returned by the image jobs endpoint
+type ImageJobsResponse struct {
+	Jobs []plant.ImageJob `json:"jobs"` // The plant's image generation jobs, newest first
 }
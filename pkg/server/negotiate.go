@@ -0,0 +1,84 @@
+package server
+
+import (
+	"cmp"
+	"github.com/williamnoble/kube-botany/pkg/render"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultRenderers serves the formats of a Server created without NewServer, e.g. in tests.
+var defaultRenderers = render.DefaultRegistry()
+
+// formats returns the renderers of the formats in which plants are served.
+func (s *Server) formats() *render.Registry {
+	if s.renderers == nil {
+		return defaultRenderers
+	}
+	return s.renderers
+}
+
+// terminalClients are the User-Agent prefixes of command line HTTP clients, which are sent text rather than JSON
+// when they accept any media type.
+var terminalClients = []string{"curl/", "Wget/", "HTTPie/", "xh/"}
+
+// isTerminalClient returns true when the request was made by a command line HTTP client, such as curl.
+func isTerminalClient(r *http.Request) bool {
+	ua := r.Header.Get("User-Agent")
+	return slices.ContainsFunc(terminalClients, func(prefix string) bool {
+		return strings.HasPrefix(ua, prefix)
+	})
+}
+
+// wantsColor returns true when text should be coloured with ANSI escape codes: when the color query parameter is
+// true, or when it is absent and the request was made by a command line client.
+func wantsColor(r *http.Request) bool {
+	if value := r.URL.Query().Get("color"); value != "" {
+		color, err := strconv.ParseBool(value)
+		return err == nil && color
+	}
+	return isTerminalClient(r)
+}
+
+// acceptedMediaRanges returns the media ranges of the request's Accept header, e.g. "text/html" or "image/*",
+// most preferred first. Ranges with a quality of zero are not acceptable and are left out. Ranges of equal
+// quality keep their order in the header, "*/*" is returned when the request has no Accept header.
+func acceptedMediaRanges(r *http.Request) []string {
+	type accepted struct {
+		mediaRange string
+		quality    float64
+	}
+	if len(r.Header.Values("Accept")) == 0 {
+		return []string{"*/*"}
+	}
+
+	var ranges []accepted
+	for _, header := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(header, ",") {
+			mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			quality := 1.0
+			if q, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(q, 64); err != nil {
+					continue
+				}
+			}
+			if quality > 0 {
+				ranges = append(ranges, accepted{mediaRange: mediaRange, quality: quality})
+			}
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b accepted) int {
+		return cmp.Compare(b.quality, a.quality)
+	})
+	mediaRanges := make([]string, 0, len(ranges))
+	for _, accepted := range ranges {
+		mediaRanges = append(mediaRanges, accepted.mediaRange)
+	}
+	return mediaRanges
+}
@@ -17,14 +17,27 @@ func (s *Server) Routes() http.Handler {
 	r.Route("/api/plants", func(r chi.Router) {
 		r.Get("/", s.HandleListPlants)         // GET /api/plants - List all plants
 		r.Get("/{id}", s.HandleGetPlant)       // GET /api/plants/{id} - Get a specific plant
+		r.Put("/{id}", s.HandlePutPlant)       // PUT /api/plants/{id} - Create or update a plant from a Plant resource
 		r.Delete("/{id}", s.HandlePlantDelete) // DELETE /api/plants/{id} - Delete a plant
 		r.Post("/water/{id}", s.HandleWaterPlant)
 		r.Post("/", s.HandleCreatePlant) // POST /api/plants - Create a plant
 
-		r.Get("/{id}/format/ascii", s.HandleGetPlantAscii)
+		r.Get("/{id}/format/{format}", s.HandleGetPlantFormat)  // GET /api/plants/{id}/format/{format} - Render a plant e.g. as svg
+		r.Get("/{id}/events", s.HandleListPlantEvents)          // GET /api/plants/{id}/events - List a plant's history
+		r.Get("/{id}/images/jobs", s.HandleListPlantImageJobs)  // GET /api/plants/{id}/images/jobs - List a plant's image generation jobs
+		r.Get("/{id}/images/{file}", s.HandleGetPlantImage)     // GET /api/plants/{id}/images/{file} - Get a plant's image
+		r.Get("/{id}/timelapse.gif", s.HandleGetPlantTimelapse) // GET /api/plants/{id}/timelapse.gif - Animate a plant's images
 
 	})
 
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Get("/clock", s.HandleGetClock)     // GET /api/admin/clock - Get the time of the simulation
+		r.Post("/clock", s.HandleUpdateClock) // POST /api/admin/clock - Advance, freeze or accelerate simulated time
+
+		r.Get("/jobs", s.HandleListJobs)           // GET /api/admin/jobs - List background jobs and their last runs
+		r.Post("/jobs/{name}/run", s.HandleRunJob) // POST /api/admin/jobs/{name}/run - Run a background job now
+	})
+
 	// handle Web
 	r.HandleFunc("GET /", s.HandleRenderHomePage)  // GET / - Render home page with all plants
 	r.HandleFunc("GET /{id}", s.HandlePlantDetail) // GET /{id} - Render plant detail page
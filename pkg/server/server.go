@@ -3,8 +3,12 @@ package server
 import (
 	"context"
 	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/gen"
 	"github.com/williamnoble/kube-botany/pkg/render"
 	"github.com/williamnoble/kube-botany/pkg/repository"
+	"github.com/williamnoble/kube-botany/pkg/retention"
+	"github.com/williamnoble/kube-botany/pkg/scheduler"
 	"html/template"
 	"log/slog"
 	"net/http"
@@ -21,15 +25,85 @@ type Server struct {
 	Logger    *slog.Logger // Logger for server logs
 	startTime time.Time    // Time when the server started
 
-	store    repository.PlantRepository // Repository for plants
-	renderer *render.ASCIIRenderer      // Renderer for ASCII art
+	store     repository.PlantRepository // Repository for plants
+	renderers *render.Registry           // Renderers of the formats in which plants are served
+
+	timelapses timelapseCache // Recently encoded timelapses
+
+	clock clock.Clock // Source of the time of the simulation, the system clock unless simulated
+
+	imageProvider gen.ImageProvider // Provider used to generate plant images, procedurally drawn if nil
+	imageOptions  gen.Options       // Timeout and retries of image generation
+
+	jobs           *scheduler.Scheduler // Scheduler of the background jobs
+	imageSchedule  scheduler.Schedule   // Schedule of image generation, every five minutes if nil
+	growthInterval time.Duration        // Interval between updates of every plant's state, disabled if zero
+
+	retention         *retention.Policies // Policies applied by the image retention task, disabled if nil
+	retentionInterval time.Duration       // Interval between image collections
+
+	manifestDir          string        // Directory of Plant manifests reconciled by the controller, disabled if empty
+	manifestSyncInterval time.Duration // Interval between reconciles of the manifest directory
 
 	httpServer *http.Server
 }
 
+// Option configures optional Server behaviour
+type Option func(*Server)
+
+// WithImageProvider sets the provider used by the background image task, and how it is called
+func WithImageProvider(provider gen.ImageProvider, opts gen.Options) Option {
+	return func(s *Server) {
+		s.imageProvider = provider
+		s.imageOptions = opts
+	}
+}
+
+// WithClock sets the source of the time of the simulation, which should be the clock of the store. A simulated
+// clock can be advanced, frozen and accelerated with the admin clock endpoint.
+func WithClock(c clock.Clock) Option {
+	return func(s *Server) {
+		s.clock = c
+	}
+}
+
+// WithImageSchedule sets the schedule on which the background image task runs, e.g. a cron expression parsed by
+// scheduler.Parse
+func WithImageSchedule(schedule scheduler.Schedule) Option {
+	return func(s *Server) {
+		s.imageSchedule = schedule
+	}
+}
+
+// WithGrowthInterval sets the interval at which the state of every plant is brought up to date while background
+// tasks are running, so that listed plants are current even when nothing reads them individually
+func WithGrowthInterval(interval time.Duration) Option {
+	return func(s *Server) {
+		s.growthInterval = interval
+	}
+}
+
+// WithRetention enables the image retention task, which deletes the images that policies no longer keep at the
+// given interval while background tasks are running
+func WithRetention(policies retention.Policies, interval time.Duration) Option {
+	return func(s *Server) {
+		s.retention = &policies
+		s.retentionInterval = interval
+	}
+}
+
+// WithManifestDir enables the embedded controller, which reconciles the store with the Plant manifests in dir
+// at the given interval while background tasks are running
+func WithManifestDir(dir string, interval time.Duration) Option {
+	return func(s *Server) {
+		s.manifestDir = dir
+		s.manifestSyncInterval = interval
+	}
+}
+
 // NewServer creates a new Server instance with the given plants
 // It initialises the logger, renderer, templates, and other server components
-func NewServer(inMemoryStore repository.PlantRepository) (*Server, error) {
+func NewServer(store repository.PlantRepository, opts ...Option) (*Server, error) {
 	logHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	})
@@ -40,8 +114,18 @@ func NewServer(inMemoryStore repository.PlantRepository) (*Server, error) {
 		startTime: time.Now(),
 		templates: make(map[string]*template.Template),
 		staticDir: "pkg/static",
-		store:     inMemoryStore,
-		renderer:  render.NewASCIIRenderer(),
+		store:     store,
+		renderers: render.DefaultRegistry(),
+		clock:     clock.System{},
+
+		jobs:           scheduler.New(logger.With("component", "scheduler")),
+		growthInterval: defaultGrowthInterval,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.imageProvider == nil {
+		s.imageProvider = gen.NewProceduralProvider(0)
 	}
 	s.ParseTemplates()
 
@@ -108,7 +108,7 @@ func TestNewServer(t *testing.T) {
 	assert.NotNil(t, svr)
 	assert.NotNil(t, svr.Logger)
 	assert.NotNil(t, svr.store)
-	assert.NotNil(t, svr.renderer)
+	assert.NotNil(t, svr.renderers)
 	assert.NotEmpty(t, svr.templates)
 	assert.Equal(t, "pkg/static", svr.staticDir)
 }
@@ -2,37 +2,130 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/controller"
 	"github.com/williamnoble/kube-botany/pkg/gen"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/retention"
+	"github.com/williamnoble/kube-botany/pkg/scheduler"
 	"time"
 )
 
-// BackgroundTasks sets up background tasks:
+const (
+	// defaultGrowthInterval is the interval between updates of every plant's state when none is configured
+	defaultGrowthInterval = time.Minute
+	// defaultImageInterval is the interval between image generation runs when no schedule is configured
+	defaultImageInterval = 5 * time.Minute
+
+	// images are generated by external APIs, jitter keeps replicas from calling them at the same moment and the
+	// timeout bounds a run which would otherwise be retried plant by plant
+	imageJobJitter  = 30 * time.Second
+	imageJobTimeout = 30 * time.Minute
+
+	growthJobTimeout    = time.Minute
+	retentionJobTimeout = 10 * time.Minute
+	manifestJobTimeout  = time.Minute
+)
+
+// Names of the background jobs, as listed by the admin jobs endpoint
+const (
+	jobImages    = "images"
+	jobGrowth    = "growth"
+	jobRetention = "retention"
+	jobManifests = "manifests"
+)
+
+// BackgroundTasks schedules the background jobs, and runs them until the context is cancelled:
+//   - images generates each plant's daily image
+//   - growth brings the state of every plant up to date
+//   - retention deletes the images which retention policies no longer keep, when enabled
+//   - manifests reconciles the store with the manifest directory, when enabled
 func (s *Server) BackgroundTasks(ctx context.Context) {
 	s.Logger.With("component", "tasks").Info("starting background tasks")
-	imgSvc := gen.NewMockImageGenerationService(s.staticDir, s.Logger)
-
-	// Run the task once on startup
-	if err := s.runImageTask(imgSvc); err != nil {
-		s.Logger.With("component", "tasks").Error("error processing initial task", "error", err)
-	}
-
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			if err := s.runImageTask(imgSvc); err != nil {
-				s.Logger.With("component", "tasks").Error("error processing scheduled task", "error", err)
-			}
-		case <-ctx.Done():
-			s.Logger.With("component", "tasks").Info("stopping background tasks")
-			return
+	if err := s.scheduleJobs(); err != nil {
+		s.Logger.With("component", "tasks").Error("failed to schedule background tasks", "error", err)
+		return
+	}
+	s.jobs.Run(ctx)
+	s.Logger.With("component", "tasks").Info("stopping background tasks")
+}
+
+// scheduleJobs adds the enabled background jobs to the server's scheduler
+func (s *Server) scheduleJobs() error {
+	imageOptions := s.imageOptions
+	if imageOptions.Clock == nil {
+		imageOptions.Clock = s.clock
+	}
+	if imageOptions.Jobs == nil {
+		imageOptions.Jobs = s.store
+	}
+	imgSvc, err := gen.NewImageGenerationService(s.imageProvider, s.store.Images(), s.Logger, imageOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create image generation service: %w", err)
+	}
+
+	imageSchedule := s.imageSchedule
+	if imageSchedule == nil {
+		imageSchedule = scheduler.Every(defaultImageInterval)
+	}
+	jobs := []scheduler.Job{{
+		Name:      jobImages,
+		Schedule:  imageSchedule,
+		Jitter:    imageJobJitter,
+		Timeout:   imageJobTimeout,
+		Immediate: true,
+		Run: func(ctx context.Context) error {
+			return s.runImageTask(ctx, imgSvc)
+		},
+	}}
+
+	if s.growthInterval > 0 {
+		jobs = append(jobs, scheduler.Job{
+			Name:     jobGrowth,
+			Schedule: scheduler.Every(s.growthInterval),
+			Timeout:  growthJobTimeout,
+			Run: func(ctx context.Context) error {
+				return s.store.UpdateAllPlants()
+			},
+		})
+	}
+
+	if s.retention != nil {
+		grace := max(s.imageOptions.Timeout, retention.DefaultRecordGrace)
+		collector := retention.NewCollector(s.store, *s.retention, grace, s.Logger.With("component", "retention"))
+		jobs = append(jobs, scheduler.Job{
+			Name:      jobRetention,
+			Schedule:  scheduler.Every(s.retentionInterval),
+			Timeout:   retentionJobTimeout,
+			Immediate: true,
+			Run:       collector.Run,
+		})
+	}
+
+	if s.manifestDir != "" {
+		ctrl := controller.New(s.store, s.manifestDir, s.clock, s.Logger.With("component", "controller"))
+		jobs = append(jobs, scheduler.Job{
+			Name:      jobManifests,
+			Schedule:  scheduler.Every(s.manifestSyncInterval),
+			Timeout:   manifestJobTimeout,
+			Immediate: true,
+			Run:       ctrl.Run,
+		})
+	}
+
+	for _, job := range jobs {
+		if err := s.jobs.Add(job); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
-// runImageTask runs the image generation task with the current list of plants
-func (s *Server) runImageTask(imgSvc *gen.ImageGenerationService) error {
-	plants := s.store.ListAllPlants()
-	return imgSvc.ImageTask(plants)
+// runImageTask runs the image generation task with a snapshot of the current plants
+func (s *Server) runImageTask(ctx context.Context, imgSvc *gen.ImageGenerationService) error {
+	plants := make(map[string]plant.Plant)
+	for id, p := range s.store.ListAllPlants() {
+		plants[id] = *p
+	}
+	return imgSvc.ImageTask(ctx, plants)
 }
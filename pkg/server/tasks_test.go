@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/clock"
+	"github.com/williamnoble/kube-botany/pkg/gen"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"github.com/williamnoble/kube-botany/pkg/scheduler"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// growthStore signals each update of every plant
+type growthStore struct {
+	repository.PlantRepository
+	updated chan struct{}
+}
+
+func (s *growthStore) UpdateAllPlants() error {
+	err := s.PlantRepository.UpdateAllPlants()
+	select {
+	case s.updated <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+func TestBackgroundTasks(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFrozen(start)
+	s, err := repository.New(repository.Options{VarietiesPath: "../plant/varieties.json", Clock: clk})
+	require.NoError(t, err)
+	_, err = s.NewPlant("TestPlant", "TestBonsai", "bonsai", clk.Now())
+	require.NoError(t, err)
+	store := &growthStore{PlantRepository: s, updated: make(chan struct{}, 1)}
+	server := &Server{
+		store:          store,
+		clock:          clk,
+		Logger:         slog.New(slog.DiscardHandler),
+		jobs:           scheduler.New(slog.New(slog.DiscardHandler)),
+		imageProvider:  gen.NewProceduralProvider(64),
+		imageSchedule:  scheduler.Every(time.Hour),
+		growthInterval: 10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.BackgroundTasks(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// the plant grows without being read
+	require.NoError(t, clk.Advance(48*time.Hour))
+	select {
+	case <-store.updated:
+	case <-time.After(time.Second):
+		t.Fatal("plants were not updated")
+	}
+	assert.True(t, s.ListAllPlants()["TestPlant"].LastUpdated.Equal(start.Add(48*time.Hour)))
+
+	// the image job runs on startup, then on its schedule
+	var response JobsResponse
+	assert.Eventually(t, func() bool {
+		rr := httptest.NewRecorder()
+		server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/admin/jobs", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		return len(response.Jobs) == 2 && response.Jobs[1].Runs == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, jobGrowth, response.Jobs[0].Name)
+	assert.Equal(t, jobImages, response.Jobs[1].Name)
+	assert.Equal(t, "@every 1h0m0s", response.Jobs[1].Schedule)
+	assert.Empty(t, response.Jobs[1].LastError)
+	assert.True(t, s.ImageExists("TestPlant", s.ListAllPlants()["TestPlant"].ImageOn(clk.Now())))
+
+	run := func(name string) int {
+		rr := httptest.NewRecorder()
+		server.Routes().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/admin/jobs/"+name+"/run", nil))
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusAccepted, run(jobImages))
+	assert.Equal(t, http.StatusNotFound, run("weeding"))
+}
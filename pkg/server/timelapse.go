@@ -0,0 +1,210 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	chi "github.com/go-chi/chi/v5"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/gen"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"github.com/williamnoble/kube-botany/pkg/render"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimelapseDelay = 500 // milliseconds each frame is shown
+	defaultTimelapseSize  = 256 // pixels along the longest side of each frame
+	maxTimelapseSize      = 1024
+	maxTimelapseFrames    = 120 // longer timelapses are sampled evenly
+	timelapseCacheEntries = 16  // number of encoded timelapses kept in memory
+)
+
+// timelapseQuery is the date range and presentation of a timelapse, from the request's query parameters.
+type timelapseQuery struct {
+	from, to time.Time // inclusive, unbounded when zero
+	opts     render.TimelapseOptions
+}
+
+// HandleGetPlantTimelapse returns an animated GIF of a plant's daily images in date order. The query parameters
+// from and to (YYYY-MM-DD, inclusive) select the days, delay is how long each frame is shown in milliseconds and
+// size is the length in pixels of each frame's longest side. Encoded timelapses are cached, keyed by the query and
+// the images which went into them, so a timelapse is only encoded again once the plant has a new image. The key is
+// built from the images' metadata, and only the sampled images are fetched, when the timelapse isn't cached.
+func (s *Server) HandleGetPlantTimelapse(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := s.store.GetPlant(id); err != nil {
+		http.Error(w, "Plant not found", http.StatusNotFound)
+		return
+	}
+
+	query, err := timelapseQueryFromRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := s.store.Images().StatImages(id)
+	if err != nil && !errors.Is(err, fs.ErrKeyNotFound) {
+		s.InternalServerErrorResponse(w, err)
+		return
+	}
+	var infos []fs.ImageInfo
+	for _, info := range stored {
+		date, ok := plant.ImageDate(info.FileName)
+		if !ok || gen.IsRecord(info.FileName) {
+			continue
+		}
+		if (!query.from.IsZero() && date.Before(query.from)) || (!query.to.IsZero() && date.After(query.to)) {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	if len(infos) == 0 {
+		http.Error(w, "No images in range", http.StatusNotFound)
+		return
+	}
+	slices.SortFunc(infos, func(a, b fs.ImageInfo) int {
+		return strings.Compare(a.FileName, b.FileName)
+	})
+
+	etag := timelapseETag(id, query, infos)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", imageCacheControl)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, ok := s.timelapses.get(etag)
+	if !ok {
+		images, err := s.timelapseImages(id, render.SampleFrames(infos, query.opts.MaxFrames))
+		if err != nil {
+			s.InternalServerErrorResponse(w, err)
+			return
+		}
+		data, err = render.Timelapse(images, query.opts)
+		if errors.Is(err, render.ErrNoFrames) {
+			http.Error(w, "No images in range", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.InternalServerErrorResponse(w, err)
+			return
+		}
+		s.timelapses.add(etag, data)
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if _, err := w.Write(data); err != nil {
+		s.Logger.Error("failed to write timelapse", "plant", id, "error", err)
+	}
+}
+
+// timelapseQueryFromRequest parses and validates the timelapse's query parameters.
+func timelapseQueryFromRequest(r *http.Request) (timelapseQuery, error) {
+	values := r.URL.Query()
+	query := timelapseQuery{opts: render.TimelapseOptions{
+		Delay:     defaultTimelapseDelay * time.Millisecond,
+		Size:      defaultTimelapseSize,
+		MaxFrames: maxTimelapseFrames,
+	}}
+
+	for name, date := range map[string]*time.Time{"from": &query.from, "to": &query.to} {
+		if value := values.Get(name); value != "" {
+			parsed, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				return query, fmt.Errorf("%s must be a date formatted as YYYY-MM-DD", name)
+			}
+			*date = parsed
+		}
+	}
+	if !query.from.IsZero() && !query.to.IsZero() && query.to.Before(query.from) {
+		return query, errors.New("to must not be before from")
+	}
+
+	if value := values.Get("delay"); value != "" {
+		ms, err := strconv.Atoi(value)
+		if err != nil || ms < 20 || ms > 10_000 {
+			return query, errors.New("delay must be between 20 and 10000 milliseconds")
+		}
+		query.opts.Delay = time.Duration(ms) * time.Millisecond
+	}
+
+	if value := values.Get("size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 16 || size > maxTimelapseSize {
+			return query, fmt.Errorf("size must be between 16 and %d pixels", maxTimelapseSize)
+		}
+		query.opts.Size = size
+	}
+
+	return query, nil
+}
+
+// timelapseImages fetches the plant's images which go into a timelapse, skipping those deleted since they were
+// listed.
+func (s *Server) timelapseImages(id string, infos []fs.ImageInfo) ([]fs.ImageMetadata, error) {
+	images := make([]fs.ImageMetadata, 0, len(infos))
+	for _, info := range infos {
+		img, err := s.store.Images().GetImage(id, info.FileName)
+		switch {
+		case errors.Is(err, fs.ErrKeyNotFound), errors.Is(err, fs.ErrImageNotFound):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to get image %s: %w", info.FileName, err)
+		}
+		images = append(images, *img)
+	}
+	return images, nil
+}
+
+// timelapseETag identifies a timelapse by its query and the images which go into it, by their name, size and
+// when they were saved, so that the images needn't be read to revalidate it.
+func timelapseETag(id string, query timelapseQuery, infos []fs.ImageInfo) string {
+	digest := sha256.New()
+	_, _ = fmt.Fprintf(digest, "%s\n%s\n%s\n%s\n%d\n", id,
+		query.from.Format(time.DateOnly), query.to.Format(time.DateOnly), query.opts.Delay, query.opts.Size)
+	for _, info := range infos {
+		_, _ = fmt.Fprintf(digest, "%s %d %d\n", info.FileName, info.Size, info.SavedAt.UnixNano())
+	}
+	return `"` + hex.EncodeToString(digest.Sum(nil)[:16]) + `"`
+}
+
+// timelapseCache holds the most recently encoded timelapses, keyed by ETag. The zero value is ready to use.
+type timelapseCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	order   []string // keys, oldest first
+}
+
+func (c *timelapseCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.entries[key]
+	return data, ok
+}
+
+func (c *timelapseCache) add(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string][]byte)
+	}
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	if len(c.order) == timelapseCacheEntries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = data
+	c.order = append(c.order, key)
+}
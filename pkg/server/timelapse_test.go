@@ -0,0 +1,91 @@
+package server
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/fs"
+	"github.com/williamnoble/kube-botany/pkg/repository"
+	"image"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingImages counts the images read from an fs.ImageStore.
+type countingImages struct {
+	fs.ImageStore
+	gets, lists atomic.Int32
+}
+
+func (c *countingImages) GetImage(key, fileName string) (*fs.ImageMetadata, error) {
+	c.gets.Add(1)
+	return c.ImageStore.GetImage(key, fileName)
+}
+
+func (c *countingImages) GetImagesForKey(key string) ([]fs.ImageMetadata, bool) {
+	c.lists.Add(1)
+	return c.ImageStore.GetImagesForKey(key)
+}
+
+func TestGetPlantTimelapse(t *testing.T) {
+	t.Parallel()
+	images := &countingImages{ImageStore: fs.NewInMemoryImageStore()}
+	s, err := repository.New(repository.Options{VarietiesPath: "../plant/varieties.json", Images: images})
+	require.NoError(t, err)
+	_, err = s.NewPlant("TestPlant", "TestBonsai", "bonsai", time.Now())
+	require.NoError(t, err)
+	for _, day := range []string{"2025-01-01", "2025-01-02", "2025-01-03"} {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))))
+		require.NoError(t, s.Images().SaveImage("TestPlant", day+"-TestPlant.png", buf.Bytes()))
+	}
+	server := &Server{store: s}
+
+	get := func(url, etag string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		server.Routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/api/plants/TestPlant/timelapse.gif?from=2025-01-02&delay=100", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "image/gif", rr.Header().Get("Content-Type"))
+	animation, err := gif.DecodeAll(rr.Body)
+	require.NoError(t, err)
+	assert.Len(t, animation.Image, 2)
+	assert.Equal(t, []int{10, 10}, animation.Delay)
+	assert.Equal(t, int32(2), images.gets.Load(), "only the images in range are read")
+
+	// the result is cached, and revalidated with its ETag
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	_, cached := server.timelapses.get(etag)
+	assert.True(t, cached)
+	assert.Equal(t, http.StatusNotModified, get("/api/plants/TestPlant/timelapse.gif?from=2025-01-02&delay=100", etag).Code)
+	assert.Equal(t, http.StatusOK, get("/api/plants/TestPlant/timelapse.gif?from=2025-01-02&delay=100", "").Code)
+	assert.Equal(t, int32(2), images.gets.Load(), "images aren't read to revalidate or serve a cached timelapse")
+	assert.NotEqual(t, etag, get("/api/plants/TestPlant/timelapse.gif", "").Header().Get("ETag"))
+	assert.Zero(t, images.lists.Load())
+
+	// replacing an image changes the ETag
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 16, 16))))
+	require.NoError(t, s.Images().SaveImage("TestPlant", "2025-01-03-TestPlant.png", buf.Bytes()))
+	rr = get("/api/plants/TestPlant/timelapse.gif?from=2025-01-02&delay=100", "")
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusNotFound, get("/api/plants/TestPlant/timelapse.gif?from=2025-02-01", "").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/plants/OtherPlant/timelapse.gif", "").Code)
+	for _, query := range []string{"from=yesterday", "from=2025-01-03&to=2025-01-01", "delay=5", "size=4096"} {
+		assert.Equal(t, http.StatusBadRequest, get("/api/plants/TestPlant/timelapse.gif?"+query, "").Code, query)
+	}
+}
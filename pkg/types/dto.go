@@ -3,6 +3,8 @@ package types
 import (
 	"fmt"
 	"github.com/williamnoble/kube-botany/pkg/plant"
+	"net/url"
+	"time"
 )
 
 // PlantDTO represents a plant in API responses and UI rendering
@@ -10,11 +12,24 @@ type PlantDTO struct {
 	Id                string `json:"id"`                   // Unique identifier for the plant
 	FriendlyName      string `json:"friendly_name"`        // Display name for the plant
 	Variety           string `json:"variety"`              // Variety of plant (e.g., bonsai, sunflower)
+	Motif             string `json:"motif,omitempty"`      // Decorative motif drawn alongside the plant
+	Generation        int64  `json:"generation"`           // Incremented whenever the plant's spec changes
 	DaysAlive         int    `json:"days_alive,omitempty"` // Number of days the plant has been alive
 	DaysToMaturity    int    `json:"days_to_maturity,omitempty"`
+	CurrentGrowth     int64  `json:"current_growth"`      // Accumulated growth, determines the growth stage
 	CurrentWaterLevel int    `json:"current_water_level"` // The current water level
+	MaxWaterLevel     int    `json:"max_water_level"`     // Above this level the plant is overwatered
 	GrowthStage       string `json:"growth_stage"`        // Derives growth stage from current growth
 
+	Vitality         int        `json:"vitality"`                    // Between 0 (dead) and 100 (full health)
+	Condition        string     `json:"condition"`                   // healthy, thirsty, wilting, overwatered, root_rot or dead
+	DroughtSince     *time.Time `json:"drought_since,omitempty"`     // When the water level dropped below the minimum
+	OverwateredSince *time.Time `json:"overwatered_since,omitempty"` // When the water level rose above the maximum
+	DiedAt           *time.Time `json:"died_at,omitempty"`           // When the plant died
+
+	CreatedAt   time.Time `json:"created_at"`   // When the plant was created
+	LastUpdated time.Time `json:"last_updated"` // When the plant's state was last brought up to date
+
 	Image string `json:"image,omitempty"` // Path to the plant's image
 }
 
@@ -25,26 +40,79 @@ func IntoPlantDTO(p *plant.Plant) PlantDTO {
 		Id:                p.Id, // Unique ID
 		FriendlyName:      p.FriendlyName,
 		Variety:           p.Variety.Type,
+		Motif:             p.Motif,
+		Generation:        p.Generation,
 		DaysAlive:         p.DaysAlive(),
 		DaysToMaturity:    p.DaysToMaturity(),
+		CurrentGrowth:     p.CurrentGrowth(),
 		CurrentWaterLevel: p.CurrentWaterLevel(),
+		MaxWaterLevel:     p.MaximumWaterLevel(),
 		GrowthStage:       p.GrowthStage(),
-		Image:             fmt.Sprintf("/static/images/%s", p.Image()),
+		Vitality:          p.Vitality(),
+		Condition:         p.Condition().String(),
+		DroughtSince:      optionalTime(p.Health.DroughtSince),
+		OverwateredSince:  optionalTime(p.Health.OverwateredSince),
+		DiedAt:            optionalTime(p.DiedAt),
+		CreatedAt:         p.CreationTime,
+		LastUpdated:       p.LastUpdated,
+		Image:             ImageURL(p.Id, p.Image()),
 	}
 
 	return r
 }
 
-// FromPlantDTO converts from PlantDTO to *plant.Plant for API responses and UI rendering
-// TODO: This uses the wrong types, fix when writing Operator
-func FromPlantDTO(p *plant.Plant) PlantDTO {
-	r := PlantDTO{
-		Id:                p.Id,
-		FriendlyName:      p.FriendlyName,
-		Variety:           p.Variety.Type,
-		DaysAlive:         p.DaysAlive(),
-		CurrentWaterLevel: p.CurrentWaterLevel(),
-		GrowthStage:       p.GrowthStage(),
+// ImageURL returns the path from which the plant's image with the given file name is served
+func ImageURL(id, fileName string) string {
+	return fmt.Sprintf("/api/plants/%s/images/%s", url.PathEscape(id), url.PathEscape(fileName))
+}
+
+// FormatURL returns the path from which the plant is served rendered in the given format e.g. "svg"
+func FormatURL(id, format string) string {
+	return fmt.Sprintf("/api/plants/%s/format/%s", url.PathEscape(id), url.PathEscape(format))
+}
+
+// optionalTime returns nil for the zero time so that it is omitted from responses
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
 	}
-	return r
+	return &t
+}
+
+// FromPlantDTO converts a PlantDTO back into a *plant.Plant of the given variety, it is the inverse of IntoPlantDTO
+// for the fields which describe the plant's state. Fields derived from that state, such as the growth stage, are
+// ignored.
+func FromPlantDTO(dto PlantDTO, variety *plant.Variety) (*plant.Plant, error) {
+	if variety == nil || variety.Type != dto.Variety {
+		return nil, fmt.Errorf("plant %s: expected variety %q", dto.Id, dto.Variety)
+	}
+
+	p := &plant.Plant{
+		Id:           dto.Id,
+		FriendlyName: dto.FriendlyName,
+		Variety:      variety,
+		Motif:        dto.Motif,
+		Generation:   dto.Generation,
+		CreationTime: dto.CreatedAt,
+		LastUpdated:  dto.LastUpdated,
+		Health: plant.Health{
+			CurrentGrowth:     float64(dto.CurrentGrowth),
+			CurrentWaterLevel: float64(dto.CurrentWaterLevel),
+			Vitality:          float64(dto.Vitality),
+		},
+	}
+	if dto.DroughtSince != nil {
+		p.Health.DroughtSince = *dto.DroughtSince
+	}
+	if dto.OverwateredSince != nil {
+		p.Health.OverwateredSince = *dto.OverwateredSince
+	}
+	if dto.DiedAt != nil {
+		p.DiedAt = *dto.DiedAt
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
@@ -0,0 +1,38 @@
+package types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"testing"
+	"time"
+)
+
+func TestPlantDTORoundTrip(t *testing.T) {
+	t.Parallel()
+	variety := &plant.Variety{Type: "bonsai", GrowthRatePerDay: 5, WaterConsumptionUnitsPerDay: 2, MinimumWaterLevel: 10}
+	creationTime := time.Now().Add(-72 * time.Hour).UTC()
+
+	original := &plant.Plant{
+		Id:           "FooPlant",
+		FriendlyName: "MyBonsai",
+		Variety:      variety,
+		Motif:        "gopher",
+		Generation:   3,
+		CreationTime: creationTime,
+		LastUpdated:  creationTime.Add(48 * time.Hour),
+		Health: plant.Health{
+			CurrentGrowth:     10,
+			CurrentWaterLevel: 42,
+			Vitality:          plant.MaxVitality,
+		},
+	}
+
+	p, err := FromPlantDTO(IntoPlantDTO(original), variety)
+	require.NoError(t, err)
+	assert.Equal(t, original, p)
+
+	// the variety must match the DTO
+	_, err = FromPlantDTO(IntoPlantDTO(original), &plant.Variety{Type: "sunflower"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"fmt"
+	"github.com/williamnoble/kube-botany/pkg/plant"
+	"time"
+)
+
+const (
+	PlantAPIVersion = "botany.williamnoble.dev/v1alpha1" // apiVersion of the Plant custom resource
+	PlantKind       = "Plant"                            // kind of the Plant custom resource
+)
+
+// PlantResource mirrors the Plant custom resource managed by kube-botany-operator. The operator owns the spec and
+// PUTs the resource on every reconcile, the backend owns the status and returns it in the response. Field names
+// follow Kubernetes conventions so that the status can be copied onto the custom resource unchanged.
+type PlantResource struct {
+	APIVersion string      `json:"apiVersion,omitempty"`
+	Kind       string      `json:"kind,omitempty"`
+	Metadata   ObjectMeta  `json:"metadata"`
+	Spec       PlantSpec   `json:"spec"`
+	Status     PlantStatus `json:"status,omitzero"`
+}
+
+// ObjectMeta is the subset of the Kubernetes object metadata used by the backend.
+type ObjectMeta struct {
+	Name       string `json:"name,omitempty"`       // the plant's id, must match the URL when set
+	Namespace  string `json:"namespace,omitempty"`  // informational, plant ids are global
+	Generation int64  `json:"generation,omitempty"` // the custom resource's generation, zero if not managed
+}
+
+// PlantSpec is the desired state of a plant.
+type PlantSpec struct {
+	Variety      string `json:"variety"`                // the variety of plant e.g. bonsai, immutable
+	FriendlyName string `json:"friendlyName,omitempty"` // display name for the plant
+	Motif        string `json:"motif,omitempty"`        // decorative motif drawn alongside the plant e.g. gopher
+}
+
+// PlantStatus is the observed state of a plant.
+type PlantStatus struct {
+	ObservedGeneration int64     `json:"observedGeneration"`
+	GrowthStage        string    `json:"growthStage,omitempty"`
+	Condition          string    `json:"condition,omitempty"`
+	CurrentGrowth      int64     `json:"currentGrowth"`
+	CurrentWaterLevel  int       `json:"currentWaterLevel"`
+	Vitality           int       `json:"vitality"`
+	ImageURL           string    `json:"imageURL,omitempty"`
+	LastUpdated        time.Time `json:"lastUpdated,omitzero"`
+}
+
+// Validate checks the resource can be applied to the plant with the given id.
+func (r PlantResource) Validate(id string) error {
+	if r.Kind != "" && r.Kind != PlantKind {
+		return fmt.Errorf("kind must be %s", PlantKind)
+	}
+	if r.Metadata.Name != "" && r.Metadata.Name != id {
+		return fmt.Errorf("metadata.name %q does not match plant id %q", r.Metadata.Name, id)
+	}
+	if r.Metadata.Generation < 0 {
+		return fmt.Errorf("metadata.generation cannot be negative")
+	}
+	if r.Spec.Variety == "" {
+		return fmt.Errorf("spec.variety is required")
+	}
+	return nil
+}
+
+// IntoPlantSpec returns the spec describing the plant.
+func IntoPlantSpec(p *plant.Plant) PlantSpec {
+	return PlantSpec{
+		Variety:      p.Variety.Type,
+		FriendlyName: p.FriendlyName,
+		Motif:        p.Motif,
+	}
+}
+
+// IntoPlantResource converts a plant.Plant into the Plant custom resource, with its status populated.
+func IntoPlantResource(p *plant.Plant) PlantResource {
+	dto := IntoPlantDTO(p)
+	return PlantResource{
+		APIVersion: PlantAPIVersion,
+		Kind:       PlantKind,
+		Metadata: ObjectMeta{
+			Name:       p.Id,
+			Generation: p.Generation,
+		},
+		Spec: IntoPlantSpec(p),
+		Status: PlantStatus{
+			ObservedGeneration: p.Generation,
+			GrowthStage:        dto.GrowthStage,
+			Condition:          dto.Condition,
+			CurrentGrowth:      dto.CurrentGrowth,
+			CurrentWaterLevel:  dto.CurrentWaterLevel,
+			Vitality:           dto.Vitality,
+			ImageURL:           dto.Image,
+			LastUpdated:        p.LastUpdated,
+		},
+	}
+}